@@ -0,0 +1,87 @@
+package ecrs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// DeleteRepository deletes the ECR repository with the given name in each of the repository regions if it exists.
+// Repositories which contain images are only deleted if force is true
+func (o *Options) DeleteRepository(repoName string, force bool) error {
+	regions := o.RepositoryRegions()
+	if len(regions) == 0 {
+		return options.MissingOption("aws-region")
+	}
+	for _, region := range regions {
+		if len(regions) > 1 {
+			log.Logger().Infof("deleting the ECR repository %s in region %s", termcolor.ColorInfo(repoName), termcolor.ColorInfo(region))
+		}
+		ro := o.RegionOptions(region)
+		err := ro.deleteRepository(repoName, force)
+
+		// lets reuse any lazily created client
+		o.saveRegionClient(ro)
+		if err != nil {
+			if len(regions) > 1 {
+				return fmt.Errorf("failed in region %s: %w", region, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Options) deleteRepository(repoName string, force bool) error {
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+
+	_, err = client.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{
+		RepositoryNames: []string{repoName},
+		RegistryId:      o.registryIDPointer(),
+	})
+	if err != nil {
+		var notFoundErr *types.RepositoryNotFoundException
+		if errors.As(err, &notFoundErr) {
+			log.Logger().Infof("ECR repository %s does not exist", termcolor.ColorInfo(repoName))
+			return nil
+		}
+		return fmt.Errorf("failed to check for repository %s with registry ID %s: %w", repoName, o.GetRegistryID(), err)
+	}
+
+	images, err := client.ListImages(ctx, &ecr.ListImagesInput{
+		RepositoryName: aws.String(repoName),
+		RegistryId:     o.registryIDPointer(),
+		MaxResults:     aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list images in the ECR repository %s: %w", repoName, err)
+	}
+	if len(images.ImageIds) > 0 && !force {
+		return fmt.Errorf("the ECR repository %s is not empty. Use --force to delete it and its images", repoName)
+	}
+
+	if o.DryRun {
+		log.Logger().Infof("would delete ECR repository %s", termcolor.ColorInfo(repoName))
+		return nil
+	}
+	_, err = client.DeleteRepository(ctx, &ecr.DeleteRepositoryInput{
+		RepositoryName: aws.String(repoName),
+		RegistryId:     o.registryIDPointer(),
+		Force:          force,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete the ECR repository %s: %w", repoName, err)
+	}
+	log.Logger().Infof("deleted ECR repository %s", termcolor.ColorInfo(repoName))
+	return nil
+}
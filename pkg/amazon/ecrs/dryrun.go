@@ -0,0 +1,81 @@
+package ecrs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// PolicyDiff returns a unified diff of the current and desired policy documents after indenting them consistently
+func PolicyDiff(current, desired string) string {
+	diff := difflib.UnifiedDiff{
+		A:        policyLines(current),
+		B:        policyLines(desired),
+		FromFile: "current",
+		ToFile:   "desired",
+		Context:  3,
+	}
+	text, err := difflib.GetUnifiedDiffString(diff)
+	if err != nil {
+		return fmt.Sprintf("failed to diff policies: %s", err.Error())
+	}
+	return text
+}
+
+// policyLines returns the lines of the consistently indented policy
+func policyLines(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	buf := bytes.Buffer{}
+	err := json.Indent(&buf, []byte(text), "", "  ")
+	if err == nil {
+		text = buf.String()
+	}
+	return difflib.SplitLines(text)
+}
+
+// isDryRunMissingRepository returns true if we are in dry run mode and the error is due to a repository
+// which would have been created
+func (o *Options) isDryRunMissingRepository(err error) bool {
+	var notFoundErr *types.RepositoryNotFoundException
+	return o.DryRun && errors.As(err, &notFoundErr)
+}
+
+// describeCreateRepository returns a description of the settings of a repository which would be created
+func describeCreateRepository(input *ecr.CreateRepositoryInput) string {
+	var values []string
+	if input.ImageTagMutability != "" {
+		values = append(values, "tag mutability "+string(input.ImageTagMutability))
+	}
+	if input.ImageScanningConfiguration != nil {
+		values = append(values, "scan on push "+strconv.FormatBool(input.ImageScanningConfiguration.ScanOnPush))
+	}
+	if input.EncryptionConfiguration != nil {
+		encryption := "encryption " + string(input.EncryptionConfiguration.EncryptionType)
+		if input.EncryptionConfiguration.KmsKey != nil {
+			encryption += " " + aws.ToString(input.EncryptionConfiguration.KmsKey)
+		}
+		values = append(values, encryption)
+	}
+	if len(input.Tags) > 0 {
+		var tags []string
+		for _, t := range input.Tags {
+			tags = append(tags, aws.ToString(t.Key)+"="+aws.ToString(t.Value))
+		}
+		values = append(values, "tags "+strings.Join(tags, ", "))
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return " with " + strings.Join(values, " and ")
+}
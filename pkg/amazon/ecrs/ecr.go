@@ -4,13 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
@@ -18,29 +22,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	defaultECRLifecyclePolicy = `
-{
-    "rules": [
-        {
-            "rulePriority": 1,
-            "description": "Expire images older than 14 days",
-            "selection": {
-                "tagStatus": "tagged",
-                "countType": "sinceImagePushed",
-                "tagPrefixList": ["0.0.0-"],
-                "countUnit": "days",
-                "countNumber": 14
-            },
-            "action": {
-                "type": "expire"
-            }
-        }
-    ]
-}
-`
-)
-
 var (
 	defaultECRRepositoryPolicy = `
 	{
@@ -50,6 +31,10 @@ var (
 `
 )
 
+var (
+	accountIDRegex = regexp.MustCompile(`^[0-9]{12}$`)
+)
+
 type ECRClient interface {
 	DescribeRepositories(context.Context, *ecr.DescribeRepositoriesInput, ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
 	CreateRepository(ctx context.Context, params *ecr.CreateRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryOutput, error)
@@ -57,33 +42,124 @@ type ECRClient interface {
 	PutLifecyclePolicy(ctx context.Context, params *ecr.PutLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.PutLifecyclePolicyOutput, error)
 	GetRepositoryPolicy(ctx context.Context, params *ecr.GetRepositoryPolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetRepositoryPolicyOutput, error)
 	SetRepositoryPolicy(ctx context.Context, params *ecr.SetRepositoryPolicyInput, optFns ...func(*ecr.Options)) (*ecr.SetRepositoryPolicyOutput, error)
+	PutImageTagMutability(ctx context.Context, params *ecr.PutImageTagMutabilityInput, optFns ...func(*ecr.Options)) (*ecr.PutImageTagMutabilityOutput, error)
+	PutImageScanningConfiguration(ctx context.Context, params *ecr.PutImageScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.PutImageScanningConfigurationOutput, error)
+	ListTagsForResource(ctx context.Context, params *ecr.ListTagsForResourceInput, optFns ...func(*ecr.Options)) (*ecr.ListTagsForResourceOutput, error)
+	TagResource(ctx context.Context, params *ecr.TagResourceInput, optFns ...func(*ecr.Options)) (*ecr.TagResourceOutput, error)
+	UntagResource(ctx context.Context, params *ecr.UntagResourceInput, optFns ...func(*ecr.Options)) (*ecr.UntagResourceOutput, error)
+	DeleteRepository(ctx context.Context, params *ecr.DeleteRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.DeleteRepositoryOutput, error)
+	ListImages(ctx context.Context, params *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
+	DescribeRegistry(ctx context.Context, params *ecr.DescribeRegistryInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRegistryOutput, error)
+	PutReplicationConfiguration(ctx context.Context, params *ecr.PutReplicationConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.PutReplicationConfigurationOutput, error)
+	DescribePullThroughCacheRules(ctx context.Context, params *ecr.DescribePullThroughCacheRulesInput, optFns ...func(*ecr.Options)) (*ecr.DescribePullThroughCacheRulesOutput, error)
+	CreatePullThroughCacheRule(ctx context.Context, params *ecr.CreatePullThroughCacheRuleInput, optFns ...func(*ecr.Options)) (*ecr.CreatePullThroughCacheRuleOutput, error)
+	UpdatePullThroughCacheRule(ctx context.Context, params *ecr.UpdatePullThroughCacheRuleInput, optFns ...func(*ecr.Options)) (*ecr.UpdatePullThroughCacheRuleOutput, error)
+	GetRegistryScanningConfiguration(ctx context.Context, params *ecr.GetRegistryScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.GetRegistryScanningConfigurationOutput, error)
+	PutRegistryScanningConfiguration(ctx context.Context, params *ecr.PutRegistryScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.PutRegistryScanningConfigurationOutput, error)
+	DescribeImageScanFindings(ctx context.Context, params *ecr.DescribeImageScanFindingsInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImageScanFindingsOutput, error)
+}
+
+// PolicyFinder finds the central policies of the development environment
+type PolicyFinder interface {
+	FindPolicy(key string) (string, error)
 }
 
 type Options struct {
 	amazon.Options
-	RegistryID                string `env:"REGISTRY_ID"`
-	Registry                  string `env:"DOCKER_REGISTRY"`
-	RegistryOrganisation      string `env:"DOCKER_REGISTRY_ORG"`
-	AppName                   string `env:"APP_NAME"`
-	ECRLifecyclePolicy        string `env:"ECR_LIFECYCLE_POLICY"`
-	ECRRepositoryPolicy       string `env:"ECR_REPOSITORY_POLICY"`
-	CreateECRLifeCyclePolicy  bool   `env:"CREATE_ECR_LIFECYCLE_POLICY,default=true"`
-	CreateECRRepositoryPolicy bool   `env:"CREATE_ECR_REPOSITORY_POLICY,default=false"`
-	ECRClient                 ECRClient
-	CacheSuffix               string `env:"CACHE_SUFFIX"` // CacheSuffix is declared here to get handling of env to work
+	RegistryID                   string            `env:"REGISTRY_ID"`
+	RegistryRoles                map[string]string `env:"ECR_REGISTRY_ROLES,separator=="`
+	RegistryExternalID           string            `env:"ECR_REGISTRY_ROLE_EXTERNAL_ID"`
+	Regions                      []string          `env:"ECR_REGIONS"`
+	ReplicationDestinations      []string          `env:"ECR_REPLICATION_DESTINATIONS"`
+	Registry                     string            `env:"DOCKER_REGISTRY"`
+	RegistryOrganisation         string            `env:"DOCKER_REGISTRY_ORG"`
+	AppName                      string            `env:"APP_NAME"`
+	ECRLifecyclePolicy           string            `env:"ECR_LIFECYCLE_POLICY"`
+	ECRLifecyclePolicyFile       string            `env:"ECR_LIFECYCLE_POLICY_FILE"`
+	ECRCacheLifecyclePolicy      string            `env:"ECR_CACHE_LIFECYCLE_POLICY"`
+	ECRRepositoryPolicy          string            `env:"ECR_REPOSITORY_POLICY"`
+	ECRRepositoryPolicyFile      string            `env:"ECR_REPOSITORY_POLICY_FILE"`
+	LifecyclePolicyMode          string            `env:"ECR_LIFECYCLE_POLICY_MODE,default=replace"`
+	RepositoryPolicyMode         string            `env:"ECR_REPOSITORY_POLICY_MODE,default=replace"`
+	RepositoryRulesFile          string            `env:"ECR_REPOSITORY_RULES_FILE"`
+	AllowPullAccounts            []string          `env:"ECR_ALLOW_PULL_ACCOUNTS"`
+	AllowPushRoleARNs            []string          `env:"ECR_ALLOW_PUSH_ROLE_ARNS"`
+	AllowPullOrgIDs              []string          `env:"ECR_ALLOW_PULL_ORG_IDS"`
+	ExpireUntaggedAfterDays      int               `env:"ECR_EXPIRE_UNTAGGED_AFTER_DAYS"`
+	ExpireTagPrefixes            []string          `env:"ECR_EXPIRE_TAG_PREFIXES"`
+	KeepLastNTagged              int               `env:"ECR_KEEP_LAST_N_TAGGED"`
+	CreateECRLifeCyclePolicy     bool              `env:"CREATE_ECR_LIFECYCLE_POLICY,default=true"`
+	CreateECRRepositoryPolicy    bool              `env:"CREATE_ECR_REPOSITORY_POLICY,default=false"`
+	ImageTagMutability           string            `env:"ECR_IMAGE_TAG_MUTABILITY"`
+	ImageTagMutabilityExclusions []string          `env:"ECR_IMAGE_TAG_MUTABILITY_EXCLUSIONS"`
+	ScanOnPush                   string            `env:"ECR_SCAN_ON_PUSH"`
+	ScanType                     string            `env:"ECR_SCAN_TYPE,default=ENHANCED"`
+	ScanFrequency                string            `env:"ECR_SCAN_FREQUENCY,default=CONTINUOUS_SCAN"`
+	ScanFilters                  []string          `env:"ECR_SCAN_FILTERS"`
+	EncryptionType               string            `env:"ECR_ENCRYPTION_TYPE"`
+	KMSKey                       string            `env:"ECR_KMS_KEY"`
+	Tags                         []string          `env:"ECR_TAGS"`
+	AutoTags                     bool              `env:"ECR_AUTO_TAGS,default=true"`
+	Owner                        string            `env:"REPO_OWNER"`
+	Repository                   string            `env:"REPO_NAME"`
+	ClusterName                  string            `env:"CLUSTER_NAME"`
+	DryRun                       bool
+	ECRClient                    ECRClient
+	ECRClients                   map[string]ECRClient
+	PolicyFinder                 PolicyFinder
+	CacheSuffix                  string `env:"CACHE_SUFFIX"` // CacheSuffix is declared here to get handling of env to work
+
+	repositoryRules *RepositoryRules
+	loadedPolicies  map[string]string
+	cacheRepository bool
 }
 
-func (o *Options) AddFlags(cmd *cobra.Command) {
+// AddRegistryFlags adds the flags to find the registry and organisation
+func (o *Options) AddRegistryFlags(cmd *cobra.Command) {
 	o.Options.AddFlags(cmd)
 
 	cmd.Flags().StringVarP(&o.RegistryID, "registry-id", "", o.RegistryID, "The registry ID to use. If not specified finds the first path of the registry. $REGISTRY_ID")
+	cmd.Flags().StringToStringVarP(&o.RegistryRoles, "registry-role", "", o.RegistryRoles, "The role ARN to assume for a registry ID in a different account to the caller, as accountID=roleARN. Can be specified in $ECR_REGISTRY_ROLES")
+	cmd.Flags().StringVarP(&o.RegistryExternalID, "registry-role-external-id", "", o.RegistryExternalID, "The external ID used when assuming a --registry-role. Can be specified in $ECR_REGISTRY_ROLE_EXTERNAL_ID")
 	cmd.Flags().StringVarP(&o.Registry, "registry", "r", o.Registry, "The registry to use. Defaults to $DOCKER_REGISTRY")
 	cmd.Flags().StringVarP(&o.RegistryOrganisation, "organisation", "o", o.RegistryOrganisation, "The registry organisation to use. Defaults to $DOCKER_REGISTRY_ORG")
+}
+
+// AddFlags adds the flags for lazily creating repositories
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	o.AddRegistryFlags(cmd)
+
 	cmd.Flags().StringVarP(&o.AppName, "app", "a", o.AppName, "The app name to use. Defaults to $APP_NAME")
-	cmd.Flags().StringVarP(&o.ECRLifecyclePolicy, "ecr-lifecycle-policy", "", o.ECRLifecyclePolicy, "ECR lifecycle policies to apply to the repository. Can be specified in $ECR_LIFECYCLE_POLICY.")
+	cmd.Flags().StringSliceVarP(&o.Regions, "regions", "", o.Regions, "The comma separated AWS regions to create and reconcile the repository in. Defaults to the AWS region. Can be specified in $ECR_REGIONS")
+	o.AddLifecyclePolicyFlags(cmd)
+	cmd.Flags().StringArrayVarP(&o.ReplicationDestinations, "replication-destination", "", o.ReplicationDestinations, "The region or region=registryID the repositories of the organisation are replicated to via the registry replication configuration. Can be specified multiple times or in the comma separated $ECR_REPLICATION_DESTINATIONS")
+	cmd.Flags().StringVarP(&o.ECRCacheLifecyclePolicy, "ecr-cache-lifecycle-policy", "", o.ECRCacheLifecyclePolicy, "ECR lifecycle policy to apply to the cache repository. If not specified a cache repository without a lifecycle policy expires untagged images after 1 day and keeps the last 10 tagged images. Can be specified in $ECR_CACHE_LIFECYCLE_POLICY.")
 	cmd.Flags().StringVarP(&o.ECRRepositoryPolicy, "ecr-repository-policy", "", o.ECRRepositoryPolicy, "ECR repository policies to apply to the repository. Can be specified in $ECR_REPOSITORY_POLICY.")
+	cmd.Flags().StringVarP(&o.ECRRepositoryPolicyFile, "ecr-repository-policy-file", "", o.ECRRepositoryPolicyFile, "The file containing the ECR repository policy to apply to the repository. Can be specified in $ECR_REPOSITORY_POLICY_FILE.")
+	cmd.Flags().StringArrayVarP(&o.AllowPullAccounts, "allow-pull-account", "", o.AllowPullAccounts, "The AWS account ID allowed to pull images via the repository policy. Can be specified multiple times or in the comma separated $ECR_ALLOW_PULL_ACCOUNTS.")
+	cmd.Flags().StringArrayVarP(&o.AllowPushRoleARNs, "allow-push-role-arn", "", o.AllowPushRoleARNs, "The IAM role ARN allowed to push images via the repository policy. Can be specified multiple times or in the comma separated $ECR_ALLOW_PUSH_ROLE_ARNS.")
+	cmd.Flags().StringArrayVarP(&o.AllowPullOrgIDs, "allow-pull-org-id", "", o.AllowPullOrgIDs, "The AWS organization ID whose principals are allowed to pull images via the repository policy. Can be specified multiple times or in the comma separated $ECR_ALLOW_PULL_ORG_IDS.")
+	cmd.Flags().StringVarP(&o.RepositoryRulesFile, "repository-rules-file", "", o.RepositoryRulesFile, "The YAML file of ordered rules mapping a repository name glob or regex to the lifecycle policy, repository policy, image tag mutability and scan on push of the repository. The first matching rule is used. Can be specified in $ECR_REPOSITORY_RULES_FILE.")
+	cmd.Flags().StringVarP(&o.RepositoryPolicyMode, "repository-policy-mode", "", o.RepositoryPolicyMode, "How the repository policy is applied to an existing policy: replace, merge which adds or updates statements by Sid, or ensure-statements which only adds missing statements. Can be specified in $ECR_REPOSITORY_POLICY_MODE.")
 	cmd.Flags().BoolVarP(&o.CreateECRLifeCyclePolicy, "create-ecr-lifecycle-policy", "", o.CreateECRLifeCyclePolicy, "Should ECR Lifecycle Policy be created. Can be specified in $CREATE_ECR_LIFECYCLE_POLICY.")
 	cmd.Flags().BoolVarP(&o.CreateECRRepositoryPolicy, "create-ecr-repository-policy", "", o.CreateECRRepositoryPolicy, "Should ECR Repository Policy be created. Can be specified in $CREATE_ECR_REPOSITORY_POLICY.")
+	cmd.Flags().StringVarP(&o.ImageTagMutability, "image-tag-mutability", "", o.ImageTagMutability, "The image tag mutability of the repository: MUTABLE, IMMUTABLE, MUTABLE_WITH_EXCLUSION or IMMUTABLE_WITH_EXCLUSION. If not specified existing repositories are left unchanged. Can be specified in $ECR_IMAGE_TAG_MUTABILITY.")
+	cmd.Flags().StringArrayVarP(&o.ImageTagMutabilityExclusions, "image-tag-mutability-exclusion", "", o.ImageTagMutabilityExclusions, "The wildcard tag filters excluded from the image tag mutability when using a _WITH_EXCLUSION mutability. Can be specified in the comma separated $ECR_IMAGE_TAG_MUTABILITY_EXCLUSIONS.")
+	cmd.Flags().StringVarP(&o.EncryptionType, "encryption-type", "", o.EncryptionType, "The encryption type of new repositories: AES256, KMS or KMS_DSSE. Existing repositories cannot be changed so any drift is reported. Can be specified in $ECR_ENCRYPTION_TYPE.")
+	cmd.Flags().StringVarP(&o.KMSKey, "kms-key", "", o.KMSKey, "The ARN, key ID or alias of the KMS key used to encrypt new repositories. Implies --encryption-type KMS. Can be specified in $ECR_KMS_KEY.")
+	cmd.Flags().StringVarP(&o.ScanOnPush, "scan-on-push", "", o.ScanOnPush, "Whether images should be scanned on push: true or false. If not specified existing repositories are left unchanged. Can be specified in $ECR_SCAN_ON_PUSH.")
+	cmd.Flags().StringArrayVarP(&o.Tags, "tag", "", o.Tags, "A key=value tag to apply to the repository. A jenkins-x.io/ tag with an empty value removes the automatic tag from existing repositories. Can be specified multiple times or in the comma separated $ECR_TAGS.")
+	cmd.Flags().BoolVarP(&o.AutoTags, "auto-tags", "", o.AutoTags, "Should the owner, repository, app and cluster tags be applied to the repository. Can be specified in $ECR_AUTO_TAGS.")
+}
+
+// AddLifecyclePolicyFlags adds the flags for specifying or generating the lifecycle policy
+func (o *Options) AddLifecyclePolicyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.ECRLifecyclePolicy, "ecr-lifecycle-policy", "", o.ECRLifecyclePolicy, "ECR lifecycle policies to apply to the repository. Can be specified in $ECR_LIFECYCLE_POLICY.")
+	cmd.Flags().StringVarP(&o.ECRLifecyclePolicyFile, "ecr-lifecycle-policy-file", "", o.ECRLifecyclePolicyFile, "The file containing the ECR lifecycle policy to apply to the repository. Can be specified in $ECR_LIFECYCLE_POLICY_FILE.")
+	cmd.Flags().IntVarP(&o.ExpireUntaggedAfterDays, "expire-untagged-after-days", "", o.ExpireUntaggedAfterDays, "Generates a lifecycle policy rule expiring untagged images after the number of days. Can be specified in $ECR_EXPIRE_UNTAGGED_AFTER_DAYS.")
+	cmd.Flags().StringArrayVarP(&o.ExpireTagPrefixes, "expire-tag-prefix", "", o.ExpireTagPrefixes, "Generates a lifecycle policy rule expiring images with a tag prefix after a number of days of the form prefix=days. Can be specified multiple times or in the comma separated $ECR_EXPIRE_TAG_PREFIXES.")
+	cmd.Flags().IntVarP(&o.KeepLastNTagged, "keep-last-n-tagged", "", o.KeepLastNTagged, "Generates a lifecycle policy rule expiring all but the last number of tagged images. Can be specified in $ECR_KEEP_LAST_N_TAGGED.")
+	cmd.Flags().StringVarP(&o.LifecyclePolicyMode, "lifecycle-policy-mode", "", o.LifecyclePolicyMode, "How the lifecycle policy is applied to an existing policy: replace, merge which adds or updates rules by description or priority, or ensure-statements which only adds missing rules. Can be specified in $ECR_LIFECYCLE_POLICY_MODE.")
 }
 
 func (o *Options) Validate() error {
@@ -110,53 +186,189 @@ func (o *Options) EnvProcess() {
 	}
 }
 
-// LazyCreateRegistry lazily creates the ECR registry if it does not already exist
-func (o *Options) LazyCreateRegistry(appName string) error {
-	ctx := o.GetContext()
+// GetRegistryID returns the registry ID to use. If not specified explicitly the account ID is taken from the
+// first path of the registry host name (e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com)
+func (o *Options) GetRegistryID() string {
+	if o.RegistryID != "" {
+		return o.RegistryID
+	}
+	idx := strings.Index(o.Registry, ".")
+	if idx > 0 && accountIDRegex.MatchString(o.Registry[0:idx]) {
+		return o.Registry[0:idx]
+	}
+	return ""
+}
+
+// GetECRClient lazily creates the ECR client. If a role is configured for the registry ID and the registry belongs to
+// a different account than the caller then the role is assumed so that all calls are made in the registry's account
+func (o *Options) GetECRClient() (ECRClient, error) {
+	if o.ECRClient != nil {
+		return o.ECRClient, nil
+	}
 	cfg, err := o.GetConfig()
 	if err != nil {
-		return fmt.Errorf("failed to create the AWS configuration: %w", err)
+		return nil, fmt.Errorf("failed to create the AWS configuration: %w", err)
 	}
 	if cfg == nil {
-		return fmt.Errorf("no AWS configuration could be found")
+		return nil, fmt.Errorf("no AWS configuration could be found")
 	}
-	if len(appName) <= 2 {
-		return fmt.Errorf("missing valid app name: '%s'", appName)
+	registryID := o.GetRegistryID()
+	roleARN := o.RegistryRoles[registryID]
+	if registryID != "" && roleARN != "" {
+		callerAccountID, err := o.GetCallerAccountID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the caller account to compare with registry ID %s: %w", registryID, err)
+		}
+		if callerAccountID != registryID {
+			log.Logger().Infof("assuming role %s to access the ECR registry %s", termcolor.ColorInfo(roleARN), termcolor.ColorInfo(registryID))
+			assumed := amazon.AssumeRole(*cfg, roleARN, o.RegistryExternalID, o.AWSSessionName)
+			cfg = &assumed
+		}
 	}
-
 	region := o.AWSRegion
-	if region == "" {
-		return options.MissingOption("aws-region")
+	o.ECRClient = ecr.NewFromConfig(*cfg, func(eo *ecr.Options) {
+		if region != "" {
+			eo.Region = region
+		}
+	})
+	return o.ECRClient, nil
+}
+
+// RepositoryRegions returns the regions to create repositories in
+func (o *Options) RepositoryRegions() []string {
+	var answer []string
+	for _, region := range o.Regions {
+		region = strings.TrimSpace(region)
+		if region != "" && stringhelpers.StringArrayIndex(answer, region) < 0 {
+			answer = append(answer, region)
+		}
+	}
+	if len(answer) == 0 && o.AWSRegion != "" {
+		answer = append(answer, o.AWSRegion)
 	}
+	return answer
+}
 
-	// strip any tag/version from the app name
-	idx := strings.Index(appName, ":")
-	if idx > 0 {
-		appName = appName[0:idx]
+// RegionOptions returns the options for the given region using the ECR client of the region
+func (o *Options) RegionOptions(region string) *Options {
+	if region == o.AWSRegion {
+		return o
+	}
+	ro := *o
+	ro.AWSRegion = region
+	ro.ECRClient = o.ECRClients[region]
+	return &ro
+}
+
+// saveRegionClient saves the lazily created ECR client of the region options
+func (o *Options) saveRegionClient(ro *Options) {
+	if ro.AWSRegion == o.AWSRegion {
+		o.ECRClient = ro.ECRClient
+		return
 	}
+	if ro.ECRClient != nil {
+		if o.ECRClients == nil {
+			o.ECRClients = map[string]ECRClient{}
+		}
+		o.ECRClients[ro.AWSRegion] = ro.ECRClient
+	}
+}
+
+// RepositoryName returns the ECR repository name for the given app name using the registry organisation
+func (o *Options) RepositoryName(appName string) string {
+	appName = stripTag(appName)
 	repoName := appName
 	if o.RegistryOrganisation != "" {
 		repoName = o.RegistryOrganisation + "/" + appName
 	}
-	repoName = strings.ToLower(repoName)
+	return strings.ToLower(repoName)
+}
+
+// stripTag strips any tag/version from the app name
+func stripTag(appName string) string {
+	idx := strings.Index(appName, ":")
+	if idx > 0 {
+		return appName[0:idx]
+	}
+	return appName
+}
+
+// LazyCreateRegistry lazily creates the ECR registry if it does not already exist
+func (o *Options) LazyCreateRegistry(appName string) error {
+	if len(appName) <= 2 {
+		return fmt.Errorf("missing valid app name: '%s'", appName)
+	}
+
+	regions := o.RepositoryRegions()
+	if len(regions) == 0 {
+		return options.MissingOption("aws-region")
+	}
+
+	appName = stripTag(appName)
+	repoName := o.RepositoryName(appName)
+	for _, region := range regions {
+		if len(regions) > 1 {
+			log.Logger().Infof("ensuring the ECR repository %s in region %s", termcolor.ColorInfo(repoName), termcolor.ColorInfo(region))
+		}
+		regionOptions := o.RegionOptions(region)
+		ro, err := regionOptions.RepositoryOptions(repoName)
+		if err != nil {
+			return err
+		}
+		err = ro.lazyCreateRepository(appName, repoName)
+
+		// lets reuse any lazily created client
+		o.saveRegionClient(ro)
+		if err != nil {
+			if len(regions) > 1 {
+				return fmt.Errorf("failed in region %s: %w", region, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Options) lazyCreateRepository(appName, repoName string) error {
+	ctx := o.GetContext()
+	err := o.ValidateRepositorySettings()
+	if err != nil {
+		return err
+	}
+	if o.CreateECRLifeCyclePolicy {
+		policy, err := o.DesiredLifecyclePolicy()
+		if err != nil {
+			return err
+		}
+		if policy != "" {
+			err = ValidateLifecyclePolicy(policy)
+			if err != nil {
+				return fmt.Errorf("invalid ECR lifecycle policy:\n%w", err)
+			}
+		}
+	}
+
+	tags, err := o.ResourceTags(appName)
+	if err != nil {
+		return err
+	}
 	log.Logger().Infof("Let's ensure that we have an ECR repository for the image %s", termcolor.ColorInfo(repoName))
 
-	if o.ECRClient == nil {
-		o.ECRClient = ecr.NewFromConfig(*cfg)
+	svc, err := o.GetECRClient()
+	if err != nil {
+		return err
 	}
-	svc := o.ECRClient
+	registryID := o.registryIDPointer()
 
 	repoInput := &ecr.DescribeRepositoriesInput{
 		RepositoryNames: []string{repoName},
-	}
-	if o.RegistryID != "" {
-		repoInput.RegistryId = &o.RegistryID
+		RegistryId:      registryID,
 	}
 	result, err := svc.DescribeRepositories(ctx, repoInput)
 	if err != nil {
 		var notFoundErr *types.RepositoryNotFoundException
 		if !errors.As(err, &notFoundErr) {
-			return fmt.Errorf("failed to check for repository with registry ID %s: %w", o.RegistryID, err)
+			return fmt.Errorf("failed to check for repository with registry ID %s: %w", o.GetRegistryID(), err)
 		}
 	}
 	if result != nil {
@@ -167,12 +379,27 @@ func (o *Options) LazyCreateRegistry(appName string) error {
 			name := *repo.RepositoryName
 			log.Logger().Infof("Found repository: %s", name)
 			if name == repoName {
+				err = o.EnsureRepositorySettings(&repo)
+				if err != nil {
+					return err
+				}
+				err = o.EnsureTags(&repo, tags)
+				if err != nil {
+					return err
+				}
 				return o.EnsureLifecyclePolicy(repoName)
 			}
 		}
 	}
 	createRepoInput := &ecr.CreateRepositoryInput{
 		RepositoryName: aws.String(repoName),
+		RegistryId:     registryID,
+		Tags:           ToECRTags(tags),
+	}
+	o.applyRepositorySettings(createRepoInput)
+	if o.DryRun {
+		log.Logger().Infof("would create ECR repository %s%s", termcolor.ColorInfo(repoName), describeCreateRepository(createRepoInput))
+		return o.EnsureLifecyclePolicy(repoName)
 	}
 	createResult, err := svc.CreateRepository(ctx, createRepoInput)
 	if err != nil {
@@ -190,95 +417,304 @@ func (o *Options) LazyCreateRegistry(appName string) error {
 
 func (o *Options) EnsureLifecyclePolicy(repoName string) error {
 	if o.CreateECRLifeCyclePolicy {
-		client := o.ECRClient
+		client, err := o.GetECRClient()
+		if err != nil {
+			return err
+		}
 		ctx := o.GetContext()
 
+		policy, err := o.DesiredLifecyclePolicy()
+		if err != nil {
+			return err
+		}
+
 		getLifecyclePolicyInput := &ecr.GetLifecyclePolicyInput{
 			RepositoryName: aws.String(repoName),
-		}
-		if o.RegistryID != "" {
-			getLifecyclePolicyInput.RegistryId = &o.RegistryID
+			RegistryId:     o.registryIDPointer(),
 		}
 		getLifecyclePolicyOutput, err := client.GetLifecyclePolicy(ctx, getLifecyclePolicyInput)
-		if err == nil && o.ECRLifecyclePolicy == "" {
+		if err == nil && policy == "" {
 			// Won't overwrite existing lifecycle policy if no policy has been specified
-			return nil
+			return o.ensureRequestedRepositoryPolicy(repoName)
 		}
 		if err != nil {
 			var notFoundErr *types.LifecyclePolicyNotFoundException
-			if !errors.As(err, &notFoundErr) {
+			if !errors.As(err, &notFoundErr) && !o.isDryRunMissingRepository(err) {
 				// LifecyclePolicyNotFoundException is OK since we then create it below
 				return fmt.Errorf("Failed to fetch lifecycle policy for the ECR repository %s due to: %s",
 					repoName, err)
 			}
 		}
-		if o.ECRLifecyclePolicy == "" {
-			o.ECRLifecyclePolicy = defaultECRLifecyclePolicy
+		found := err == nil
+		if policy == "" {
+			policy, err = o.defaultLifecyclePolicy()
+			if err != nil {
+				return err
+			}
 		}
-		if err == nil && o.ECRLifecyclePolicy == *getLifecyclePolicyOutput.LifecyclePolicyText {
-			// No need to put policy if it already set. I'm not sure
-			return nil
+		if found {
+			policy, err = MergeLifecyclePolicies(aws.ToString(getLifecyclePolicyOutput.LifecyclePolicyText), policy, o.LifecyclePolicyMode)
+			if err != nil {
+				return fmt.Errorf("failed to merge lifecycle policies for the ECR repository %s: %w", repoName, err)
+			}
+			err = ValidateLifecyclePolicy(policy)
+			if err != nil {
+				return fmt.Errorf("invalid merged lifecycle policy for the ECR repository %s:\n%w", repoName, err)
+			}
+			equal, err := LifecyclePoliciesEqual(policy, aws.ToString(getLifecyclePolicyOutput.LifecyclePolicyText))
+			if err != nil {
+				return fmt.Errorf("failed to compare lifecycle policies for the ECR repository %s: %w", repoName, err)
+			}
+			if equal {
+				// No need to put policy if it already set
+				log.Logger().Debugf("lifecycle policy for the ECR repository %s is up to date", repoName)
+				return o.ensureRequestedRepositoryPolicy(repoName)
+			}
+		}
+		if o.DryRun {
+			current := ""
+			if found {
+				current = aws.ToString(getLifecyclePolicyOutput.LifecyclePolicyText)
+			}
+			log.Logger().Infof("would put lifecycle policy for ECR repository %s:\n%s", termcolor.ColorInfo(repoName), PolicyDiff(current, policy))
+			return o.EnsureRepositoryPolicy(repoName)
 		}
 		putLifecyclePolicyInput := &ecr.PutLifecyclePolicyInput{
-			LifecyclePolicyText: aws.String(o.ECRLifecyclePolicy),
+			LifecyclePolicyText: aws.String(policy),
 			RepositoryName:      aws.String(repoName),
-		}
-		if o.RegistryID != "" {
-			putLifecyclePolicyInput.RegistryId = &o.RegistryID
+			RegistryId:          o.registryIDPointer(),
 		}
 		putLifecyclePolicyOutput, err := client.PutLifecyclePolicy(ctx, putLifecyclePolicyInput)
 		if err != nil {
 			return fmt.Errorf("Failed to put lifecycle policy '%s' for the ECR repository %s due to: %s",
-				o.ECRLifecyclePolicy, repoName, err)
+				policy, repoName, err)
 		}
 		log.Logger().Infof("Put ECR repository lifecycle policy: %s", termcolor.ColorInfo(*putLifecyclePolicyOutput.LifecyclePolicyText))
 	}
 	return o.EnsureRepositoryPolicy(repoName)
 }
 
+// ensureRequestedRepositoryPolicy ensures the repository policy if one has been requested when the lifecycle policy
+// of the repository is left unchanged, so that the default repository policy is only put alongside a new lifecycle policy
+func (o *Options) ensureRequestedRepositoryPolicy(repoName string) error {
+	policy, err := o.DesiredRepositoryPolicy("")
+	if err != nil {
+		return err
+	}
+	if policy == "" {
+		return nil
+	}
+	return o.EnsureRepositoryPolicy(repoName)
+}
+
+// CacheOptions returns the options for a cache repository. The cache lifecycle policy is only used as the desired
+// policy if it has been specified, otherwise the default cache lifecycle policy is used if the cache repository does
+// not have a lifecycle policy yet
+func (o *Options) CacheOptions() *Options {
+	co := *o
+	co.ECRLifecyclePolicy = o.ECRCacheLifecyclePolicy
+	co.ExpireUntaggedAfterDays = 0
+	co.ExpireTagPrefixes = nil
+	co.KeepLastNTagged = 0
+	co.cacheRepository = true
+	return &co
+}
+
+// defaultLifecyclePolicy returns the lifecycle policy for a repository which does not have one yet. Unless this is a
+// cache repository the central policy of the development environment is used if there is one
+func (o *Options) defaultLifecyclePolicy() (string, error) {
+	if o.cacheRepository {
+		return DefaultCacheLifecyclePolicy().ToJSON()
+	}
+	policy, err := o.findCentralPolicy(requirements.LifecyclePolicyKey)
+	if err != nil || policy != "" {
+		return policy, err
+	}
+	return DefaultLifecyclePolicy().ToJSON()
+}
+
+// findCentralPolicy lazily finds the central policy so that the cluster and dev environment git repository are only
+// accessed when a repository is missing a policy
+func (o *Options) findCentralPolicy(key string) (string, error) {
+	if o.PolicyFinder == nil {
+		return "", nil
+	}
+	policy, err := o.PolicyFinder.FindPolicy(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the central %s: %w", key, err)
+	}
+	return policy, nil
+}
+
+// LoadPolicyFiles loads the lifecycle and repository policies from the policy files if specified. A policy which was
+// previously loaded from a file is replaced so that the options can be reused
+func (o *Options) LoadPolicyFiles() error {
+	var err error
+	o.ECRLifecyclePolicy, err = o.loadPolicyFile(o.ECRLifecyclePolicy, o.ECRLifecyclePolicyFile, "ecr-lifecycle-policy")
+	if err != nil {
+		return err
+	}
+	o.ECRRepositoryPolicy, err = o.loadPolicyFile(o.ECRRepositoryPolicy, o.ECRRepositoryPolicyFile, "ecr-repository-policy")
+	return err
+}
+
+func (o *Options) loadPolicyFile(policy, file, flag string) (string, error) {
+	if file == "" {
+		return policy, nil
+	}
+	if policy != "" && policy != o.loadedPolicies[flag] {
+		return "", fmt.Errorf("cannot specify both --%s and --%s-file", flag, flag)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read policy file %s: %w", file, err)
+	}
+	if o.loadedPolicies == nil {
+		o.loadedPolicies = map[string]string{}
+	}
+	o.loadedPolicies[flag] = string(data)
+	return string(data), nil
+}
+
+// DesiredLifecyclePolicy returns the lifecycle policy which has been specified explicitly or generated from
+// the expiry settings. An empty string is returned if no policy has been specified
+func (o *Options) DesiredLifecyclePolicy() (string, error) {
+	builder := o.LifecyclePolicyBuilder()
+	if builder.IsEmpty() {
+		return o.ECRLifecyclePolicy, nil
+	}
+	if o.ECRLifecyclePolicy != "" {
+		return "", fmt.Errorf("cannot specify both a lifecycle policy and the lifecycle policy expiry flags")
+	}
+	policy, err := builder.Build()
+	if err != nil {
+		return "", fmt.Errorf("failed to build lifecycle policy: %w", err)
+	}
+	return policy.ToJSON()
+}
+
 func (o *Options) EnsureRepositoryPolicy(repoName string) error {
-	if !o.CreateECRRepositoryPolicy {
+	if !o.CreateECRRepositoryPolicy && o.RepositoryPolicyBuilder().IsEmpty() {
 		return nil
 	}
-	client := o.ECRClient
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
 	ctx := o.GetContext()
 	getRepositoryPolicyInput := &ecr.GetRepositoryPolicyInput{
 		RepositoryName: aws.String(repoName),
-	}
-	if o.RegistryID != "" {
-		getRepositoryPolicyInput.RegistryId = &o.RegistryID
+		RegistryId:     o.registryIDPointer(),
 	}
 	getRepositoryPolicyOutput, err := client.GetRepositoryPolicy(ctx, getRepositoryPolicyInput)
-	if err == nil && o.ECRRepositoryPolicy == "" {
-		// Won't overwrite existing Repository policy if no policy has been specified
-		return nil
-	}
+	found := err == nil
 	if err != nil {
 		var notFoundErr *types.RepositoryPolicyNotFoundException
-		if !errors.As(err, &notFoundErr) {
+		if !errors.As(err, &notFoundErr) && !o.isDryRunMissingRepository(err) {
 			return fmt.Errorf("Failed to fetch lifecycle policy for the ECR repository %s due to: %s",
 				repoName, err)
 		}
 	}
-	if o.ECRRepositoryPolicy == "" {
-		o.ECRRepositoryPolicy = defaultECRRepositoryPolicy
+	current := ""
+	if found {
+		current = aws.ToString(getRepositoryPolicyOutput.PolicyText)
 	}
-	if err == nil && o.ECRRepositoryPolicy == *getRepositoryPolicyOutput.PolicyText {
-		// No need to put policy if it already set. I'm not sure
+	policy, err := o.DesiredRepositoryPolicy(current)
+	if err != nil {
+		return err
+	}
+	if found && policy == "" {
+		// Won't overwrite existing Repository policy if no policy has been specified
+		return nil
+	}
+	if policy == "" {
+		policy, err = o.findCentralPolicy(requirements.RepositoryPolicyKey)
+		if err != nil {
+			return err
+		}
+	}
+	if policy == "" {
+		policy = defaultECRRepositoryPolicy
+	}
+	if found {
+		equal, err := RepositoryPoliciesEqual(policy, current)
+		if err != nil {
+			return fmt.Errorf("failed to compare repository policies for the ECR repository %s: %w", repoName, err)
+		}
+		if equal {
+			// No need to put policy if it already set
+			log.Logger().Debugf("repository policy for the ECR repository %s is up to date", repoName)
+			return nil
+		}
+	}
+	if o.DryRun {
+		log.Logger().Infof("would set repository policy for ECR repository %s:\n%s", termcolor.ColorInfo(repoName), PolicyDiff(current, policy))
 		return nil
 	}
 	setRepositoryPolicyInput := &ecr.SetRepositoryPolicyInput{
-		PolicyText:     aws.String(o.ECRRepositoryPolicy),
+		PolicyText:     aws.String(policy),
 		RepositoryName: aws.String(repoName),
-	}
-	if o.RegistryID != "" {
-		setRepositoryPolicyInput.RegistryId = &o.RegistryID
+		RegistryId:     o.registryIDPointer(),
 	}
 	setRegistryPolicyOutput, err := client.SetRepositoryPolicy(ctx, setRepositoryPolicyInput)
 	if err != nil {
 		return fmt.Errorf("Failed to set repository policy '%s' for the ECR repository %s due to: %s",
-			o.ECRRepositoryPolicy, repoName, err)
+			policy, repoName, err)
 	}
 	log.Logger().Infof("Put ECR repository repository policy: %s", termcolor.ColorInfo(*setRegistryPolicyOutput.PolicyText))
 	return nil
 }
+
+// DesiredRepositoryPolicy returns the repository policy which has been specified explicitly, merged with the current
+// policy using the repository policy mode, with any statements generated from the allow flags merged into it.
+// If only the allow flags are specified the statements are merged into the current policy of the repository.
+// An empty string is returned if no policy has been specified
+func (o *Options) DesiredRepositoryPolicy(current string) (string, error) {
+	policy := o.ECRRepositoryPolicy
+	if policy != "" {
+		var err error
+		policy, err = MergeRepositoryPolicies(current, policy, o.RepositoryPolicyMode)
+		if err != nil {
+			return "", fmt.Errorf("failed to merge repository policies: %w", err)
+		}
+	}
+	builder := o.RepositoryPolicyBuilder()
+	if builder.IsEmpty() {
+		return policy, nil
+	}
+	if policy == "" {
+		policy = current
+	}
+	answer, err := builder.Merge(policy)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate repository policy: %w", err)
+	}
+	return answer, nil
+}
+
+// RepositoryPolicyBuilder returns the builder of the repository policy statements from the allow flags
+func (o *Options) RepositoryPolicyBuilder() *RepositoryPolicyBuilder {
+	return &RepositoryPolicyBuilder{
+		AllowPullAccounts: o.AllowPullAccounts,
+		AllowPushRoleARNs: o.AllowPushRoleARNs,
+		AllowPullOrgIDs:   o.AllowPullOrgIDs,
+	}
+}
+
+// LifecyclePolicyBuilder returns the builder for the lifecycle policy expiry settings
+func (o *Options) LifecyclePolicyBuilder() *LifecyclePolicyBuilder {
+	return &LifecyclePolicyBuilder{
+		ExpireUntaggedAfterDays: o.ExpireUntaggedAfterDays,
+		ExpireTagPrefixes:       o.ExpireTagPrefixes,
+		KeepLastNTagged:         o.KeepLastNTagged,
+	}
+}
+
+// registryIDPointer returns the registry ID to pass to the ECR API or nil to use the default registry
+func (o *Options) registryIDPointer() *string {
+	registryID := o.GetRegistryID()
+	if registryID == "" {
+		return nil
+	}
+	return aws.String(registryID)
+}
@@ -0,0 +1,413 @@
+package ecrs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/fakests"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRegistryID(t *testing.T) {
+	testCases := []struct {
+		registryID string
+		registry   string
+		expected   string
+	}{
+		{
+			registryID: "111111111111",
+			registry:   "222222222222.dkr.ecr.us-east-1.amazonaws.com",
+			expected:   "111111111111",
+		},
+		{
+			registry: "222222222222.dkr.ecr.us-east-1.amazonaws.com",
+			expected: "222222222222",
+		},
+		{
+			registry: "ecr.io",
+		},
+		{},
+	}
+	for _, tc := range testCases {
+		o := &ecrs.Options{
+			RegistryID: tc.registryID,
+			Registry:   tc.registry,
+		}
+		assert.Equal(t, tc.expected, o.GetRegistryID(), "for registry ID '%s' and registry '%s'", tc.registryID, tc.registry)
+	}
+}
+
+func TestCrossAccountRegistryWithoutRole(t *testing.T) {
+	o := &ecrs.Options{
+		RegistryID: "222222222222",
+	}
+	o.Config = &aws.Config{Region: "us-east-1"}
+	fakeSTS := fakests.NewFakeSTS("111111111111")
+	o.STSClient = fakeSTS
+
+	client, err := o.GetECRClient()
+	require.NoError(t, err, "failed to create the ECR client")
+	require.IsType(t, &ecr.Client{}, client)
+	assert.Nil(t, client.(*ecr.Client).Options().Credentials, "should use the caller credentials")
+	assert.Equal(t, 0, fakeSTS.Calls, "should not have called STS without a registry role")
+}
+
+func TestCrossAccountRegistryAssumesRole(t *testing.T) {
+	o := &ecrs.Options{
+		Registry: "222222222222.dkr.ecr.us-east-1.amazonaws.com",
+		RegistryRoles: map[string]string{
+			"222222222222": "arn:aws:iam::222222222222:role/jx-registry",
+		},
+	}
+	o.Config = &aws.Config{Region: "us-east-1"}
+	o.STSClient = fakests.NewFakeSTS("111111111111")
+
+	client, err := o.GetECRClient()
+	require.NoError(t, err, "failed to create the ECR client")
+	require.IsType(t, &ecr.Client{}, client)
+	assert.IsType(t, &aws.CredentialsCache{}, client.(*ecr.Client).Options().Credentials, "should use the assumed role credentials")
+}
+
+func TestLazyCreateRegistryUsesRegistryID(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		Registry:             "222222222222.dkr.ecr.us-east-1.amazonaws.com",
+		RegistryOrganisation: "myorg",
+		ECRClient:            fakeECR,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+
+	repo := fakeECR.Repositories["myorg/myapp"]
+	require.NotNil(t, repo, "should have created the repository")
+	assert.Equal(t, "222222222222", aws.ToString(repo.RegistryId))
+}
+
+func TestLazyCreateRegistryRepositorySettings(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                    fakeECR,
+		ImageTagMutability:           "immutable_with_exclusion",
+		ImageTagMutabilityExclusions: []string{"latest"},
+		ScanOnPush:                   "true",
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+
+	repo := fakeECR.Repositories["myapp"]
+	require.NotNil(t, repo, "should have created the repository")
+	assert.Equal(t, types.ImageTagMutabilityImmutableWithExclusion, repo.ImageTagMutability)
+	require.Len(t, repo.ImageTagMutabilityExclusionFilters, 1)
+	assert.Equal(t, "latest", aws.ToString(repo.ImageTagMutabilityExclusionFilters[0].Filter))
+	assert.True(t, repo.ImageScanningConfiguration.ScanOnPush, "should scan on push")
+
+	// lets reconcile the existing repository
+	o.ImageTagMutability = "IMMUTABLE"
+	o.ImageTagMutabilityExclusions = nil
+	o.ScanOnPush = "false"
+
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+
+	assert.Equal(t, types.ImageTagMutabilityImmutable, repo.ImageTagMutability)
+	assert.Empty(t, repo.ImageTagMutabilityExclusionFilters)
+	assert.False(t, repo.ImageScanningConfiguration.ScanOnPush, "should not scan on push")
+}
+
+func TestExistingLifecyclePolicyOnlyEnsuresRequestedRepositoryPolicy(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                 fakeECR,
+		CreateECRLifeCyclePolicy:  true,
+		CreateECRRepositoryPolicy: true,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	require.NotEmpty(t, fakeECR.LifecyclePolicies["myapp"], "should have put the lifecycle policy")
+	delete(fakeECR.RepositoryPolicies, "myapp")
+
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Empty(t, fakeECR.RepositoryPolicies["myapp"], "should not put the default repository policy when the lifecycle policy exists")
+
+	o.AllowPullAccounts = []string{"111111111111"}
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Contains(t, fakeECR.RepositoryPolicies["myapp"], "arn:aws:iam::111111111111:root", "should put the requested repository policy")
+}
+
+func TestLoadPolicyFilesIsRepeatable(t *testing.T) {
+	policy := `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}}]}`
+	file := filepath.Join(t.TempDir(), "lifecycle-policy.json")
+	require.NoError(t, os.WriteFile(file, []byte(policy), 0o600), "failed to write file")
+
+	o := &ecrs.Options{
+		ECRLifecyclePolicyFile: file,
+	}
+	for i := 0; i < 2; i++ {
+		err := o.LoadPolicyFiles()
+		require.NoError(t, err, "failed to load policy files on call %d", i+1)
+		assert.Equal(t, policy, o.ECRLifecyclePolicy)
+	}
+
+	o.ECRLifecyclePolicy = `{"rules": []}`
+	err := o.LoadPolicyFiles()
+	require.Error(t, err, "should fail when both a policy and a policy file are specified")
+}
+
+func TestExistingLifecyclePolicyEnsuresRepositoryPolicyFromRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(file, []byte(`rules:
+- glob: myapp
+  repositoryPolicy: |
+    {"Version": "2012-10-17", "Statement": [{"Sid": "Pull", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::222222222222:root"}, "Action": "ecr:BatchGetImage"}]}
+`), 0o600)
+	require.NoError(t, err, "failed to write file")
+
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                fakeECR,
+		CreateECRLifeCyclePolicy: true,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	require.NotEmpty(t, fakeECR.LifecyclePolicies["myapp"], "should have put the lifecycle policy")
+	assert.Empty(t, fakeECR.RepositoryPolicies["myapp"], "should not put a repository policy")
+
+	o.RepositoryRulesFile = file
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Contains(t, fakeECR.RepositoryPolicies["myapp"], "arn:aws:iam::222222222222:root", "should put the repository policy of the rule")
+}
+
+func TestUpToDateLifecyclePolicyOnlyEnsuresRequestedRepositoryPolicy(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                 fakeECR,
+		CreateECRLifeCyclePolicy:  true,
+		CreateECRRepositoryPolicy: true,
+		ECRLifecyclePolicy:        `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}]}`,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	require.NotEmpty(t, fakeECR.RepositoryPolicies["myapp"], "should have put the default repository policy")
+	delete(fakeECR.RepositoryPolicies, "myapp")
+
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Empty(t, fakeECR.RepositoryPolicies["myapp"], "should not put the default repository policy when the lifecycle policy is up to date")
+
+	o.AllowPullAccounts = []string{"111111111111"}
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Contains(t, fakeECR.RepositoryPolicies["myapp"], "arn:aws:iam::111111111111:root", "should put the requested repository policy")
+}
+
+type fakePolicyFinder struct {
+	policies map[string]string
+	keys     []string
+}
+
+func (f *fakePolicyFinder) FindPolicy(key string) (string, error) {
+	f.keys = append(f.keys, key)
+	return f.policies[key], nil
+}
+
+func TestCentralPolicyOnlyFoundForMissingPolicies(t *testing.T) {
+	centralPolicy := `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 7}, "action": {"type": "expire"}}]}`
+	finder := &fakePolicyFinder{
+		policies: map[string]string{
+			requirements.LifecyclePolicyKey: centralPolicy,
+		},
+	}
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                fakeECR,
+		CreateECRLifeCyclePolicy: true,
+		PolicyFinder:             finder,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Equal(t, centralPolicy, fakeECR.LifecyclePolicies["myapp"], "should use the central lifecycle policy for a new repository")
+	assert.Equal(t, []string{requirements.LifecyclePolicyKey}, finder.keys, "should have looked up the central lifecycle policy")
+
+	finder.keys = nil
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Empty(t, finder.keys, "should not look up central policies when the repository has a lifecycle policy")
+}
+
+func TestInvalidRepositorySettings(t *testing.T) {
+	testCases := []ecrs.Options{
+		{ImageTagMutability: "SOMETIMES"},
+		{ImageTagMutability: "IMMUTABLE_WITH_EXCLUSION"},
+		{ImageTagMutability: "IMMUTABLE", ImageTagMutabilityExclusions: []string{"latest"}},
+		{ScanOnPush: "maybe"},
+	}
+	for i := range testCases {
+		o := &testCases[i]
+		err := o.ValidateRepositorySettings()
+		assert.Error(t, err, "should fail for mutability '%s' exclusions %v scan on push '%s'", o.ImageTagMutability, o.ImageTagMutabilityExclusions, o.ScanOnPush)
+	}
+}
+
+func TestLazyCreateRegistryWithKMSKey(t *testing.T) {
+	keyARN := "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient: fakeECR,
+		KMSKey:    keyARN,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+
+	repo := fakeECR.Repositories["myapp"]
+	require.NotNil(t, repo, "should have created the repository")
+	require.NotNil(t, repo.EncryptionConfiguration, "should have an encryption configuration")
+	assert.Equal(t, types.EncryptionTypeKms, repo.EncryptionConfiguration.EncryptionType)
+	assert.Equal(t, keyARN, aws.ToString(repo.EncryptionConfiguration.KmsKey))
+	assert.Empty(t, o.EncryptionDrift(repo), "should have no encryption drift")
+}
+
+func TestEncryptionDrift(t *testing.T) {
+	keyARN := "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	kmsRepo := &types.Repository{
+		RepositoryName: aws.String("myapp"),
+		EncryptionConfiguration: &types.EncryptionConfiguration{
+			EncryptionType: types.EncryptionTypeKms,
+			KmsKey:         aws.String(keyARN),
+		},
+	}
+	aesRepo := &types.Repository{
+		RepositoryName: aws.String("myapp"),
+	}
+	testCases := []struct {
+		encryptionType string
+		kmsKey         string
+		repo           *types.Repository
+		drift          bool
+	}{
+		{repo: aesRepo},
+		{encryptionType: "AES256", repo: aesRepo},
+		{encryptionType: "KMS", repo: aesRepo, drift: true},
+		{encryptionType: "AES256", repo: kmsRepo, drift: true},
+		{encryptionType: "KMS", repo: kmsRepo},
+		{encryptionType: "KMS", kmsKey: keyARN, repo: kmsRepo},
+		{encryptionType: "KMS", kmsKey: "1234abcd-12ab-34cd-56ef-1234567890ab", repo: kmsRepo},
+		{encryptionType: "KMS", kmsKey: "alias/ecr", repo: kmsRepo},
+		{encryptionType: "KMS", kmsKey: "arn:aws:kms:us-east-1:123456789012:key/other", repo: kmsRepo, drift: true},
+	}
+	for _, tc := range testCases {
+		o := &ecrs.Options{
+			EncryptionType: tc.encryptionType,
+			KMSKey:         tc.kmsKey,
+		}
+		drift := o.EncryptionDrift(tc.repo)
+		if tc.drift {
+			assert.NotEmpty(t, drift, "should have drift for type '%s' key '%s'", tc.encryptionType, tc.kmsKey)
+		} else {
+			assert.Empty(t, drift, "should not have drift for type '%s' key '%s'", tc.encryptionType, tc.kmsKey)
+		}
+	}
+}
+
+func TestLazyCreateRegistryTags(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:            fakeECR,
+		RegistryOrganisation: "myorg",
+		AutoTags:             true,
+		Owner:                "myowner",
+		Repository:           "myrepo",
+		ClusterName:          "mycluster",
+		Tags:                 []string{"cost-centre=platform"},
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	err := o.LazyCreateRegistry("myapp:1.2.3")
+	require.NoError(t, err, "failed to lazy create registry")
+
+	repo := fakeECR.Repositories["myorg/myapp"]
+	require.NotNil(t, repo, "should have created the repository")
+	arn := aws.ToString(repo.RepositoryArn)
+	assert.Equal(t, map[string]string{
+		ecrs.TagOwner:      "myowner",
+		ecrs.TagRepository: "myrepo",
+		ecrs.TagApp:        "myapp",
+		ecrs.TagCluster:    "mycluster",
+		"cost-centre":      "platform",
+	}, fakeECR.Tags[arn])
+
+	// lets reconcile the tags on the existing repository
+	fakeECR.Tags[arn]["team"] = "someone-else"
+	fakeECR.Tags[arn][ecrs.TagPrefix+"other"] = "other-tool"
+	o.ClusterName = ""
+	o.Tags = []string{"cost-centre=apps"}
+
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+
+	assert.Equal(t, map[string]string{
+		ecrs.TagOwner:            "myowner",
+		ecrs.TagRepository:       "myrepo",
+		ecrs.TagApp:              "myapp",
+		ecrs.TagCluster:          "mycluster",
+		ecrs.TagPrefix + "other": "other-tool",
+		"cost-centre":            "apps",
+		"team":                   "someone-else",
+	}, fakeECR.Tags[arn], "should not remove tags without a value in this run")
+
+	// lets remove an automatic tag explicitly which does nothing when automatic tagging is disabled
+	o.Tags = []string{ecrs.TagCluster + "="}
+	o.AutoTags = false
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.Equal(t, "mycluster", fakeECR.Tags[arn][ecrs.TagCluster], "should not remove tags when automatic tagging is disabled")
+
+	o.AutoTags = true
+	err = o.LazyCreateRegistry("myapp")
+	require.NoError(t, err, "failed to lazy create registry")
+	assert.NotContains(t, fakeECR.Tags[arn], ecrs.TagCluster, "should have removed the cluster tag")
+	assert.Equal(t, "other-tool", fakeECR.Tags[arn][ecrs.TagPrefix+"other"])
+}
+
+func TestPolicyDiff(t *testing.T) {
+	diff := ecrs.PolicyDiff(`{"rules": [{"rulePriority": 1}]}`, `{"rules": [{"rulePriority": 2}]}`)
+	assert.Contains(t, diff, "--- current")
+	assert.Contains(t, diff, "+++ desired")
+	assert.Contains(t, diff, `-      "rulePriority": 1`)
+	assert.Contains(t, diff, `+      "rulePriority": 2`)
+
+	diff = ecrs.PolicyDiff("", `{"rules": []}`)
+	assert.Contains(t, diff, `+  "rules": []`)
+}
@@ -3,8 +3,10 @@ package fakeecr
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	"github.com/aws/smithy-go/middleware"
@@ -12,17 +14,41 @@ import (
 
 // FakeECR a fake ECR implementation for testing
 type FakeECR struct {
-	Region       string
-	Repositories map[string]*types.Repository
+	Region             string
+	Repositories       map[string]*types.Repository
+	Images             map[string][]types.ImageIdentifier
+	Tags               map[string]map[string]string
+	LifecyclePolicies  map[string]string
+	RepositoryPolicies map[string]string
+	Replication        *types.ReplicationConfiguration
+	PullThroughCache   map[string]*types.PullThroughCacheRule
+	Scanning           *types.RegistryScanningConfiguration
+
+	// ScanFindings the findings of each image keyed by repository:tag or repository@digest
+	ScanFindings map[string]*types.ImageScanFindings
+
+	// ScanInProgressCalls the number of calls for which image scans are in progress
+	ScanInProgressCalls int
 }
 
-func (f *FakeECR) GetLifecyclePolicy(_ context.Context, _ *ecr.GetLifecyclePolicyInput, _ ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
-	return nil, &types.LifecyclePolicyNotFoundException{}
+func (f *FakeECR) GetLifecyclePolicy(_ context.Context, params *ecr.GetLifecyclePolicyInput, _ ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	name := aws.ToString(params.RepositoryName)
+	text, ok := f.LifecyclePolicies[name]
+	if !ok {
+		return nil, &types.LifecyclePolicyNotFoundException{}
+	}
+	return &ecr.GetLifecyclePolicyOutput{
+		LifecyclePolicyText: aws.String(text),
+		RegistryId:          params.RegistryId,
+		RepositoryName:      params.RepositoryName,
+		ResultMetadata:      middleware.Metadata{},
+	}, nil
 }
 
 func (f *FakeECR) PutLifecyclePolicy(_ context.Context, params *ecr.PutLifecyclePolicyInput, _ ...func(*ecr.Options)) (*ecr.PutLifecyclePolicyOutput, error) {
 	repo := f.createRepo(*params.RepositoryName)
-	text := "default"
+	text := aws.ToString(params.LifecyclePolicyText)
+	f.LifecyclePolicies[*params.RepositoryName] = text
 	return &ecr.PutLifecyclePolicyOutput{
 		LifecyclePolicyText: &text,
 		RegistryId:          repo.RegistryId,
@@ -31,13 +57,24 @@ func (f *FakeECR) PutLifecyclePolicy(_ context.Context, params *ecr.PutLifecycle
 	}, nil
 }
 
-func (f *FakeECR) GetRepositoryPolicy(_ context.Context, _ *ecr.GetRepositoryPolicyInput, _ ...func(*ecr.Options)) (*ecr.GetRepositoryPolicyOutput, error) {
-	return nil, &types.RepositoryPolicyNotFoundException{}
+func (f *FakeECR) GetRepositoryPolicy(_ context.Context, params *ecr.GetRepositoryPolicyInput, _ ...func(*ecr.Options)) (*ecr.GetRepositoryPolicyOutput, error) {
+	name := aws.ToString(params.RepositoryName)
+	text, ok := f.RepositoryPolicies[name]
+	if !ok {
+		return nil, &types.RepositoryPolicyNotFoundException{}
+	}
+	return &ecr.GetRepositoryPolicyOutput{
+		PolicyText:     aws.String(text),
+		RegistryId:     params.RegistryId,
+		RepositoryName: params.RepositoryName,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
 }
 
 func (f *FakeECR) SetRepositoryPolicy(_ context.Context, params *ecr.SetRepositoryPolicyInput, _ ...func(*ecr.Options)) (*ecr.SetRepositoryPolicyOutput, error) {
 	repo := f.createRepo(*params.RepositoryName)
-	text := "default"
+	text := aws.ToString(params.PolicyText)
+	f.RepositoryPolicies[*params.RepositoryName] = text
 	return &ecr.SetRepositoryPolicyOutput{
 		PolicyText:     &text,
 		RegistryId:     repo.RegistryId,
@@ -48,12 +85,23 @@ func (f *FakeECR) SetRepositoryPolicy(_ context.Context, params *ecr.SetReposito
 
 func (f *FakeECR) DescribeRepositories(_ context.Context, input *ecr.DescribeRepositoriesInput, _ ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
 	var repos []types.Repository
+	if input != nil && len(input.RepositoryNames) == 0 {
+		names := make([]string, 0, len(f.Repositories))
+		for name := range f.Repositories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			repos = append(repos, *f.Repositories[name])
+		}
+	}
 	if input != nil && f.Repositories != nil {
 		for _, name := range input.RepositoryNames {
 			r := f.Repositories[name]
-			if r != nil {
-				repos = append(repos, *r)
+			if r == nil {
+				return nil, &types.RepositoryNotFoundException{Message: aws.String("repository " + name + " not found")}
 			}
+			repos = append(repos, *r)
 		}
 	}
 	return &ecr.DescribeRepositoriesOutput{
@@ -72,7 +120,26 @@ func (f *FakeECR) CreateRepository(_ context.Context, params *ecr.CreateReposito
 	}
 
 	repo := f.createRepo(name)
+	if params.RegistryId != nil {
+		repo = f.createRepoInRegistry(name, *params.RegistryId)
+	}
+	repo.ImageTagMutability = params.ImageTagMutability
+	if repo.ImageTagMutability == "" {
+		repo.ImageTagMutability = types.ImageTagMutabilityMutable
+	}
+	repo.ImageTagMutabilityExclusionFilters = params.ImageTagMutabilityExclusionFilters
+	repo.ImageScanningConfiguration = params.ImageScanningConfiguration
+	if repo.ImageScanningConfiguration == nil {
+		repo.ImageScanningConfiguration = &types.ImageScanningConfiguration{}
+	}
+	repo.EncryptionConfiguration = params.EncryptionConfiguration
+	if repo.EncryptionConfiguration == nil {
+		repo.EncryptionConfiguration = &types.EncryptionConfiguration{
+			EncryptionType: types.EncryptionTypeAes256,
+		}
+	}
 	f.Repositories[name] = repo
+	f.tagRepo(*repo.RepositoryArn, params.Tags)
 
 	return &ecr.CreateRepositoryOutput{
 		Repository:     repo,
@@ -80,12 +147,246 @@ func (f *FakeECR) CreateRepository(_ context.Context, params *ecr.CreateReposito
 	}, nil
 }
 
+func (f *FakeECR) PutImageTagMutability(_ context.Context, params *ecr.PutImageTagMutabilityInput, _ ...func(*ecr.Options)) (*ecr.PutImageTagMutabilityOutput, error) {
+	repo, err := f.getRepo(params.RepositoryName)
+	if err != nil {
+		return nil, err
+	}
+	repo.ImageTagMutability = params.ImageTagMutability
+	repo.ImageTagMutabilityExclusionFilters = params.ImageTagMutabilityExclusionFilters
+	return &ecr.PutImageTagMutabilityOutput{
+		ImageTagMutability:                 repo.ImageTagMutability,
+		ImageTagMutabilityExclusionFilters: repo.ImageTagMutabilityExclusionFilters,
+		RegistryId:                         repo.RegistryId,
+		RepositoryName:                     repo.RepositoryName,
+		ResultMetadata:                     middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) PutImageScanningConfiguration(_ context.Context, params *ecr.PutImageScanningConfigurationInput, _ ...func(*ecr.Options)) (*ecr.PutImageScanningConfigurationOutput, error) {
+	repo, err := f.getRepo(params.RepositoryName)
+	if err != nil {
+		return nil, err
+	}
+	repo.ImageScanningConfiguration = params.ImageScanningConfiguration
+	return &ecr.PutImageScanningConfigurationOutput{
+		ImageScanningConfiguration: repo.ImageScanningConfiguration,
+		RegistryId:                 repo.RegistryId,
+		RepositoryName:             repo.RepositoryName,
+		ResultMetadata:             middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) ListImages(_ context.Context, params *ecr.ListImagesInput, _ ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	repo, err := f.getRepo(params.RepositoryName)
+	if err != nil {
+		return nil, err
+	}
+	images := f.Images[*repo.RepositoryName]
+	if params.MaxResults != nil && len(images) > int(*params.MaxResults) {
+		images = images[0:*params.MaxResults]
+	}
+	return &ecr.ListImagesOutput{
+		ImageIds:       images,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) DeleteRepository(_ context.Context, params *ecr.DeleteRepositoryInput, _ ...func(*ecr.Options)) (*ecr.DeleteRepositoryOutput, error) {
+	repo, err := f.getRepo(params.RepositoryName)
+	if err != nil {
+		return nil, err
+	}
+	name := *repo.RepositoryName
+	if len(f.Images[name]) > 0 && !params.Force {
+		return nil, &types.RepositoryNotEmptyException{Message: aws.String("repository " + name + " is not empty")}
+	}
+	delete(f.Repositories, name)
+	delete(f.Images, name)
+	delete(f.LifecyclePolicies, name)
+	delete(f.RepositoryPolicies, name)
+	delete(f.Tags, aws.ToString(repo.RepositoryArn))
+	return &ecr.DeleteRepositoryOutput{
+		Repository:     repo,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) ListTagsForResource(_ context.Context, params *ecr.ListTagsForResourceInput, _ ...func(*ecr.Options)) (*ecr.ListTagsForResourceOutput, error) {
+	var tags []types.Tag
+	for k, v := range f.Tags[aws.ToString(params.ResourceArn)] {
+		tags = append(tags, types.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+	return &ecr.ListTagsForResourceOutput{
+		Tags:           tags,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) TagResource(_ context.Context, params *ecr.TagResourceInput, _ ...func(*ecr.Options)) (*ecr.TagResourceOutput, error) {
+	f.tagRepo(aws.ToString(params.ResourceArn), params.Tags)
+	return &ecr.TagResourceOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) UntagResource(_ context.Context, params *ecr.UntagResourceInput, _ ...func(*ecr.Options)) (*ecr.UntagResourceOutput, error) {
+	tags := f.Tags[aws.ToString(params.ResourceArn)]
+	for _, k := range params.TagKeys {
+		delete(tags, k)
+	}
+	return &ecr.UntagResourceOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) DescribeRegistry(_ context.Context, _ *ecr.DescribeRegistryInput, _ ...func(*ecr.Options)) (*ecr.DescribeRegistryOutput, error) {
+	return &ecr.DescribeRegistryOutput{
+		RegistryId:               aws.String("123456789012"),
+		ReplicationConfiguration: f.Replication,
+		ResultMetadata:           middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) PutReplicationConfiguration(_ context.Context, params *ecr.PutReplicationConfigurationInput, _ ...func(*ecr.Options)) (*ecr.PutReplicationConfigurationOutput, error) {
+	f.Replication = params.ReplicationConfiguration
+	return &ecr.PutReplicationConfigurationOutput{
+		ReplicationConfiguration: params.ReplicationConfiguration,
+		ResultMetadata:           middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) DescribePullThroughCacheRules(_ context.Context, _ *ecr.DescribePullThroughCacheRulesInput, _ ...func(*ecr.Options)) (*ecr.DescribePullThroughCacheRulesOutput, error) {
+	var prefixes []string
+	for k := range f.PullThroughCache {
+		prefixes = append(prefixes, k)
+	}
+	sort.Strings(prefixes)
+	var rules []types.PullThroughCacheRule
+	for _, k := range prefixes {
+		rules = append(rules, *f.PullThroughCache[k])
+	}
+	return &ecr.DescribePullThroughCacheRulesOutput{
+		PullThroughCacheRules: rules,
+		ResultMetadata:        middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) CreatePullThroughCacheRule(_ context.Context, params *ecr.CreatePullThroughCacheRuleInput, _ ...func(*ecr.Options)) (*ecr.CreatePullThroughCacheRuleOutput, error) {
+	prefix := aws.ToString(params.EcrRepositoryPrefix)
+	if f.PullThroughCache[prefix] != nil {
+		return nil, &types.PullThroughCacheRuleAlreadyExistsException{}
+	}
+	f.PullThroughCache[prefix] = &types.PullThroughCacheRule{
+		CredentialArn:       params.CredentialArn,
+		EcrRepositoryPrefix: params.EcrRepositoryPrefix,
+		RegistryId:          params.RegistryId,
+		UpstreamRegistry:    params.UpstreamRegistry,
+		UpstreamRegistryUrl: params.UpstreamRegistryUrl,
+	}
+	return &ecr.CreatePullThroughCacheRuleOutput{
+		CredentialArn:       params.CredentialArn,
+		EcrRepositoryPrefix: params.EcrRepositoryPrefix,
+		UpstreamRegistryUrl: params.UpstreamRegistryUrl,
+		ResultMetadata:      middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) UpdatePullThroughCacheRule(_ context.Context, params *ecr.UpdatePullThroughCacheRuleInput, _ ...func(*ecr.Options)) (*ecr.UpdatePullThroughCacheRuleOutput, error) {
+	rule := f.PullThroughCache[aws.ToString(params.EcrRepositoryPrefix)]
+	if rule == nil {
+		return nil, &types.PullThroughCacheRuleNotFoundException{}
+	}
+	rule.CredentialArn = params.CredentialArn
+	return &ecr.UpdatePullThroughCacheRuleOutput{
+		CredentialArn:       params.CredentialArn,
+		EcrRepositoryPrefix: params.EcrRepositoryPrefix,
+		ResultMetadata:      middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) GetRegistryScanningConfiguration(_ context.Context, _ *ecr.GetRegistryScanningConfigurationInput, _ ...func(*ecr.Options)) (*ecr.GetRegistryScanningConfigurationOutput, error) {
+	config := f.Scanning
+	if config == nil {
+		config = &types.RegistryScanningConfiguration{ScanType: types.ScanTypeBasic}
+	}
+	return &ecr.GetRegistryScanningConfigurationOutput{
+		RegistryId:            aws.String("123456789012"),
+		ScanningConfiguration: config,
+		ResultMetadata:        middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) PutRegistryScanningConfiguration(_ context.Context, params *ecr.PutRegistryScanningConfigurationInput, _ ...func(*ecr.Options)) (*ecr.PutRegistryScanningConfigurationOutput, error) {
+	f.Scanning = &types.RegistryScanningConfiguration{
+		Rules:    params.Rules,
+		ScanType: params.ScanType,
+	}
+	return &ecr.PutRegistryScanningConfigurationOutput{
+		RegistryScanningConfiguration: f.Scanning,
+		ResultMetadata:                middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) DescribeImageScanFindings(_ context.Context, params *ecr.DescribeImageScanFindingsInput, _ ...func(*ecr.Options)) (*ecr.DescribeImageScanFindingsOutput, error) {
+	key := aws.ToString(params.RepositoryName) + ":" + aws.ToString(params.ImageId.ImageTag)
+	if params.ImageId.ImageDigest != nil {
+		key = aws.ToString(params.RepositoryName) + "@" + aws.ToString(params.ImageId.ImageDigest)
+	}
+	findings := f.ScanFindings[key]
+	if findings == nil {
+		return nil, &types.ScanNotFoundException{Message: aws.String("no scan found for " + key)}
+	}
+	status := types.ScanStatusComplete
+	if f.ScanInProgressCalls > 0 {
+		f.ScanInProgressCalls--
+		status = types.ScanStatusInProgress
+	}
+	return &ecr.DescribeImageScanFindingsOutput{
+		ImageId:           params.ImageId,
+		ImageScanFindings: findings,
+		ImageScanStatus:   &types.ImageScanStatus{Status: status},
+		RegistryId:        params.RegistryId,
+		RepositoryName:    params.RepositoryName,
+		ResultMetadata:    middleware.Metadata{},
+	}, nil
+}
+
+func (f *FakeECR) tagRepo(arn string, tags []types.Tag) {
+	if f.Tags == nil {
+		f.Tags = map[string]map[string]string{}
+	}
+	if f.Tags[arn] == nil {
+		f.Tags[arn] = map[string]string{}
+	}
+	for _, t := range tags {
+		f.Tags[arn][aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+}
+
+func (f *FakeECR) getRepo(name *string) (*types.Repository, error) {
+	if name == nil {
+		return nil, fmt.Errorf("missing params.RepositoryName")
+	}
+	repo := f.Repositories[*name]
+	if repo == nil {
+		return nil, &types.RepositoryNotFoundException{Message: name}
+	}
+	return repo, nil
+}
+
 func (f *FakeECR) createRepo(name string) *types.Repository {
+	return f.createRepoInRegistry(name, "123456789012")
+}
+
+func (f *FakeECR) createRepoInRegistry(name, id string) *types.Repository {
 	now := time.Now()
 	if f.Region == "" {
 		f.Region = "us-east-1"
 	}
-	id := "123456789012"
 	arn := "arn:aws:ecr:" + f.Region + ":" + id + ":repository/" + name
 	uri := id + ".dkr.ecr." + f.Region + ".amazonaws.com/" + name
 	repo := &types.Repository{
@@ -101,6 +402,12 @@ func (f *FakeECR) createRepo(name string) *types.Repository {
 // NewFakeECR creates a new fake ECR
 func NewFakeECR() *FakeECR {
 	return &FakeECR{
-		Repositories: map[string]*types.Repository{},
+		Repositories:       map[string]*types.Repository{},
+		Images:             map[string][]types.ImageIdentifier{},
+		Tags:               map[string]map[string]string{},
+		LifecyclePolicies:  map[string]string{},
+		RepositoryPolicies: map[string]string{},
+		PullThroughCache:   map[string]*types.PullThroughCacheRule{},
+		ScanFindings:       map[string]*types.ImageScanFindings{},
 	}
 }
@@ -0,0 +1,211 @@
+package ecrs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TagStatusTagged selects tagged images
+	TagStatusTagged = "tagged"
+
+	// TagStatusUntagged selects untagged images
+	TagStatusUntagged = "untagged"
+
+	// TagStatusAny selects all images
+	TagStatusAny = "any"
+
+	// CountTypeSinceImagePushed expires images older than the count
+	CountTypeSinceImagePushed = "sinceImagePushed"
+
+	// CountTypeImageCountMoreThan expires images once there are more than the count
+	CountTypeImageCountMoreThan = "imageCountMoreThan"
+
+	// CountUnitDays the unit of a sinceImagePushed count
+	CountUnitDays = "days"
+
+	// ActionExpire the lifecycle rule action type
+	ActionExpire = "expire"
+
+	// DefaultExpireTagPrefix the tag prefix used for pull request builds which is expired by default
+	DefaultExpireTagPrefix = "0.0.0-"
+
+	// DefaultExpireTagPrefixDays the number of days after which pull request images are expired by default
+	DefaultExpireTagPrefixDays = 14
+
+	// DefaultCacheExpireUntaggedAfterDays the number of days after which untagged cache images are expired by default
+	DefaultCacheExpireUntaggedAfterDays = 1
+
+	// DefaultCacheKeepLastNTagged the number of tagged cache images kept by default
+	DefaultCacheKeepLastNTagged = 10
+)
+
+// LifecyclePolicy an ECR lifecycle policy
+type LifecyclePolicy struct {
+	Rules []LifecycleRule `json:"rules"`
+}
+
+// LifecycleRule a rule in an ECR lifecycle policy
+type LifecycleRule struct {
+	RulePriority int                `json:"rulePriority"`
+	Description  string             `json:"description,omitempty"`
+	Selection    LifecycleSelection `json:"selection"`
+	Action       LifecycleAction    `json:"action"`
+}
+
+// LifecycleSelection the images selected by a lifecycle rule
+type LifecycleSelection struct {
+	TagStatus      string   `json:"tagStatus"`
+	TagPrefixList  []string `json:"tagPrefixList,omitempty"`
+	TagPatternList []string `json:"tagPatternList,omitempty"`
+	CountType      string   `json:"countType"`
+	CountUnit      string   `json:"countUnit,omitempty"`
+	CountNumber    int      `json:"countNumber"`
+}
+
+// LifecycleAction the action of a lifecycle rule
+type LifecycleAction struct {
+	Type string `json:"type"`
+}
+
+// ToJSON returns the JSON text of the policy
+func (p *LifecyclePolicy) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal lifecycle policy: %w", err)
+	}
+	return string(data), nil
+}
+
+// LifecyclePolicyBuilder builds a lifecycle policy from simple expiry settings
+type LifecyclePolicyBuilder struct {
+	// ExpireUntaggedAfterDays expires untagged images after the number of days if positive
+	ExpireUntaggedAfterDays int
+
+	// ExpireTagPrefixes expires tagged images with a prefix after a number of days, each of the form prefix=days
+	ExpireTagPrefixes []string
+
+	// KeepLastNTagged expires all but the last number of tagged images if positive
+	KeepLastNTagged int
+}
+
+// IsEmpty returns true if no expiry settings have been specified
+func (b *LifecyclePolicyBuilder) IsEmpty() bool {
+	return b.ExpireUntaggedAfterDays <= 0 && len(b.ExpireTagPrefixes) == 0 && b.KeepLastNTagged <= 0
+}
+
+// Build builds the lifecycle policy. If no expiry settings are specified the default policy is returned
+// which expires pull request images after 14 days.
+//
+// Rule priorities are ordered so that the tag prefix rules are evaluated first, then the untagged rule and
+// finally the rule keeping the last tagged images
+func (b *LifecyclePolicyBuilder) Build() (*LifecyclePolicy, error) {
+	if b.IsEmpty() {
+		return DefaultLifecyclePolicy(), nil
+	}
+	policy := &LifecyclePolicy{}
+	for _, text := range b.ExpireTagPrefixes {
+		prefix, days, err := parseTagPrefixExpiry(text)
+		if err != nil {
+			return nil, err
+		}
+		policy.AddRule(LifecycleRule{
+			Description: fmt.Sprintf("Expire images with tag prefix %s older than %d days", prefix, days),
+			Selection: LifecycleSelection{
+				TagStatus:     TagStatusTagged,
+				TagPrefixList: []string{prefix},
+				CountType:     CountTypeSinceImagePushed,
+				CountUnit:     CountUnitDays,
+				CountNumber:   days,
+			},
+		})
+	}
+	if b.ExpireUntaggedAfterDays > 0 {
+		policy.AddRule(LifecycleRule{
+			Description: fmt.Sprintf("Expire untagged images older than %d days", b.ExpireUntaggedAfterDays),
+			Selection: LifecycleSelection{
+				TagStatus:   TagStatusUntagged,
+				CountType:   CountTypeSinceImagePushed,
+				CountUnit:   CountUnitDays,
+				CountNumber: b.ExpireUntaggedAfterDays,
+			},
+		})
+	}
+	if b.KeepLastNTagged > 0 {
+		policy.AddRule(LifecycleRule{
+			Description: fmt.Sprintf("Keep the last %d tagged images", b.KeepLastNTagged),
+			Selection: LifecycleSelection{
+				TagStatus:      TagStatusTagged,
+				TagPatternList: []string{"*"},
+				CountType:      CountTypeImageCountMoreThan,
+				CountNumber:    b.KeepLastNTagged,
+			},
+		})
+	}
+	return policy, nil
+}
+
+// AddRule appends the rule to the policy with the next rule priority and the expire action
+func (p *LifecyclePolicy) AddRule(rule LifecycleRule) {
+	rule.RulePriority = len(p.Rules) + 1
+	if rule.Action.Type == "" {
+		rule.Action.Type = ActionExpire
+	}
+	p.Rules = append(p.Rules, rule)
+}
+
+// DefaultLifecyclePolicy returns the default lifecycle policy which expires pull request images after 14 days
+func DefaultLifecyclePolicy() *LifecyclePolicy {
+	policy := &LifecyclePolicy{}
+	policy.AddRule(LifecycleRule{
+		Description: fmt.Sprintf("Expire images older than %d days", DefaultExpireTagPrefixDays),
+		Selection: LifecycleSelection{
+			TagStatus:     TagStatusTagged,
+			CountType:     CountTypeSinceImagePushed,
+			TagPrefixList: []string{DefaultExpireTagPrefix},
+			CountUnit:     CountUnitDays,
+			CountNumber:   DefaultExpireTagPrefixDays,
+		},
+	})
+	return policy
+}
+
+// DefaultCacheLifecyclePolicy returns the default lifecycle policy of cache repositories which expires untagged
+// images after a day and keeps the last 10 tagged images
+func DefaultCacheLifecyclePolicy() *LifecyclePolicy {
+	policy := &LifecyclePolicy{}
+	policy.AddRule(LifecycleRule{
+		Description: "Expire untagged cache images older than 1 day",
+		Selection: LifecycleSelection{
+			TagStatus:   TagStatusUntagged,
+			CountType:   CountTypeSinceImagePushed,
+			CountUnit:   CountUnitDays,
+			CountNumber: DefaultCacheExpireUntaggedAfterDays,
+		},
+	})
+	policy.AddRule(LifecycleRule{
+		Description: fmt.Sprintf("Keep the last %d tagged cache images", DefaultCacheKeepLastNTagged),
+		Selection: LifecycleSelection{
+			TagStatus:      TagStatusTagged,
+			TagPatternList: []string{"*"},
+			CountType:      CountTypeImageCountMoreThan,
+			CountNumber:    DefaultCacheKeepLastNTagged,
+		},
+	})
+	return policy
+}
+
+func parseTagPrefixExpiry(text string) (prefix string, days int, err error) {
+	prefix, value, ok := strings.Cut(text, "=")
+	prefix = strings.TrimSpace(prefix)
+	if !ok || prefix == "" {
+		return "", 0, fmt.Errorf("invalid tag prefix expiry '%s' should be of the form prefix=days", text)
+	}
+	days, err = strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || days <= 0 {
+		return "", 0, fmt.Errorf("invalid number of days in tag prefix expiry '%s'", text)
+	}
+	return prefix, days, nil
+}
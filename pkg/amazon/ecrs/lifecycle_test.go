@@ -0,0 +1,70 @@
+package ecrs_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecyclePolicyBuilderDefault(t *testing.T) {
+	builder := &ecrs.LifecyclePolicyBuilder{}
+	policy, err := builder.Build()
+	require.NoError(t, err, "failed to build policy")
+
+	text, err := policy.ToJSON()
+	require.NoError(t, err, "failed to marshal policy")
+
+	expected := `{
+    "rules": [
+        {
+            "rulePriority": 1,
+            "description": "Expire images older than 14 days",
+            "selection": {
+                "tagStatus": "tagged",
+                "countType": "sinceImagePushed",
+                "tagPrefixList": ["0.0.0-"],
+                "countUnit": "days",
+                "countNumber": 14
+            },
+            "action": {
+                "type": "expire"
+            }
+        }
+    ]
+}`
+	equal, err := ecrs.LifecyclePoliciesEqual(expected, text)
+	require.NoError(t, err, "failed to compare policies")
+	assert.True(t, equal, "should generate the default policy but got %s", text)
+}
+
+func TestLifecyclePolicyBuilder(t *testing.T) {
+	builder := &ecrs.LifecyclePolicyBuilder{
+		ExpireUntaggedAfterDays: 1,
+		ExpireTagPrefixes:       []string{"0.0.0-=7", "pr-=3"},
+		KeepLastNTagged:         20,
+	}
+	policy, err := builder.Build()
+	require.NoError(t, err, "failed to build policy")
+	require.Len(t, policy.Rules, 4)
+
+	for i, r := range policy.Rules {
+		assert.Equal(t, i+1, r.RulePriority, "rule priority for rule %d", i)
+		assert.Equal(t, ecrs.ActionExpire, r.Action.Type, "action for rule %d", i)
+	}
+	assert.Equal(t, []string{"0.0.0-"}, policy.Rules[0].Selection.TagPrefixList)
+	assert.Equal(t, 7, policy.Rules[0].Selection.CountNumber)
+	assert.Equal(t, []string{"pr-"}, policy.Rules[1].Selection.TagPrefixList)
+	assert.Equal(t, ecrs.TagStatusUntagged, policy.Rules[2].Selection.TagStatus)
+	assert.Equal(t, ecrs.CountTypeImageCountMoreThan, policy.Rules[3].Selection.CountType)
+	assert.Equal(t, 20, policy.Rules[3].Selection.CountNumber)
+
+	for _, prefix := range []string{"0.0.0-", "=7", "pr-=x", "pr-=0"} {
+		builder = &ecrs.LifecyclePolicyBuilder{
+			ExpireTagPrefixes: []string{prefix},
+		}
+		_, err = builder.Build()
+		assert.Error(t, err, "should fail to build for tag prefix %s", prefix)
+	}
+}
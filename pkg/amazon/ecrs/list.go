@@ -0,0 +1,130 @@
+package ecrs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// RepositoryInfo a summary of an ECR repository
+type RepositoryInfo struct {
+	Name               string     `json:"name"`
+	URI                string     `json:"uri,omitempty"`
+	CreatedAt          *time.Time `json:"createdAt,omitempty"`
+	ImageTagMutability string     `json:"imageTagMutability,omitempty"`
+	ScanOnPush         bool       `json:"scanOnPush"`
+	Encryption         string     `json:"encryption,omitempty"`
+	LifecyclePolicy    bool       `json:"lifecyclePolicy"`
+	RepositoryPolicy   bool       `json:"repositoryPolicy"`
+}
+
+// ListRepositories lists the repositories in the registry filtered by the registry organisation if specified
+func (o *Options) ListRepositories() ([]RepositoryInfo, error) {
+	repos, err := o.DescribeAllRepositories(o.organisationPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var answer []RepositoryInfo
+	for i := range repos {
+		info, err := o.repositoryInfo(&repos[i])
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, info)
+	}
+	return answer, nil
+}
+
+// DescribeAllRepositories pages through all the repositories in the registry whose name starts with the given prefix
+func (o *Options) DescribeAllRepositories(prefix string) ([]types.Repository, error) {
+	client, err := o.GetECRClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := o.GetContext()
+
+	var answer []types.Repository
+	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{
+		RegistryId: o.registryIDPointer(),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repositories with registry ID %s: %w", o.GetRegistryID(), err)
+		}
+		for i := range output.Repositories {
+			repo := output.Repositories[i]
+			if strings.HasPrefix(aws.ToString(repo.RepositoryName), prefix) {
+				answer = append(answer, repo)
+			}
+		}
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return aws.ToString(answer[i].RepositoryName) < aws.ToString(answer[j].RepositoryName)
+	})
+	return answer, nil
+}
+
+func (o *Options) repositoryInfo(repo *types.Repository) (RepositoryInfo, error) {
+	name := aws.ToString(repo.RepositoryName)
+	info := RepositoryInfo{
+		Name:               name,
+		URI:                aws.ToString(repo.RepositoryUri),
+		CreatedAt:          repo.CreatedAt,
+		ImageTagMutability: string(repo.ImageTagMutability),
+		Encryption:         string(types.EncryptionTypeAes256),
+	}
+	if repo.ImageScanningConfiguration != nil {
+		info.ScanOnPush = repo.ImageScanningConfiguration.ScanOnPush
+	}
+	if repo.EncryptionConfiguration != nil && repo.EncryptionConfiguration.EncryptionType != "" {
+		info.Encryption = string(repo.EncryptionConfiguration.EncryptionType)
+		if repo.EncryptionConfiguration.KmsKey != nil {
+			info.Encryption += " " + *repo.EncryptionConfiguration.KmsKey
+		}
+	}
+
+	client, err := o.GetECRClient()
+	if err != nil {
+		return info, err
+	}
+	ctx := o.GetContext()
+	_, err = client.GetLifecyclePolicy(ctx, &ecr.GetLifecyclePolicyInput{
+		RepositoryName: repo.RepositoryName,
+		RegistryId:     repo.RegistryId,
+	})
+	if err != nil {
+		var notFoundErr *types.LifecyclePolicyNotFoundException
+		if !errors.As(err, &notFoundErr) {
+			return info, fmt.Errorf("failed to fetch lifecycle policy for the ECR repository %s: %w", name, err)
+		}
+	}
+	info.LifecyclePolicy = err == nil
+
+	_, err = client.GetRepositoryPolicy(ctx, &ecr.GetRepositoryPolicyInput{
+		RepositoryName: repo.RepositoryName,
+		RegistryId:     repo.RegistryId,
+	})
+	if err != nil {
+		var notFoundErr *types.RepositoryPolicyNotFoundException
+		if !errors.As(err, &notFoundErr) {
+			return info, fmt.Errorf("failed to fetch repository policy for the ECR repository %s: %w", name, err)
+		}
+	}
+	info.RepositoryPolicy = err == nil
+	return info, nil
+}
+
+// organisationPrefix returns the repository name prefix for the registry organisation
+func (o *Options) organisationPrefix() string {
+	if o.RegistryOrganisation == "" {
+		return ""
+	}
+	return strings.ToLower(o.RegistryOrganisation) + "/"
+}
@@ -0,0 +1,223 @@
+package ecrs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultRepositoryPolicyVersion the policy version used by IAM if none is specified
+	DefaultRepositoryPolicyVersion = "2008-10-17"
+)
+
+// LifecyclePoliciesEqual returns true if the lifecycle policies are semantically equal, ignoring formatting,
+// key order, rule order and missing default values
+func LifecyclePoliciesEqual(a, b string) (bool, error) {
+	return policiesEqual(a, b, normalizeLifecyclePolicy)
+}
+
+// RepositoryPoliciesEqual returns true if the repository policies are semantically equal, ignoring formatting,
+// key order, statement order and missing default values
+func RepositoryPoliciesEqual(a, b string) (bool, error) {
+	return policiesEqual(a, b, normalizeRepositoryPolicy)
+}
+
+func policiesEqual(a, b string, normalize func(map[string]interface{})) (bool, error) {
+	ca, err := canonicalPolicy(a, normalize)
+	if err != nil {
+		return false, err
+	}
+	cb, err := canonicalPolicy(b, normalize)
+	if err != nil {
+		return false, err
+	}
+	return ca == cb, nil
+}
+
+// canonicalPolicy parses the policy, normalizes it and returns the canonical JSON text with sorted keys
+func canonicalPolicy(text string, normalize func(map[string]interface{})) (string, error) {
+	m := map[string]interface{}{}
+	if strings.TrimSpace(text) != "" {
+		err := json.Unmarshal([]byte(text), &m)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse policy %s: %w", text, err)
+		}
+	}
+	normalize(m)
+	v := removeEmptyValues(m)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal policy: %w", err)
+	}
+	return string(data), nil
+}
+
+func normalizeLifecyclePolicy(m map[string]interface{}) {
+	rules := toSlice(m["rules"])
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		action, ok := rule["action"].(map[string]interface{})
+		if !ok {
+			action = map[string]interface{}{}
+			rule["action"] = action
+		}
+		if action["type"] == nil || action["type"] == "" {
+			action["type"] = "expire"
+		}
+		selection, ok := rule["selection"].(map[string]interface{})
+		if ok {
+			sortStrings(selection, "tagPrefixList", "tagPatternList")
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rulePriority(rules[i]) < rulePriority(rules[j])
+	})
+	if rules != nil {
+		m["rules"] = rules
+	}
+}
+
+func rulePriority(r interface{}) float64 {
+	rule, ok := r.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	p, _ := rule["rulePriority"].(float64)
+	return p
+}
+
+func normalizeRepositoryPolicy(m map[string]interface{}) {
+	if m["Version"] == nil || m["Version"] == "" {
+		m["Version"] = DefaultRepositoryPolicyVersion
+	}
+	statements := toSlice(m["Statement"])
+	for _, s := range statements {
+		statement, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		sortStrings(statement, "Action", "NotAction", "Resource", "NotResource")
+		for _, key := range []string{"Principal", "NotPrincipal"} {
+			principal, ok := statement[key].(map[string]interface{})
+			if ok {
+				sortStrings(principal, keys(principal)...)
+			}
+		}
+		condition, ok := statement["Condition"].(map[string]interface{})
+		if ok {
+			for _, v := range condition {
+				operator, ok := v.(map[string]interface{})
+				if ok {
+					sortStrings(operator, keys(operator)...)
+				}
+			}
+		}
+	}
+	sort.SliceStable(statements, func(i, j int) bool {
+		return canonicalJSON(statements[i]) < canonicalJSON(statements[j])
+	})
+	if statements != nil {
+		m["Statement"] = statements
+	}
+}
+
+// sortStrings converts the values of the given keys from a string or list of strings into a sorted list of unique strings
+func sortStrings(m map[string]interface{}, names ...string) {
+	for _, name := range names {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		var values []string
+		for _, item := range toSlice(v) {
+			s, ok := item.(string)
+			if !ok {
+				// leave non string lists alone
+				values = nil
+				break
+			}
+			values = append(values, s)
+		}
+		if values == nil {
+			continue
+		}
+		sort.Strings(values)
+		var answer []interface{}
+		for i, s := range values {
+			if i == 0 || values[i-1] != s {
+				answer = append(answer, s)
+			}
+		}
+		m[name] = answer
+	}
+}
+
+// toSlice converts a single value into a slice of one value
+func toSlice(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return t
+	default:
+		return []interface{}{t}
+	}
+}
+
+// removeEmptyValues removes empty strings, lists and maps so that missing and empty values are equal
+func removeEmptyValues(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		answer := map[string]interface{}{}
+		for k, v := range t {
+			v = removeEmptyValues(v)
+			if v != nil {
+				answer[k] = v
+			}
+		}
+		if len(answer) == 0 {
+			return nil
+		}
+		return answer
+	case []interface{}:
+		var answer []interface{}
+		for _, v := range t {
+			v = removeEmptyValues(v)
+			if v != nil {
+				answer = append(answer, v)
+			}
+		}
+		if len(answer) == 0 {
+			return nil
+		}
+		return answer
+	case string:
+		if t == "" {
+			return nil
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func canonicalJSON(v interface{}) string {
+	data, err := json.Marshal(removeEmptyValues(v))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func keys(m map[string]interface{}) []string {
+	answer := make([]string, 0, len(m))
+	for k := range m {
+		answer = append(answer, k)
+	}
+	return answer
+}
@@ -0,0 +1,118 @@
+package ecrs_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecyclePoliciesEqual(t *testing.T) {
+	policy := `{
+    "rules": [
+        {
+            "rulePriority": 1,
+            "description": "Expire images older than 14 days",
+            "selection": {
+                "tagStatus": "tagged",
+                "countType": "sinceImagePushed",
+                "tagPrefixList": ["0.0.0-", "pr-"],
+                "countUnit": "days",
+                "countNumber": 14
+            },
+            "action": {
+                "type": "expire"
+            }
+        },
+        {
+            "rulePriority": 2,
+            "selection": {
+                "tagStatus": "untagged",
+                "countType": "imageCountMoreThan",
+                "countNumber": 5
+            }
+        }
+    ]
+}`
+	testCases := []struct {
+		name     string
+		other    string
+		expected bool
+	}{
+		{
+			name:     "same",
+			other:    policy,
+			expected: true,
+		},
+		{
+			name:     "normalised by ECR",
+			other:    `{"rules":[{"action":{"type":"expire"},"selection":{"countType":"imageCountMoreThan","countNumber":5,"tagStatus":"untagged"},"description":"","rulePriority":2},{"rulePriority":1,"description":"Expire images older than 14 days","selection":{"tagStatus":"tagged","countType":"sinceImagePushed","tagPrefixList":["pr-","0.0.0-"],"countUnit":"days","countNumber":14},"action":{"type":"expire"}}]}`,
+			expected: true,
+		},
+		{
+			name:     "different count",
+			other:    `{"rules":[{"rulePriority":1,"description":"Expire images older than 14 days","selection":{"tagStatus":"tagged","countType":"sinceImagePushed","tagPrefixList":["0.0.0-","pr-"],"countUnit":"days","countNumber":7}},{"rulePriority":2,"selection":{"tagStatus":"untagged","countType":"imageCountMoreThan","countNumber":5}}]}`,
+			expected: false,
+		},
+		{
+			name:     "missing rule",
+			other:    `{"rules":[{"rulePriority":2,"selection":{"tagStatus":"untagged","countType":"imageCountMoreThan","countNumber":5}}]}`,
+			expected: false,
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := ecrs.LifecyclePoliciesEqual(policy, tc.other)
+		require.NoError(t, err, "failed to compare policies for %s", tc.name)
+		assert.Equal(t, tc.expected, actual, "for %s", tc.name)
+	}
+
+	_, err := ecrs.LifecyclePoliciesEqual(policy, `{"rules": [`)
+	assert.Error(t, err, "should fail to parse invalid JSON")
+}
+
+func TestRepositoryPoliciesEqual(t *testing.T) {
+	policy := `{
+  "Version": "2008-10-17",
+  "Statement": [
+    {
+      "Sid": "AllowPull",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::222222222222:root", "arn:aws:iam::111111111111:root"]},
+      "Action": ["ecr:GetDownloadUrlForLayer", "ecr:BatchGetImage", "ecr:BatchCheckLayerAvailability"]
+    },
+    {
+      "Sid": "AllowOrg",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": "ecr:BatchGetImage",
+      "Condition": {"StringEquals": {"aws:PrincipalOrgID": "o-abc123"}}
+    }
+  ]
+}`
+	testCases := []struct {
+		name     string
+		other    string
+		expected bool
+	}{
+		{
+			name:     "reordered",
+			other:    `{"Statement":[{"Action":["ecr:BatchGetImage"],"Condition":{"StringEquals":{"aws:PrincipalOrgID":["o-abc123"]}},"Effect":"Allow","Principal":"*","Sid":"AllowOrg"},{"Action":["ecr:BatchCheckLayerAvailability","ecr:BatchGetImage","ecr:GetDownloadUrlForLayer"],"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::111111111111:root","arn:aws:iam::222222222222:root"]},"Sid":"AllowPull"}],"Version":"2008-10-17"}`,
+			expected: true,
+		},
+		{
+			name:     "different principal",
+			other:    `{"Statement":[{"Action":"ecr:BatchGetImage","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abc123"}},"Effect":"Allow","Principal":"*","Sid":"AllowOrg"},{"Action":["ecr:BatchCheckLayerAvailability","ecr:BatchGetImage","ecr:GetDownloadUrlForLayer"],"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111111111111:root"},"Sid":"AllowPull"}]}`,
+			expected: false,
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := ecrs.RepositoryPoliciesEqual(policy, tc.other)
+		require.NoError(t, err, "failed to compare policies for %s", tc.name)
+		assert.Equal(t, tc.expected, actual, "for %s", tc.name)
+	}
+
+	actual, err := ecrs.RepositoryPoliciesEqual(`{"Version": "2008-10-17", "Statement": []}`, `{"Statement": []}`)
+	require.NoError(t, err, "failed to compare empty policies")
+	assert.True(t, actual, "should treat a missing version as the default")
+}
@@ -0,0 +1,191 @@
+package ecrs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// PolicyModeReplace replaces the current policy with the desired policy
+	PolicyModeReplace = "replace"
+
+	// PolicyModeMerge adds the statements or rules of the desired policy to the current policy, updating any matching
+	// statements or rules and keeping all others
+	PolicyModeMerge = "merge"
+
+	// PolicyModeEnsureStatements adds the statements or rules of the desired policy which are missing from the current
+	// policy without modifying any existing statements or rules
+	PolicyModeEnsureStatements = "ensure-statements"
+)
+
+var (
+	// PolicyModes the valid policy modes
+	PolicyModes = []string{PolicyModeReplace, PolicyModeMerge, PolicyModeEnsureStatements}
+)
+
+// MergeRepositoryPolicies merges the desired repository policy into the current policy using the mode.
+// Statements are matched by Sid or if they have no Sid by their content
+func MergeRepositoryPolicies(current, desired, mode string) (string, error) {
+	if mode == "" || mode == PolicyModeReplace || strings.TrimSpace(current) == "" {
+		return desired, nil
+	}
+	m := map[string]interface{}{}
+	err := json.Unmarshal([]byte(current), &m)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse current repository policy: %w", err)
+	}
+	d := map[string]interface{}{}
+	err = json.Unmarshal([]byte(desired), &d)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repository policy: %w", err)
+	}
+	if m["Version"] == nil {
+		m["Version"] = d["Version"]
+	}
+	m["Statement"] = mergeStatements(toSlice(m["Statement"]), toSlice(d["Statement"]), mode != PolicyModeEnsureStatements)
+	return marshalRepositoryPolicy(m)
+}
+
+// MergeLifecyclePolicies merges the desired lifecycle policy into the current policy using the mode.
+// Rules are matched by description or if they have no description by rule priority. Added or replaced rules whose
+// priority is already used by another rule are given the next free priority. Fields of the current policy which
+// are not known to this tool are preserved
+func MergeLifecyclePolicies(current, desired, mode string) (string, error) {
+	if mode == "" || mode == PolicyModeReplace || strings.TrimSpace(current) == "" {
+		return desired, nil
+	}
+	c := map[string]interface{}{}
+	err := json.Unmarshal([]byte(current), &c)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse current lifecycle policy: %w", err)
+	}
+	d := map[string]interface{}{}
+	err = json.Unmarshal([]byte(desired), &d)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse lifecycle policy: %w", err)
+	}
+	rules := toSlice(c["rules"])
+	for _, r := range toSlice(d["rules"]) {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		idx := findLifecycleRule(rules, rule)
+		switch {
+		case idx < 0:
+			ensureFreeRulePriority(rules, rule, -1)
+			rules = append(rules, rule)
+		case mode != PolicyModeEnsureStatements:
+			ensureFreeRulePriority(rules, rule, idx)
+			rules[idx] = rule
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rulePriority(rules[i]) < rulePriority(rules[j])
+	})
+	c["rules"] = rules
+	data, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal lifecycle policy: %w", err)
+	}
+	return string(data), nil
+}
+
+func findLifecycleRule(rules []interface{}, rule map[string]interface{}) int {
+	description := ruleDescription(rule)
+	for i := range rules {
+		if description != "" && ruleDescription(rules[i]) == description {
+			return i
+		}
+		if description == "" && ruleDescription(rules[i]) == "" && rulePriority(rules[i]) == rulePriority(rule) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ensureFreeRulePriority changes the priority of the rule if it is used by any of the rules other than the one at
+// index ignore. A rule with tagStatus any is moved after all other rules as ECR requires it to have the highest priority
+func ensureFreeRulePriority(rules []interface{}, rule map[string]interface{}, ignore int) {
+	used := map[float64]bool{}
+	maxPriority := 0.0
+	for i, r := range rules {
+		if i == ignore {
+			continue
+		}
+		p := rulePriority(r)
+		used[p] = true
+		if p > maxPriority {
+			maxPriority = p
+		}
+	}
+	p := rulePriority(rule)
+	if !used[p] {
+		return
+	}
+	selection, _ := rule["selection"].(map[string]interface{})
+	if selection != nil && selection["tagStatus"] == TagStatusAny {
+		rule["rulePriority"] = maxPriority + 1
+		return
+	}
+	for used[p] {
+		p++
+	}
+	rule["rulePriority"] = p
+}
+
+func ruleDescription(r interface{}) string {
+	rule, ok := r.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	description, _ := rule["description"].(string)
+	return description
+}
+
+// mergeStatements adds the statements to the current statements matching them by Sid or content.
+// If overwrite is true matching statements are replaced otherwise they are left as they are
+func mergeStatements(current, statements []interface{}, overwrite bool) []interface{} {
+	for _, s := range statements {
+		idx := findStatement(current, s)
+		switch {
+		case idx < 0:
+			current = append(current, s)
+		case overwrite:
+			current[idx] = s
+		}
+	}
+	return current
+}
+
+func findStatement(statements []interface{}, statement interface{}) int {
+	sid := statementSid(statement)
+	for i, s := range statements {
+		if sid != "" && statementSid(s) == sid {
+			return i
+		}
+		if sid == "" && canonicalJSON(s) == canonicalJSON(statement) {
+			return i
+		}
+	}
+	return -1
+}
+
+func statementSid(statement interface{}) string {
+	m, ok := statement.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	sid, _ := m["Sid"].(string)
+	return sid
+}
+
+func marshalRepositoryPolicy(m map[string]interface{}) (string, error) {
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal repository policy: %w", err)
+	}
+	return string(data), nil
+}
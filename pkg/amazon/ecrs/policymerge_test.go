@@ -0,0 +1,151 @@
+package ecrs_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeRepositoryPolicies(t *testing.T) {
+	current := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "OtherTeam", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::333333333333:root"}, "Action": "ecr:BatchGetImage"},
+    {"Sid": "Pull", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::444444444444:root"}, "Action": "ecr:BatchGetImage"}
+  ]
+}`
+	desired := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "Pull", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:root"}, "Action": "ecr:BatchGetImage"},
+    {"Sid": "Push", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:role/pusher"}, "Action": "ecr:PutImage"}
+  ]
+}`
+	testCases := []struct {
+		mode     string
+		expected string
+	}{
+		{
+			mode:     ecrs.PolicyModeReplace,
+			expected: desired,
+		},
+		{
+			mode: ecrs.PolicyModeMerge,
+			expected: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "OtherTeam", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::333333333333:root"}, "Action": "ecr:BatchGetImage"},
+    {"Sid": "Pull", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:root"}, "Action": "ecr:BatchGetImage"},
+    {"Sid": "Push", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:role/pusher"}, "Action": "ecr:PutImage"}
+  ]
+}`,
+		},
+		{
+			mode: ecrs.PolicyModeEnsureStatements,
+			expected: `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Sid": "OtherTeam", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::333333333333:root"}, "Action": "ecr:BatchGetImage"},
+    {"Sid": "Pull", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::444444444444:root"}, "Action": "ecr:BatchGetImage"},
+    {"Sid": "Push", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:role/pusher"}, "Action": "ecr:PutImage"}
+  ]
+}`,
+		},
+	}
+	for _, tc := range testCases {
+		policy, err := ecrs.MergeRepositoryPolicies(current, desired, tc.mode)
+		require.NoError(t, err, "failed to merge for mode %s", tc.mode)
+
+		equal, err := ecrs.RepositoryPoliciesEqual(tc.expected, policy)
+		require.NoError(t, err, "failed to compare for mode %s", tc.mode)
+		assert.True(t, equal, "for mode %s got %s", tc.mode, policy)
+	}
+}
+
+func TestMergeLifecyclePolicies(t *testing.T) {
+	current := `{"rules": [
+  {"rulePriority": 1, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 14}, "action": {"type": "expire"}},
+  {"rulePriority": 5, "description": "other team", "selection": {"tagStatus": "tagged", "tagPrefixList": ["dev-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}}
+]}`
+	desired := `{"rules": [
+  {"rulePriority": 1, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 7}, "action": {"type": "expire"}},
+  {"rulePriority": 2, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}
+]}`
+	testCases := []struct {
+		mode     string
+		expected string
+	}{
+		{
+			mode:     ecrs.PolicyModeReplace,
+			expected: desired,
+		},
+		{
+			mode: ecrs.PolicyModeMerge,
+			expected: `{"rules": [
+  {"rulePriority": 1, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 7}, "action": {"type": "expire"}},
+  {"rulePriority": 2, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}},
+  {"rulePriority": 5, "description": "other team", "selection": {"tagStatus": "tagged", "tagPrefixList": ["dev-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}}
+]}`,
+		},
+		{
+			mode: ecrs.PolicyModeEnsureStatements,
+			expected: `{"rules": [
+  {"rulePriority": 1, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 14}, "action": {"type": "expire"}},
+  {"rulePriority": 2, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}},
+  {"rulePriority": 5, "description": "other team", "selection": {"tagStatus": "tagged", "tagPrefixList": ["dev-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}}
+]}`,
+		},
+	}
+	for _, tc := range testCases {
+		policy, err := ecrs.MergeLifecyclePolicies(current, desired, tc.mode)
+		require.NoError(t, err, "failed to merge for mode %s", tc.mode)
+
+		equal, err := ecrs.LifecyclePoliciesEqual(tc.expected, policy)
+		require.NoError(t, err, "failed to compare for mode %s", tc.mode)
+		assert.True(t, equal, "for mode %s got %s", tc.mode, policy)
+		assert.NoError(t, ecrs.ValidateLifecyclePolicy(policy), "merged policy should be valid for mode %s", tc.mode)
+	}
+}
+
+func TestMergeLifecyclePoliciesWithClashingPriorities(t *testing.T) {
+	current := `{"rules": [
+  {"rulePriority": 1, "description": "other team", "selection": {"tagStatus": "tagged", "tagPrefixList": ["dev-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}, "futureField": "keep me"},
+  {"rulePriority": 2, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 14}, "action": {"type": "expire"}}
+]}`
+	desired := `{"rules": [
+  {"rulePriority": 1, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 7}, "action": {"type": "expire"}},
+  {"rulePriority": 1, "description": "untagged", "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}
+]}`
+	testCases := []struct {
+		mode     string
+		expected string
+	}{
+		{
+			mode: ecrs.PolicyModeMerge,
+			expected: `{"rules": [
+  {"rulePriority": 1, "description": "other team", "selection": {"tagStatus": "tagged", "tagPrefixList": ["dev-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}, "futureField": "keep me"},
+  {"rulePriority": 2, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 7}, "action": {"type": "expire"}},
+  {"rulePriority": 3, "description": "untagged", "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}
+]}`,
+		},
+		{
+			mode: ecrs.PolicyModeEnsureStatements,
+			expected: `{"rules": [
+  {"rulePriority": 1, "description": "other team", "selection": {"tagStatus": "tagged", "tagPrefixList": ["dev-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}, "futureField": "keep me"},
+  {"rulePriority": 2, "description": "pull requests", "selection": {"tagStatus": "tagged", "tagPrefixList": ["0.0.0-"], "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 14}, "action": {"type": "expire"}},
+  {"rulePriority": 3, "description": "untagged", "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}
+]}`,
+		},
+	}
+	for _, tc := range testCases {
+		policy, err := ecrs.MergeLifecyclePolicies(current, desired, tc.mode)
+		require.NoError(t, err, "failed to merge for mode %s", tc.mode)
+
+		equal, err := ecrs.LifecyclePoliciesEqual(tc.expected, policy)
+		require.NoError(t, err, "failed to compare for mode %s", tc.mode)
+		assert.True(t, equal, "for mode %s got %s", tc.mode, policy)
+		assert.NoError(t, ecrs.ValidateLifecyclePolicy(policy), "merged policy should be valid for mode %s", tc.mode)
+	}
+}
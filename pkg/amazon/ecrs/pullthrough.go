@@ -0,0 +1,237 @@
+package ecrs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// PullThroughCacheRules the declarative list of pull through cache rules
+type PullThroughCacheRules struct {
+	Rules []PullThroughCacheRule `json:"rules"`
+}
+
+// PullThroughCacheRule a pull through cache rule proxying an upstream registry
+type PullThroughCacheRule struct {
+	// UpstreamRegistryURL the URL of the upstream registry such as registry-1.docker.io
+	UpstreamRegistryURL string `json:"upstreamRegistryUrl"`
+
+	// ECRRepositoryPrefix the prefix of the ECR repositories of the cached images such as docker-hub
+	ECRRepositoryPrefix string `json:"ecrRepositoryPrefix"`
+
+	// CredentialARN the ARN of the Secrets Manager secret of the upstream registry credentials
+	CredentialARN string `json:"credentialArn,omitempty"`
+
+	// UpstreamRegistry the kind of upstream registry such as docker-hub, quay or github-container-registry
+	UpstreamRegistry string `json:"upstreamRegistry,omitempty"`
+
+	// LifecyclePolicy the lifecycle policy of the repositories created by the rule
+	LifecyclePolicy string `json:"lifecyclePolicy,omitempty"`
+}
+
+// LoadPullThroughCacheRules loads the pull through cache rules from the given YAML or JSON file
+func LoadPullThroughCacheRules(file string) (*PullThroughCacheRules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pull through cache rules file %s: %w", file, err)
+	}
+	rules := &PullThroughCacheRules{}
+	err = yaml.UnmarshalStrict(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pull through cache rules file %s: %w", file, err)
+	}
+	err = rules.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull through cache rules file %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// Validate validates the rules
+func (r *PullThroughCacheRules) Validate() error {
+	prefixes := map[string]bool{}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.UpstreamRegistryURL == "" {
+			return fmt.Errorf("rule %d is missing the upstreamRegistryUrl", i+1)
+		}
+		if rule.ECRRepositoryPrefix == "" {
+			return fmt.Errorf("rule %d is missing the ecrRepositoryPrefix", i+1)
+		}
+		if prefixes[rule.ECRRepositoryPrefix] {
+			return fmt.Errorf("rule %d has the duplicate ecrRepositoryPrefix %s", i+1, rule.ECRRepositoryPrefix)
+		}
+		prefixes[rule.ECRRepositoryPrefix] = true
+		if rule.UpstreamRegistry != "" {
+			found := false
+			for _, v := range types.UpstreamRegistry("").Values() {
+				if string(v) == rule.UpstreamRegistry {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("rule %d has an unknown upstreamRegistry %s", i+1, rule.UpstreamRegistry)
+			}
+		}
+		if rule.LifecyclePolicy != "" {
+			err := ValidateLifecyclePolicy(rule.LifecyclePolicy)
+			if err != nil {
+				return fmt.Errorf("rule %d has an invalid lifecycle policy:\n%w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// EnsurePullThroughCacheRules creates or updates the pull through cache rules then ensures the lifecycle policy of
+// the repositories created by each rule
+func (o *Options) EnsurePullThroughCacheRules(rules *PullThroughCacheRules) error {
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+
+	existing := map[string]*types.PullThroughCacheRule{}
+	paginator := ecr.NewDescribePullThroughCacheRulesPaginator(client, &ecr.DescribePullThroughCacheRulesInput{
+		RegistryId: o.registryIDPointer(),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe pull through cache rules with registry ID %s: %w", o.GetRegistryID(), err)
+		}
+		for i := range output.PullThroughCacheRules {
+			r := output.PullThroughCacheRules[i]
+			existing[aws.ToString(r.EcrRepositoryPrefix)] = &r
+		}
+	}
+
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		err = o.ensurePullThroughCacheRule(rule, existing[rule.ECRRepositoryPrefix])
+		if err != nil {
+			return err
+		}
+		err = o.ensurePullThroughCacheLifecyclePolicies(rule)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Options) ensurePullThroughCacheRule(rule *PullThroughCacheRule, current *types.PullThroughCacheRule) error {
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+	prefix := rule.ECRRepositoryPrefix
+
+	if current == nil {
+		input := &ecr.CreatePullThroughCacheRuleInput{
+			EcrRepositoryPrefix: aws.String(prefix),
+			UpstreamRegistryUrl: aws.String(rule.UpstreamRegistryURL),
+			RegistryId:          o.registryIDPointer(),
+			UpstreamRegistry:    types.UpstreamRegistry(rule.UpstreamRegistry),
+		}
+		if rule.CredentialARN != "" {
+			input.CredentialArn = aws.String(rule.CredentialARN)
+		}
+		if o.DryRun {
+			log.Logger().Infof("would create pull through cache rule %s for %s", termcolor.ColorInfo(prefix), termcolor.ColorInfo(rule.UpstreamRegistryURL))
+			return nil
+		}
+		_, err = client.CreatePullThroughCacheRule(ctx, input)
+		if err != nil {
+			return fmt.Errorf("failed to create pull through cache rule %s for %s: %w", prefix, rule.UpstreamRegistryURL, err)
+		}
+		log.Logger().Infof("Created pull through cache rule %s for %s", termcolor.ColorInfo(prefix), termcolor.ColorInfo(rule.UpstreamRegistryURL))
+		return nil
+	}
+
+	currentURL := aws.ToString(current.UpstreamRegistryUrl)
+	if !strings.EqualFold(strings.TrimSuffix(currentURL, "/"), strings.TrimSuffix(rule.UpstreamRegistryURL, "/")) {
+		return fmt.Errorf("pull through cache rule %s uses the upstream registry %s not %s. The upstream registry of a rule cannot be changed so please delete the rule first",
+			prefix, currentURL, rule.UpstreamRegistryURL)
+	}
+	if aws.ToString(current.CredentialArn) == rule.CredentialARN {
+		log.Logger().Debugf("pull through cache rule %s is up to date", prefix)
+		return nil
+	}
+	if rule.CredentialARN == "" {
+		log.Logger().Warnf("pull through cache rule %s uses the credential %s which cannot be removed without deleting the rule", prefix, aws.ToString(current.CredentialArn))
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would update the credential of pull through cache rule %s to %s", termcolor.ColorInfo(prefix), termcolor.ColorInfo(rule.CredentialARN))
+		return nil
+	}
+	_, err = client.UpdatePullThroughCacheRule(ctx, &ecr.UpdatePullThroughCacheRuleInput{
+		EcrRepositoryPrefix: aws.String(prefix),
+		CredentialArn:       aws.String(rule.CredentialARN),
+		RegistryId:          o.registryIDPointer(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update pull through cache rule %s: %w", prefix, err)
+	}
+	log.Logger().Infof("Updated the credential of pull through cache rule %s", termcolor.ColorInfo(prefix))
+	return nil
+}
+
+// ensurePullThroughCacheLifecyclePolicies ensures the lifecycle policy of the repositories created by the rule
+func (o *Options) ensurePullThroughCacheLifecyclePolicies(rule *PullThroughCacheRule) error {
+	repos, err := o.DescribeAllRepositories(rule.ECRRepositoryPrefix + "/")
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		return nil
+	}
+	ro, err := o.PullThroughCacheOptions(rule)
+	if err != nil {
+		return err
+	}
+	for i := range repos {
+		err = ro.EnsureLifecyclePolicy(aws.ToString(repos[i].RepositoryName))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PullThroughCacheOptions returns the options for the repositories created by the rule. The lifecycle policy of the
+// rule is used, falling back to the lifecycle policy flags then the default cache lifecycle policy
+func (o *Options) PullThroughCacheOptions(rule *PullThroughCacheRule) (*Options, error) {
+	ro := *o
+	ro.CreateECRLifeCyclePolicy = true
+	ro.CreateECRRepositoryPolicy = false
+	ro.AllowPullAccounts = nil
+	ro.AllowPushRoleARNs = nil
+	ro.AllowPullOrgIDs = nil
+	if rule.LifecyclePolicy != "" {
+		ro.ECRLifecyclePolicy = rule.LifecyclePolicy
+		ro.ExpireUntaggedAfterDays = 0
+		ro.ExpireTagPrefixes = nil
+		ro.KeepLastNTagged = 0
+		return &ro, nil
+	}
+	if o.ECRLifecyclePolicy != "" || !o.LifecyclePolicyBuilder().IsEmpty() {
+		return &ro, nil
+	}
+	policy, err := DefaultCacheLifecyclePolicy().ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the default cache lifecycle policy: %w", err)
+	}
+	ro.ECRLifecyclePolicy = policy
+	return &ro, nil
+}
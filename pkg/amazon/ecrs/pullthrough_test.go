@@ -0,0 +1,129 @@
+package ecrs_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullThroughCacheRulesValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rules    []ecrs.PullThroughCacheRule
+		expected string
+	}{
+		{
+			name: "valid",
+			rules: []ecrs.PullThroughCacheRule{
+				{UpstreamRegistryURL: "registry-1.docker.io", ECRRepositoryPrefix: "docker-hub", UpstreamRegistry: "docker-hub"},
+				{UpstreamRegistryURL: "public.ecr.aws", ECRRepositoryPrefix: "ecr-public"},
+			},
+		},
+		{
+			name:     "missing url",
+			rules:    []ecrs.PullThroughCacheRule{{ECRRepositoryPrefix: "docker-hub"}},
+			expected: "rule 1 is missing the upstreamRegistryUrl",
+		},
+		{
+			name:     "missing prefix",
+			rules:    []ecrs.PullThroughCacheRule{{UpstreamRegistryURL: "quay.io"}},
+			expected: "rule 1 is missing the ecrRepositoryPrefix",
+		},
+		{
+			name: "duplicate prefix",
+			rules: []ecrs.PullThroughCacheRule{
+				{UpstreamRegistryURL: "quay.io", ECRRepositoryPrefix: "quay"},
+				{UpstreamRegistryURL: "quay.io", ECRRepositoryPrefix: "quay"},
+			},
+			expected: "rule 2 has the duplicate ecrRepositoryPrefix quay",
+		},
+		{
+			name:     "unknown upstream registry",
+			rules:    []ecrs.PullThroughCacheRule{{UpstreamRegistryURL: "quay.io", ECRRepositoryPrefix: "quay", UpstreamRegistry: "cheese"}},
+			expected: "rule 1 has an unknown upstreamRegistry cheese",
+		},
+		{
+			name:     "invalid lifecycle policy",
+			rules:    []ecrs.PullThroughCacheRule{{UpstreamRegistryURL: "quay.io", ECRRepositoryPrefix: "quay", LifecyclePolicy: `{"rules": []}`}},
+			expected: "rule 1 has an invalid lifecycle policy",
+		},
+	}
+	for _, tc := range testCases {
+		rules := &ecrs.PullThroughCacheRules{Rules: tc.rules}
+		err := rules.Validate()
+		if tc.expected == "" {
+			assert.NoError(t, err, "for %s", tc.name)
+			continue
+		}
+		require.Error(t, err, "for %s", tc.name)
+		assert.Contains(t, err.Error(), tc.expected, "for %s", tc.name)
+	}
+}
+
+func TestEnsurePullThroughCacheRules(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	fakeECR.PullThroughCache["quay"] = &types.PullThroughCacheRule{
+		EcrRepositoryPrefix: aws.String("quay"),
+		UpstreamRegistryUrl: aws.String("quay.io"),
+		CredentialArn:       aws.String("arn:aws:secretsmanager:us-east-1:123456789012:secret:ecr-pullthroughcache/old"),
+	}
+	fakeECR.Repositories["quay/prometheus/node-exporter"] = &types.Repository{RepositoryName: aws.String("quay/prometheus/node-exporter")}
+	fakeECR.Repositories["myorg/myapp"] = &types.Repository{RepositoryName: aws.String("myorg/myapp")}
+
+	o := &ecrs.Options{
+		ECRClient: fakeECR,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	credential := "arn:aws:secretsmanager:us-east-1:123456789012:secret:ecr-pullthroughcache/new"
+	rules := &ecrs.PullThroughCacheRules{
+		Rules: []ecrs.PullThroughCacheRule{
+			{
+				UpstreamRegistryURL: "registry-1.docker.io",
+				ECRRepositoryPrefix: "docker-hub",
+				UpstreamRegistry:    "docker-hub",
+				CredentialARN:       credential,
+			},
+			{
+				UpstreamRegistryURL: "quay.io",
+				ECRRepositoryPrefix: "quay",
+				CredentialARN:       credential,
+			},
+		},
+	}
+	require.NoError(t, rules.Validate(), "failed to validate rules")
+
+	o.DryRun = true
+	err := o.EnsurePullThroughCacheRules(rules)
+	require.NoError(t, err, "failed to ensure rules with dry run")
+	assert.Len(t, fakeECR.PullThroughCache, 1, "should not create rules with dry run")
+	assert.Empty(t, fakeECR.LifecyclePolicies, "should not put lifecycle policies with dry run")
+
+	o.DryRun = false
+	err = o.EnsurePullThroughCacheRules(rules)
+	require.NoError(t, err, "failed to ensure rules")
+
+	require.Contains(t, fakeECR.PullThroughCache, "docker-hub")
+	dockerHub := fakeECR.PullThroughCache["docker-hub"]
+	assert.Equal(t, "registry-1.docker.io", aws.ToString(dockerHub.UpstreamRegistryUrl))
+	assert.Equal(t, types.UpstreamRegistryDockerHub, dockerHub.UpstreamRegistry)
+	assert.Equal(t, credential, aws.ToString(dockerHub.CredentialArn))
+	assert.Equal(t, credential, aws.ToString(fakeECR.PullThroughCache["quay"].CredentialArn), "should have updated the credential")
+
+	expected, err := ecrs.DefaultCacheLifecyclePolicy().ToJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, fakeECR.LifecyclePolicies["quay/prometheus/node-exporter"], "should use the default cache lifecycle policy")
+	assert.NotContains(t, fakeECR.LifecyclePolicies, "myorg/myapp", "should not change repositories of other prefixes")
+	assert.Empty(t, fakeECR.RepositoryPolicies, "should not set repository policies")
+
+	rules.Rules[1].UpstreamRegistryURL = "ghcr.io"
+	err = o.EnsurePullThroughCacheRules(rules)
+	require.Error(t, err, "should fail when the upstream registry changes")
+	assert.Contains(t, err.Error(), "cannot be changed")
+}
@@ -0,0 +1,173 @@
+package ecrs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// ValidateReplication validates that the replication destinations of the registry account are neither the source
+// region, which ECR rejects, nor one of the regions the repositories are created in, which would create them twice.
+// An organisation is required as the replication would otherwise apply to every repository in the registry
+func (o *Options) ValidateReplication() error {
+	if len(o.ReplicationDestinations) == 0 {
+		return nil
+	}
+	if o.RegistryOrganisation == "" {
+		return fmt.Errorf("no registry organisation to limit the replication to so specify --organisation to replicate its repositories")
+	}
+	regions := o.RepositoryRegions()
+	registryID := o.GetRegistryID()
+	for _, text := range o.ReplicationDestinations {
+		region, destinationID, _ := strings.Cut(text, "=")
+		region = strings.TrimSpace(region)
+		destinationID = strings.TrimSpace(destinationID)
+		if destinationID != "" && destinationID != registryID {
+			continue
+		}
+		if region == o.AWSRegion {
+			return fmt.Errorf("replication destination '%s' is the source region of the registry so specify another region or registry ID", text)
+		}
+		if stringhelpers.StringArrayIndex(regions, region) >= 0 {
+			return fmt.Errorf("replication destination '%s' is also one of the --regions the repositories are created in so only specify it once", text)
+		}
+	}
+	return nil
+}
+
+// EnsureReplicationConfiguration ensures the registry replication configuration has a rule replicating the
+// repositories of the organisation to the replication destinations. Rules for other repository filters are left
+// unchanged and the configuration is only put if the rule is missing or has different destinations
+func (o *Options) EnsureReplicationConfiguration() error {
+	if len(o.ReplicationDestinations) == 0 {
+		return nil
+	}
+	err := o.ValidateReplication()
+	if err != nil {
+		return err
+	}
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+
+	destinations, err := o.replicationDestinations()
+	if err != nil {
+		return err
+	}
+	filters := o.replicationFilters()
+
+	registry, err := client.DescribeRegistry(ctx, &ecr.DescribeRegistryInput{})
+	if err != nil {
+		return fmt.Errorf("failed to describe the ECR registry: %w", err)
+	}
+	config := &types.ReplicationConfiguration{}
+	if registry.ReplicationConfiguration != nil {
+		config.Rules = append(config.Rules, registry.ReplicationConfiguration.Rules...)
+	}
+
+	found := false
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if repositoryFiltersKey(rule.RepositoryFilters) != repositoryFiltersKey(filters) {
+			continue
+		}
+		found = true
+		if replicationDestinationsKey(rule.Destinations) == replicationDestinationsKey(destinations) {
+			log.Logger().Debugf("the ECR replication configuration is up to date")
+			return nil
+		}
+		rule.Destinations = destinations
+	}
+	if !found {
+		config.Rules = append(config.Rules, types.ReplicationRule{
+			Destinations:      destinations,
+			RepositoryFilters: filters,
+		})
+	}
+
+	description := fmt.Sprintf("replicating %s to %s", repositoryFiltersKey(filters), replicationDestinationsKey(destinations))
+	if o.DryRun {
+		log.Logger().Infof("would put the ECR replication configuration %s", termcolor.ColorInfo(description))
+		return nil
+	}
+	_, err = client.PutReplicationConfiguration(ctx, &ecr.PutReplicationConfigurationInput{
+		ReplicationConfiguration: config,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put the ECR replication configuration %s: %w", description, err)
+	}
+	log.Logger().Infof("Put the ECR replication configuration %s", termcolor.ColorInfo(description))
+	return nil
+}
+
+// replicationDestinations parses the destinations of the form region or region=registryID defaulting the
+// registry ID to the registry of the repositories
+func (o *Options) replicationDestinations() ([]types.ReplicationDestination, error) {
+	var answer []types.ReplicationDestination
+	for _, text := range o.ReplicationDestinations {
+		region, registryID, _ := strings.Cut(text, "=")
+		region = strings.TrimSpace(region)
+		registryID = strings.TrimSpace(registryID)
+		if region == "" {
+			return nil, fmt.Errorf("invalid replication destination '%s' should be of the form region or region=registryID", text)
+		}
+		if registryID == "" {
+			registryID = o.GetRegistryID()
+			if registryID == "" {
+				var err error
+				registryID, err = o.GetCallerAccountID()
+				if err != nil {
+					return nil, fmt.Errorf("failed to find the registry ID of replication destination %s: %w", text, err)
+				}
+			}
+		}
+		if !accountIDRegex.MatchString(registryID) {
+			return nil, fmt.Errorf("invalid registry ID '%s' of replication destination '%s' should be a 12 digit AWS account ID", registryID, text)
+		}
+		answer = append(answer, types.ReplicationDestination{
+			Region:     aws.String(region),
+			RegistryId: aws.String(registryID),
+		})
+	}
+	return answer, nil
+}
+
+// replicationFilters returns the filter of the organisation prefix
+func (o *Options) replicationFilters() []types.RepositoryFilter {
+	return []types.RepositoryFilter{
+		{
+			Filter:     aws.String(o.organisationPrefix()),
+			FilterType: types.RepositoryFilterTypePrefixMatch,
+		},
+	}
+}
+
+func repositoryFiltersKey(filters []types.RepositoryFilter) string {
+	if len(filters) == 0 {
+		return "all repositories"
+	}
+	var values []string
+	for _, f := range filters {
+		values = append(values, string(f.FilterType)+":"+aws.ToString(f.Filter))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
+
+func replicationDestinationsKey(destinations []types.ReplicationDestination) string {
+	var values []string
+	for _, d := range destinations {
+		values = append(values, aws.ToString(d.Region)+"="+aws.ToString(d.RegistryId))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,164 @@
+package ecrs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// RepositoryPolicyVersion the IAM policy language version of generated repository policies
+	RepositoryPolicyVersion = "2012-10-17"
+
+	// SidAllowPull the statement ID of the generated statement allowing accounts to pull
+	SidAllowPull = "JxRegistryAllowPull"
+
+	// SidAllowPush the statement ID of the generated statement allowing roles to push
+	SidAllowPush = "JxRegistryAllowPush"
+
+	// SidAllowPullOrg the statement ID of the generated statement allowing AWS organizations to pull
+	SidAllowPullOrg = "JxRegistryAllowPullOrg"
+
+	// ConditionPrincipalOrgID the condition key of the AWS organization of the principal
+	ConditionPrincipalOrgID = "aws:PrincipalOrgID"
+)
+
+var (
+	// PullActions the ECR actions needed to pull images
+	PullActions = []string{
+		"ecr:BatchCheckLayerAvailability",
+		"ecr:BatchGetImage",
+		"ecr:GetDownloadUrlForLayer",
+	}
+
+	// PushActions the ECR actions needed to push and pull images
+	PushActions = append(append([]string{}, PullActions...),
+		"ecr:CompleteLayerUpload",
+		"ecr:InitiateLayerUpload",
+		"ecr:PutImage",
+		"ecr:UploadLayerPart",
+	)
+)
+
+// RepositoryPolicyStatement a statement in an ECR repository policy
+type RepositoryPolicyStatement struct {
+	Sid       string                            `json:"Sid"`
+	Effect    string                            `json:"Effect"`
+	Principal interface{}                       `json:"Principal"`
+	Action    []string                          `json:"Action"`
+	Condition map[string]map[string]interface{} `json:"Condition,omitempty"`
+}
+
+// RepositoryPolicyBuilder generates the statements of a repository policy allowing cross account access
+type RepositoryPolicyBuilder struct {
+	// AllowPullAccounts the account IDs allowed to pull images
+	AllowPullAccounts []string
+
+	// AllowPushRoleARNs the IAM role ARNs allowed to push images
+	AllowPushRoleARNs []string
+
+	// AllowPullOrgIDs the AWS organization IDs whose principals are allowed to pull images
+	AllowPullOrgIDs []string
+}
+
+// IsEmpty returns true if no access has been specified
+func (b *RepositoryPolicyBuilder) IsEmpty() bool {
+	return len(b.AllowPullAccounts) == 0 && len(b.AllowPushRoleARNs) == 0 && len(b.AllowPullOrgIDs) == 0
+}
+
+// Validate validates the account IDs, role ARNs and organization IDs
+func (b *RepositoryPolicyBuilder) Validate() error {
+	for _, account := range b.AllowPullAccounts {
+		if !accountIDRegex.MatchString(account) {
+			return fmt.Errorf("invalid --allow-pull-account '%s' should be a 12 digit AWS account ID", account)
+		}
+	}
+	for _, arn := range b.AllowPushRoleARNs {
+		if !strings.HasPrefix(arn, "arn:") || !strings.Contains(arn, ":role/") {
+			return fmt.Errorf("invalid --allow-push-role-arn '%s' should be an IAM role ARN", arn)
+		}
+	}
+	for _, id := range b.AllowPullOrgIDs {
+		if !strings.HasPrefix(id, "o-") {
+			return fmt.Errorf("invalid --allow-pull-org-id '%s' should be an AWS organization ID such as o-abc123", id)
+		}
+	}
+	return nil
+}
+
+// Statements returns the generated statements
+func (b *RepositoryPolicyBuilder) Statements() []RepositoryPolicyStatement {
+	var answer []RepositoryPolicyStatement
+	if len(b.AllowPullAccounts) > 0 {
+		var principals []string
+		for _, account := range b.AllowPullAccounts {
+			principals = append(principals, "arn:aws:iam::"+account+":root")
+		}
+		answer = append(answer, RepositoryPolicyStatement{
+			Sid:       SidAllowPull,
+			Effect:    "Allow",
+			Principal: map[string][]string{"AWS": principals},
+			Action:    PullActions,
+		})
+	}
+	if len(b.AllowPushRoleARNs) > 0 {
+		answer = append(answer, RepositoryPolicyStatement{
+			Sid:       SidAllowPush,
+			Effect:    "Allow",
+			Principal: map[string][]string{"AWS": b.AllowPushRoleARNs},
+			Action:    PushActions,
+		})
+	}
+	if len(b.AllowPullOrgIDs) > 0 {
+		answer = append(answer, RepositoryPolicyStatement{
+			Sid:       SidAllowPullOrg,
+			Effect:    "Allow",
+			Principal: "*",
+			Action:    PullActions,
+			Condition: map[string]map[string]interface{}{
+				"StringEquals": {
+					ConditionPrincipalOrgID: b.AllowPullOrgIDs,
+				},
+			},
+		})
+	}
+	return answer
+}
+
+// Merge merges the generated statements into the policy replacing any statements with the same Sid and keeping
+// all other statements. If the policy is empty a new policy is created
+func (b *RepositoryPolicyBuilder) Merge(policy string) (string, error) {
+	m := map[string]interface{}{}
+	if strings.TrimSpace(policy) != "" {
+		err := json.Unmarshal([]byte(policy), &m)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse repository policy: %w", err)
+		}
+	}
+	if m["Version"] == nil {
+		m["Version"] = RepositoryPolicyVersion
+	}
+	var statements []interface{}
+	for _, s := range b.Statements() {
+		statement, err := toJSONMap(s)
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, statement)
+	}
+	m["Statement"] = mergeStatements(toSlice(m["Statement"]), statements, true)
+	return marshalRepositoryPolicy(m)
+}
+
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %#v: %w", v, err)
+	}
+	m := map[string]interface{}{}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", string(data), err)
+	}
+	return m, nil
+}
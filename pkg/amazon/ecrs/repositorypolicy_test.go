@@ -0,0 +1,106 @@
+package ecrs_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryPolicyBuilderMerge(t *testing.T) {
+	builder := &ecrs.RepositoryPolicyBuilder{
+		AllowPullAccounts: []string{"111111111111", "222222222222"},
+		AllowPushRoleARNs: []string{"arn:aws:iam::123456789012:role/pusher"},
+		AllowPullOrgIDs:   []string{"o-abc123"},
+	}
+	require.NoError(t, builder.Validate(), "failed to validate")
+
+	existing := `{
+  "Version": "2008-10-17",
+  "Statement": [
+    {
+      "Sid": "Existing",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::333333333333:root"},
+      "Action": "ecr:BatchGetImage"
+    },
+    {
+      "Sid": "JxRegistryAllowPull",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::444444444444:root"},
+      "Action": "ecr:BatchGetImage"
+    }
+  ]
+}`
+	policy, err := builder.Merge(existing)
+	require.NoError(t, err, "failed to merge")
+
+	expected := `{
+  "Version": "2008-10-17",
+  "Statement": [
+    {
+      "Sid": "Existing",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::333333333333:root"},
+      "Action": "ecr:BatchGetImage"
+    },
+    {
+      "Sid": "JxRegistryAllowPull",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["arn:aws:iam::111111111111:root", "arn:aws:iam::222222222222:root"]},
+      "Action": ["ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"]
+    },
+    {
+      "Sid": "JxRegistryAllowPush",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:role/pusher"},
+      "Action": [
+        "ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer",
+        "ecr:CompleteLayerUpload", "ecr:InitiateLayerUpload", "ecr:PutImage", "ecr:UploadLayerPart"
+      ]
+    },
+    {
+      "Sid": "JxRegistryAllowPullOrg",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["ecr:BatchCheckLayerAvailability", "ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"],
+      "Condition": {"StringEquals": {"aws:PrincipalOrgID": "o-abc123"}}
+    }
+  ]
+}`
+	equal, err := ecrs.RepositoryPoliciesEqual(expected, policy)
+	require.NoError(t, err, "failed to compare policies")
+	assert.True(t, equal, "merged policy %s", policy)
+
+	// lets check merging again doesn't change the policy
+	again, err := builder.Merge(policy)
+	require.NoError(t, err, "failed to merge")
+	assert.Equal(t, policy, again, "merge should be idempotent")
+
+	// lets check we create a new policy if there is none
+	policy, err = builder.Merge("")
+	require.NoError(t, err, "failed to merge")
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(policy), &m), "failed to parse policy")
+	assert.Equal(t, ecrs.RepositoryPolicyVersion, m["Version"])
+	assert.Len(t, m["Statement"], 3)
+}
+
+func TestRepositoryPolicyBuilderValidate(t *testing.T) {
+	invalid := []ecrs.RepositoryPolicyBuilder{
+		{
+			AllowPullAccounts: []string{"1234"},
+		},
+		{
+			AllowPushRoleARNs: []string{"pusher"},
+		},
+		{
+			AllowPullOrgIDs: []string{"abc123"},
+		},
+	}
+	for i := range invalid {
+		assert.Error(t, invalid[i].Validate(), "should fail for %#v", invalid[i])
+	}
+}
@@ -0,0 +1,156 @@
+package ecrs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// RepositoryRules an ordered list of rules overriding the policies and settings of matching repositories
+type RepositoryRules struct {
+	Rules []RepositoryRule `json:"rules"`
+}
+
+// RepositoryRule overrides the policies and settings of the repositories matching the glob or regex.
+// Empty values are not overridden
+type RepositoryRule struct {
+	// Glob the glob of the repository name such as myorg/*-cache
+	Glob string `json:"glob,omitempty"`
+
+	// Regex the regular expression matching the whole repository name
+	Regex string `json:"regex,omitempty"`
+
+	// LifecyclePolicy the lifecycle policy of the repository
+	LifecyclePolicy string `json:"lifecyclePolicy,omitempty"`
+
+	// RepositoryPolicy the repository policy of the repository
+	RepositoryPolicy string `json:"repositoryPolicy,omitempty"`
+
+	// ImageTagMutability the image tag mutability of the repository
+	ImageTagMutability string `json:"imageTagMutability,omitempty"`
+
+	// ImageTagMutabilityExclusions the wildcard tag filters excluded from the image tag mutability
+	ImageTagMutabilityExclusions []string `json:"imageTagMutabilityExclusions,omitempty"`
+
+	// ScanOnPush whether images should be scanned on push: true or false
+	ScanOnPush string `json:"scanOnPush,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// LoadRepositoryRules loads the repository rules from the given YAML or JSON file
+func LoadRepositoryRules(file string) (*RepositoryRules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository rules file %s: %w", file, err)
+	}
+	rules := &RepositoryRules{}
+	err = yaml.UnmarshalStrict(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository rules file %s: %w", file, err)
+	}
+	err = rules.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository rules file %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// Validate validates the rules and compiles any regular expressions
+func (r *RepositoryRules) Validate() error {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		switch {
+		case rule.Glob != "" && rule.Regex != "":
+			return fmt.Errorf("rule %d cannot specify both a glob and a regex", i+1)
+		case rule.Glob != "":
+			_, err := path.Match(rule.Glob, "")
+			if err != nil {
+				return fmt.Errorf("rule %d has an invalid glob '%s': %w", i+1, rule.Glob, err)
+			}
+		case rule.Regex != "":
+			var err error
+			rule.regex, err = regexp.Compile("^(?:" + rule.Regex + ")$")
+			if err != nil {
+				return fmt.Errorf("rule %d has an invalid regex '%s': %w", i+1, rule.Regex, err)
+			}
+		default:
+			return fmt.Errorf("rule %d must specify a glob or a regex", i+1)
+		}
+	}
+	return nil
+}
+
+// Match returns the first rule matching the repository name or nil if there is no match
+func (r *RepositoryRules) Match(repoName string) *RepositoryRule {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Matches(repoName) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the rule matches the repository name
+func (r *RepositoryRule) Matches(repoName string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(repoName)
+	}
+	matched, err := path.Match(r.Glob, repoName)
+	return err == nil && matched
+}
+
+// String returns the pattern of the rule
+func (r *RepositoryRule) String() string {
+	if r.Regex != "" {
+		return "regex " + r.Regex
+	}
+	return "glob " + r.Glob
+}
+
+// RepositoryOptions returns the options for the repository name after applying the first matching repository rule.
+// If there are no matching rules the options are returned unchanged
+func (o *Options) RepositoryOptions(repoName string) (*Options, error) {
+	if o.RepositoryRulesFile == "" {
+		return o, nil
+	}
+	if o.repositoryRules == nil {
+		var err error
+		o.repositoryRules, err = LoadRepositoryRules(o.RepositoryRulesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rule := o.repositoryRules.Match(repoName)
+	if rule == nil {
+		return o, nil
+	}
+	log.Logger().Infof("using repository rule %s for ECR repository %s", termcolor.ColorInfo(rule.String()), termcolor.ColorInfo(repoName))
+
+	ro := *o
+	if rule.LifecyclePolicy != "" {
+		ro.CreateECRLifeCyclePolicy = true
+		ro.ECRLifecyclePolicy = rule.LifecyclePolicy
+		ro.ExpireUntaggedAfterDays = 0
+		ro.ExpireTagPrefixes = nil
+		ro.KeepLastNTagged = 0
+	}
+	if rule.RepositoryPolicy != "" {
+		ro.CreateECRRepositoryPolicy = true
+		ro.ECRRepositoryPolicy = rule.RepositoryPolicy
+	}
+	if rule.ImageTagMutability != "" {
+		ro.ImageTagMutability = rule.ImageTagMutability
+		ro.ImageTagMutabilityExclusions = rule.ImageTagMutabilityExclusions
+	}
+	if rule.ScanOnPush != "" {
+		ro.ScanOnPush = rule.ScanOnPush
+	}
+	return &ro, nil
+}
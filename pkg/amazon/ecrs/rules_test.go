@@ -0,0 +1,120 @@
+package ecrs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryRulesMatch(t *testing.T) {
+	rules := &ecrs.RepositoryRules{
+		Rules: []ecrs.RepositoryRule{
+			{
+				Glob:       "myorg/*-cache",
+				ScanOnPush: "false",
+			},
+			{
+				Regex:              "myorg/legacy-.*",
+				ImageTagMutability: "MUTABLE",
+			},
+			{
+				Glob:               "myorg/*",
+				ImageTagMutability: "IMMUTABLE",
+			},
+		},
+	}
+	require.NoError(t, rules.Validate(), "failed to validate rules")
+
+	testCases := []struct {
+		repoName string
+		expected string
+	}{
+		{
+			repoName: "myorg/myapp-cache",
+			expected: "glob myorg/*-cache",
+		},
+		{
+			repoName: "myorg/legacy-app-cache",
+			expected: "glob myorg/*-cache",
+		},
+		{
+			repoName: "myorg/legacy-app",
+			expected: "regex myorg/legacy-.*",
+		},
+		{
+			repoName: "myorg/myapp",
+			expected: "glob myorg/*",
+		},
+		{
+			repoName: "otherorg/legacy-app",
+		},
+		{
+			repoName: "myorg/nested/myapp",
+		},
+	}
+	for _, tc := range testCases {
+		rule := rules.Match(tc.repoName)
+		if tc.expected == "" {
+			assert.Nil(t, rule, "should not match for %s", tc.repoName)
+			continue
+		}
+		require.NotNil(t, rule, "should match for %s", tc.repoName)
+		assert.Equal(t, tc.expected, rule.String(), "for %s", tc.repoName)
+	}
+}
+
+func TestRepositoryRulesValidate(t *testing.T) {
+	invalid := []ecrs.RepositoryRule{
+		{},
+		{
+			Glob:  "myorg/*",
+			Regex: "myorg/.*",
+		},
+		{
+			Glob: "myorg/[",
+		},
+		{
+			Regex: "myorg/(",
+		},
+	}
+	for _, rule := range invalid {
+		rules := &ecrs.RepositoryRules{
+			Rules: []ecrs.RepositoryRule{rule},
+		}
+		assert.Error(t, rules.Validate(), "should fail for %#v", rule)
+	}
+}
+
+func TestRepositoryOptions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(file, []byte(`rules:
+- glob: myorg/*-cache
+  scanOnPush: "false"
+  lifecyclePolicy: |
+    {"rules": [{"rulePriority": 1, "selection": {"tagStatus": "any", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}]}
+`), 0o600)
+	require.NoError(t, err, "failed to write file")
+
+	o := &ecrs.Options{
+		RepositoryRulesFile: file,
+		ScanOnPush:          "true",
+		KeepLastNTagged:     10,
+	}
+
+	ro, err := o.RepositoryOptions("myorg/myapp")
+	require.NoError(t, err, "failed to get repository options")
+	assert.Same(t, o, ro, "should not override options when no rule matches")
+
+	ro, err = o.RepositoryOptions("myorg/myapp-cache")
+	require.NoError(t, err, "failed to get repository options")
+	assert.Equal(t, "false", ro.ScanOnPush)
+	assert.True(t, ro.CreateECRLifeCyclePolicy, "should create the lifecycle policy")
+	assert.Contains(t, ro.ECRLifecyclePolicy, `"tagStatus": "any"`)
+	assert.Equal(t, 0, ro.KeepLastNTagged, "should clear the lifecycle policy expiry settings")
+	assert.Equal(t, "true", o.ScanOnPush, "should not modify the original options")
+	assert.Equal(t, 10, o.KeepLastNTagged, "should not modify the original options")
+}
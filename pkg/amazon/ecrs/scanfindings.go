@@ -0,0 +1,231 @@
+package ecrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+var (
+	// Severities the finding severities from the most to the least severe
+	Severities = []string{
+		string(types.FindingSeverityCritical),
+		string(types.FindingSeverityHigh),
+		string(types.FindingSeverityMedium),
+		string(types.FindingSeverityLow),
+		string(types.FindingSeverityInformational),
+		string(types.FindingSeverityUndefined),
+	}
+)
+
+// ImageScanResult the findings of the scan of an image
+type ImageScanResult struct {
+	Repository     string             `json:"repository"`
+	Image          string             `json:"image"`
+	Status         string             `json:"status"`
+	CompletedAt    *time.Time         `json:"completedAt,omitempty"`
+	SeverityCounts map[string]int     `json:"severityCounts"`
+	Findings       []ImageScanFinding `json:"findings,omitempty"`
+}
+
+// ImageScanFinding a summary of a vulnerability found by the scan of an image
+type ImageScanFinding struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+	Package  string `json:"package,omitempty"`
+	Version  string `json:"version,omitempty"`
+	URI      string `json:"uri,omitempty"`
+}
+
+// ImageReference resolves the image of the form name:tag or name@digest to the repository name and image ID.
+// Any registry host is removed and the repository name is resolved in the same way as LazyCreateRegistry unless
+// the name already starts with the registry organisation. The tag defaults to latest
+func (o *Options) ImageReference(image string) (string, *types.ImageIdentifier, error) {
+	name := strings.TrimSpace(image)
+	imageID := &types.ImageIdentifier{}
+	if idx := strings.Index(name, "@"); idx >= 0 {
+		imageID.ImageDigest = aws.String(name[idx+1:])
+		name = name[:idx]
+	} else if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		imageID.ImageTag = aws.String(name[idx+1:])
+		name = name[:idx]
+	}
+	if imageID.ImageDigest == nil && aws.ToString(imageID.ImageTag) == "" {
+		imageID.ImageTag = aws.String("latest")
+	}
+
+	paths := strings.SplitN(name, "/", 2)
+	if len(paths) == 2 && (strings.ContainsAny(paths[0], ".:") || paths[0] == "localhost") {
+		name = paths[1]
+	}
+	if len(name) <= 2 || (imageID.ImageDigest != nil && *imageID.ImageDigest == "") {
+		return "", nil, fmt.Errorf("invalid image '%s' should be of the form name:tag or name@digest", image)
+	}
+
+	prefix := o.organisationPrefix()
+	if prefix != "" && strings.HasPrefix(strings.ToLower(name), prefix) {
+		return strings.ToLower(name), imageID, nil
+	}
+	return o.RepositoryName(name), imageID, nil
+}
+
+// WaitForImageScanFindings waits up to the timeout for the scan of the image to complete then returns all of its findings.
+// A missing scan is retried as the scan of a recently pushed image may not have started yet
+func (o *Options) WaitForImageScanFindings(repoName string, imageID *types.ImageIdentifier, timeout, pollInterval time.Duration) (*ImageScanResult, error) {
+	client, err := o.GetECRClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx := o.GetContext()
+	image := imageDescription(imageID)
+
+	input := &ecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(repoName),
+		ImageId:        imageID,
+		RegistryId:     o.registryIDPointer(),
+	}
+	log.Logger().Infof("waiting for the scan of image %s in ECR repository %s to complete", termcolor.ColorInfo(image), termcolor.ColorInfo(repoName))
+	waiter := ecr.NewImageScanCompleteWaiter(client, func(w *ecr.ImageScanCompleteWaiterOptions) {
+		w.MinDelay = pollInterval
+		if w.MaxDelay < pollInterval {
+			w.MaxDelay = pollInterval
+		}
+		retryable := w.Retryable
+		w.Retryable = func(ctx context.Context, input *ecr.DescribeImageScanFindingsInput, output *ecr.DescribeImageScanFindingsOutput, err error) (bool, error) {
+			var notFoundErr *types.ScanNotFoundException
+			if errors.As(err, &notFoundErr) {
+				return true, nil
+			}
+			return retryable(ctx, input, output, err)
+		}
+	})
+	_, err = waiter.WaitForOutput(ctx, input, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for the scan of image %s in ECR repository %s: %w", image, repoName, err)
+	}
+
+	result := &ImageScanResult{
+		Repository: repoName,
+		Image:      image,
+	}
+	paginator := ecr.NewDescribeImageScanFindingsPaginator(client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the scan findings of image %s in ECR repository %s: %w", image, repoName, err)
+		}
+		if output.ImageScanStatus != nil {
+			result.Status = string(output.ImageScanStatus.Status)
+		}
+		findings := output.ImageScanFindings
+		if findings == nil {
+			continue
+		}
+		if result.SeverityCounts == nil && findings.FindingSeverityCounts != nil {
+			result.SeverityCounts = map[string]int{}
+			for k, v := range findings.FindingSeverityCounts {
+				result.SeverityCounts[k] = int(v)
+			}
+		}
+		if result.CompletedAt == nil {
+			result.CompletedAt = findings.ImageScanCompletedAt
+		}
+		for i := range findings.Findings {
+			result.Findings = append(result.Findings, toImageScanFinding(&findings.Findings[i]))
+		}
+		for i := range findings.EnhancedFindings {
+			result.Findings = append(result.Findings, toEnhancedImageScanFinding(&findings.EnhancedFindings[i]))
+		}
+	}
+	if result.SeverityCounts == nil {
+		result.SeverityCounts = map[string]int{}
+		for i := range result.Findings {
+			result.SeverityCounts[result.Findings[i].Severity]++
+		}
+	}
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		return severityRank(result.Findings[i].Severity) < severityRank(result.Findings[j].Severity)
+	})
+	return result, nil
+}
+
+// CheckThresholds returns an error if the number of findings of any severity exceeds its maximum.
+// Severities without a maximum or with a negative maximum are not checked
+func (r *ImageScanResult) CheckThresholds(maximums map[string]int) error {
+	var failures []string
+	for _, severity := range Severities {
+		maximum, ok := maximums[severity]
+		if !ok || maximum < 0 {
+			continue
+		}
+		count := r.SeverityCounts[severity]
+		if count > maximum {
+			failures = append(failures, fmt.Sprintf("%d %s findings, more than the maximum of %d", count, severity, maximum))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("image %s in ECR repository %s has %s", r.Image, r.Repository, strings.Join(failures, " and "))
+	}
+	return nil
+}
+
+func toImageScanFinding(f *types.ImageScanFinding) ImageScanFinding {
+	answer := ImageScanFinding{
+		Name:     aws.ToString(f.Name),
+		Severity: string(f.Severity),
+		URI:      aws.ToString(f.Uri),
+	}
+	for _, a := range f.Attributes {
+		switch aws.ToString(a.Key) {
+		case "package_name":
+			answer.Package = aws.ToString(a.Value)
+		case "package_version":
+			answer.Version = aws.ToString(a.Value)
+		}
+	}
+	return answer
+}
+
+func toEnhancedImageScanFinding(f *types.EnhancedImageScanFinding) ImageScanFinding {
+	answer := ImageScanFinding{
+		Name:     aws.ToString(f.Title),
+		Severity: aws.ToString(f.Severity),
+	}
+	details := f.PackageVulnerabilityDetails
+	if details != nil {
+		if details.VulnerabilityId != nil {
+			answer.Name = aws.ToString(details.VulnerabilityId)
+		}
+		answer.URI = aws.ToString(details.SourceUrl)
+		if len(details.VulnerablePackages) > 0 {
+			answer.Package = aws.ToString(details.VulnerablePackages[0].Name)
+			answer.Version = aws.ToString(details.VulnerablePackages[0].Version)
+		}
+	}
+	return answer
+}
+
+func severityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+func imageDescription(imageID *types.ImageIdentifier) string {
+	if imageID.ImageDigest != nil {
+		return aws.ToString(imageID.ImageDigest)
+	}
+	return aws.ToString(imageID.ImageTag)
+}
@@ -0,0 +1,122 @@
+package ecrs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageReference(t *testing.T) {
+	o := &ecrs.Options{
+		RegistryOrganisation: "MyOrg",
+	}
+	testCases := []struct {
+		image    string
+		repoName string
+		tag      string
+		digest   string
+	}{
+		{
+			image:    "myapp:1.2.3",
+			repoName: "myorg/myapp",
+			tag:      "1.2.3",
+		},
+		{
+			image:    "myapp",
+			repoName: "myorg/myapp",
+			tag:      "latest",
+		},
+		{
+			image:    "123456789012.dkr.ecr.us-east-1.amazonaws.com/myorg/myapp:1.2.3",
+			repoName: "myorg/myapp",
+			tag:      "1.2.3",
+		},
+		{
+			image:    "localhost:5000/myapp@sha256:abc",
+			repoName: "myorg/myapp",
+			digest:   "sha256:abc",
+		},
+		{
+			image:    "other/myapp:1.0.0",
+			repoName: "myorg/other/myapp",
+			tag:      "1.0.0",
+		},
+	}
+	for _, tc := range testCases {
+		repoName, imageID, err := o.ImageReference(tc.image)
+		require.NoError(t, err, "failed to resolve %s", tc.image)
+		assert.Equal(t, tc.repoName, repoName, "repository name for %s", tc.image)
+		if tc.digest != "" {
+			assert.Equal(t, tc.digest, aws.ToString(imageID.ImageDigest), "digest for %s", tc.image)
+			assert.Nil(t, imageID.ImageTag, "tag for %s", tc.image)
+		} else {
+			assert.Equal(t, tc.tag, aws.ToString(imageID.ImageTag), "tag for %s", tc.image)
+		}
+	}
+
+	_, _, err := o.ImageReference("myapp@")
+	assert.Error(t, err, "should fail with an empty digest")
+}
+
+func TestWaitForImageScanFindings(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	fakeECR.ScanInProgressCalls = 2
+	fakeECR.ScanFindings["myorg/myapp:1.2.3"] = &types.ImageScanFindings{
+		FindingSeverityCounts: map[string]int32{
+			"HIGH":     2,
+			"CRITICAL": 1,
+		},
+		Findings: []types.ImageScanFinding{
+			{
+				Name:     aws.String("CVE-2024-0002"),
+				Severity: types.FindingSeverityHigh,
+				Attributes: []types.Attribute{
+					{Key: aws.String("package_name"), Value: aws.String("openssl")},
+					{Key: aws.String("package_version"), Value: aws.String("3.0.1")},
+				},
+			},
+			{
+				Name:     aws.String("CVE-2024-0001"),
+				Severity: types.FindingSeverityCritical,
+			},
+			{
+				Name:     aws.String("CVE-2024-0003"),
+				Severity: types.FindingSeverityHigh,
+			},
+		},
+	}
+	o := &ecrs.Options{
+		ECRClient:            fakeECR,
+		RegistryOrganisation: "myorg",
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	repoName, imageID, err := o.ImageReference("myapp:1.2.3")
+	require.NoError(t, err)
+	result, err := o.WaitForImageScanFindings(repoName, imageID, time.Minute, time.Millisecond)
+	require.NoError(t, err, "failed to wait for scan findings")
+
+	assert.Equal(t, 0, fakeECR.ScanInProgressCalls, "should have waited for the scan to complete")
+	assert.Equal(t, "COMPLETE", result.Status)
+	assert.Equal(t, 1, result.SeverityCounts["CRITICAL"])
+	assert.Equal(t, 2, result.SeverityCounts["HIGH"])
+	require.Len(t, result.Findings, 3)
+	assert.Equal(t, "CVE-2024-0001", result.Findings[0].Name, "should sort the most severe findings first")
+	assert.Equal(t, "openssl", result.Findings[1].Package)
+	assert.Equal(t, "3.0.1", result.Findings[1].Version)
+
+	assert.NoError(t, result.CheckThresholds(map[string]int{"CRITICAL": 1, "HIGH": 5, "MEDIUM": -1}))
+	err = result.CheckThresholds(map[string]int{"CRITICAL": 0, "HIGH": 1})
+	require.Error(t, err, "should fail when the thresholds are exceeded")
+	assert.Equal(t, "image 1.2.3 in ECR repository myorg/myapp has 1 CRITICAL findings, more than the maximum of 0 and 2 HIGH findings, more than the maximum of 1", err.Error())
+
+	_, err = o.WaitForImageScanFindings("myorg/missing", imageID, 20*time.Millisecond, 5*time.Millisecond)
+	require.Error(t, err, "should time out if the scan is not found")
+}
@@ -0,0 +1,193 @@
+package ecrs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ScanTypes the valid registry scan types
+	ScanTypes = []string{string(types.ScanTypeBasic), string(types.ScanTypeEnhanced)}
+
+	// scanFrequencies the valid scan frequencies of each scan type
+	scanFrequencies = map[types.ScanType][]types.ScanFrequency{
+		types.ScanTypeBasic:    {types.ScanFrequencyScanOnPush},
+		types.ScanTypeEnhanced: {types.ScanFrequencyScanOnPush, types.ScanFrequencyContinuousScan},
+	}
+)
+
+// AddScanningFlags adds the flags for the registry scanning configuration
+func (o *Options) AddScanningFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.ScanType, "scan-type", "", o.ScanType, "The registry scan type: BASIC or ENHANCED. Can be specified in $ECR_SCAN_TYPE.")
+	cmd.Flags().StringVarP(&o.ScanFrequency, "scan-frequency", "", o.ScanFrequency, "The default scan frequency of the scan filters: SCAN_ON_PUSH or for ENHANCED scanning CONTINUOUS_SCAN. Can be specified in $ECR_SCAN_FREQUENCY.")
+	cmd.Flags().StringArrayVarP(&o.ScanFilters, "scan-filter", "", o.ScanFilters, "The wildcard repository filter to scan of the form filter or filter=frequency. Defaults to the repositories of the organisation. Can be specified multiple times or in the comma separated $ECR_SCAN_FILTERS.")
+}
+
+// EnsureRegistryScanningConfiguration ensures the registry scanning configuration uses the scan type and has rules
+// scanning the repositories matching the scan filters at their frequency. Filters of other rules are left unchanged
+// and the configuration is only put if the scan type or rules change
+func (o *Options) EnsureRegistryScanningConfiguration() error {
+	scanType, desired, err := o.desiredScanningFilters()
+	if err != nil {
+		return err
+	}
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+
+	output, err := client.GetRegistryScanningConfiguration(ctx, &ecr.GetRegistryScanningConfigurationInput{})
+	if err != nil {
+		return fmt.Errorf("failed to get the ECR registry scanning configuration: %w", err)
+	}
+	current := output.ScanningConfiguration
+	if current == nil {
+		current = &types.RegistryScanningConfiguration{}
+	}
+
+	config := mergeScanningRules(current, scanType, desired)
+	currentKey := scanningConfigurationKey(current)
+	key := scanningConfigurationKey(config)
+	if currentKey == key {
+		log.Logger().Debugf("the ECR registry scanning configuration is up to date")
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would put the ECR registry scanning configuration %s replacing %s", termcolor.ColorInfo(key), termcolor.ColorInfo(currentKey))
+		return nil
+	}
+	_, err = client.PutRegistryScanningConfiguration(ctx, &ecr.PutRegistryScanningConfigurationInput{
+		Rules:    config.Rules,
+		ScanType: config.ScanType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put the ECR registry scanning configuration %s: %w", key, err)
+	}
+	log.Logger().Infof("Put the ECR registry scanning configuration %s", termcolor.ColorInfo(key))
+	return nil
+}
+
+// desiredScanningFilters validates the scan type and returns the desired scan frequency of each filter.
+// If no filters are specified the repositories of the organisation are scanned
+func (o *Options) desiredScanningFilters() (types.ScanType, map[string]types.ScanFrequency, error) {
+	scanType := types.ScanType(strings.ToUpper(o.ScanType))
+	allowed, ok := scanFrequencies[scanType]
+	if !ok {
+		return "", nil, options.InvalidOption("scan-type", o.ScanType, ScanTypes)
+	}
+	var allowedValues []string
+	for _, f := range allowed {
+		allowedValues = append(allowedValues, string(f))
+	}
+
+	filters := o.ScanFilters
+	if len(filters) == 0 {
+		if o.RegistryOrganisation == "" {
+			return "", nil, fmt.Errorf("no registry organisation to default the scan filter to so specify --organisation or use --scan-filter '*' to scan every repository in the registry")
+		}
+		filter := o.organisationPrefix() + "*"
+		log.Logger().Infof("defaulting the scan filter to %s", termcolor.ColorInfo(filter))
+		filters = []string{filter}
+	}
+	answer := map[string]types.ScanFrequency{}
+	for _, text := range filters {
+		filter, frequency, found := strings.Cut(text, "=")
+		filter = strings.TrimSpace(filter)
+		if !found {
+			frequency = o.ScanFrequency
+		}
+		frequency = strings.ToUpper(strings.TrimSpace(frequency))
+		if filter == "" {
+			return "", nil, fmt.Errorf("invalid scan filter '%s' should be of the form filter or filter=frequency", text)
+		}
+		if !containsScanFrequency(allowed, types.ScanFrequency(frequency)) {
+			return "", nil, fmt.Errorf("invalid scan frequency '%s' of scan filter '%s' for %s scanning. Possible values: %s",
+				frequency, filter, scanType, strings.Join(allowedValues, ", "))
+		}
+		answer[filter] = types.ScanFrequency(frequency)
+	}
+	return scanType, answer, nil
+}
+
+// mergeScanningRules returns the scanning configuration with the scan type where each desired filter is moved to the
+// rule of its frequency. Rules with a frequency which is not supported by the scan type are removed
+func mergeScanningRules(current *types.RegistryScanningConfiguration, scanType types.ScanType, desired map[string]types.ScanFrequency) *types.RegistryScanningConfiguration {
+	filters := map[types.ScanFrequency][]string{}
+	for _, rule := range current.Rules {
+		if !containsScanFrequency(scanFrequencies[scanType], rule.ScanFrequency) {
+			log.Logger().Warnf("removing the %s registry scanning rule as it is not supported by %s scanning", rule.ScanFrequency, scanType)
+			continue
+		}
+		for _, f := range rule.RepositoryFilters {
+			filter := aws.ToString(f.Filter)
+			if _, ok := desired[filter]; !ok {
+				filters[rule.ScanFrequency] = append(filters[rule.ScanFrequency], filter)
+			}
+		}
+	}
+	for filter, frequency := range desired {
+		filters[frequency] = append(filters[frequency], filter)
+	}
+
+	config := &types.RegistryScanningConfiguration{
+		ScanType: scanType,
+	}
+	for _, frequency := range scanFrequencies[scanType] {
+		values := filters[frequency]
+		if len(values) == 0 {
+			continue
+		}
+		sort.Strings(values)
+		rule := types.RegistryScanningRule{
+			ScanFrequency: frequency,
+		}
+		for _, filter := range values {
+			rule.RepositoryFilters = append(rule.RepositoryFilters, types.ScanningRepositoryFilter{
+				Filter:     aws.String(filter),
+				FilterType: types.ScanningRepositoryFilterTypeWildcard,
+			})
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+	return config
+}
+
+func containsScanFrequency(frequencies []types.ScanFrequency, frequency types.ScanFrequency) bool {
+	for _, f := range frequencies {
+		if f == frequency {
+			return true
+		}
+	}
+	return false
+}
+
+func scanningConfigurationKey(config *types.RegistryScanningConfiguration) string {
+	scanType := config.ScanType
+	if scanType == "" {
+		scanType = types.ScanTypeBasic
+	}
+	var rules []string
+	for _, rule := range config.Rules {
+		var filters []string
+		for _, f := range rule.RepositoryFilters {
+			filters = append(filters, aws.ToString(f.Filter))
+		}
+		sort.Strings(filters)
+		rules = append(rules, string(rule.ScanFrequency)+" of "+strings.Join(filters, ", "))
+	}
+	if len(rules) == 0 {
+		return string(scanType) + " scanning"
+	}
+	sort.Strings(rules)
+	return string(scanType) + " scanning with " + strings.Join(rules, " and ")
+}
@@ -0,0 +1,122 @@
+package ecrs_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureRegistryScanningConfiguration(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	fakeECR.Scanning = &types.RegistryScanningConfiguration{
+		ScanType: types.ScanTypeBasic,
+		Rules: []types.RegistryScanningRule{
+			{
+				ScanFrequency: types.ScanFrequencyScanOnPush,
+				RepositoryFilters: []types.ScanningRepositoryFilter{
+					{Filter: aws.String("other/*"), FilterType: types.ScanningRepositoryFilterTypeWildcard},
+					{Filter: aws.String("myorg/*"), FilterType: types.ScanningRepositoryFilterTypeWildcard},
+				},
+			},
+		},
+	}
+	o := &ecrs.Options{
+		ECRClient:            fakeECR,
+		RegistryOrganisation: "myorg",
+		ScanType:             "ENHANCED",
+		ScanFrequency:        "CONTINUOUS_SCAN",
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+
+	o.DryRun = true
+	err := o.EnsureRegistryScanningConfiguration()
+	require.NoError(t, err, "failed to ensure with dry run")
+	assert.Equal(t, types.ScanTypeBasic, fakeECR.Scanning.ScanType, "should not change the configuration with dry run")
+
+	o.DryRun = false
+	err = o.EnsureRegistryScanningConfiguration()
+	require.NoError(t, err, "failed to ensure scanning configuration")
+
+	config := fakeECR.Scanning
+	assert.Equal(t, types.ScanTypeEnhanced, config.ScanType)
+	require.Len(t, config.Rules, 2, "should have a rule for each frequency")
+	assert.Equal(t, types.ScanFrequencyScanOnPush, config.Rules[0].ScanFrequency)
+	require.Len(t, config.Rules[0].RepositoryFilters, 1, "should have moved the organisation filter")
+	assert.Equal(t, "other/*", aws.ToString(config.Rules[0].RepositoryFilters[0].Filter))
+	assert.Equal(t, types.ScanFrequencyContinuousScan, config.Rules[1].ScanFrequency)
+	require.Len(t, config.Rules[1].RepositoryFilters, 1)
+	assert.Equal(t, "myorg/*", aws.ToString(config.Rules[1].RepositoryFilters[0].Filter))
+	assert.Equal(t, types.ScanningRepositoryFilterTypeWildcard, config.Rules[1].RepositoryFilters[0].FilterType)
+
+	o.ScanFilters = []string{"myorg/*", "myorg/*-cache=scan_on_push"}
+	err = o.EnsureRegistryScanningConfiguration()
+	require.NoError(t, err, "failed to ensure scanning configuration with filters")
+	require.Len(t, fakeECR.Scanning.Rules, 2)
+	assert.Len(t, fakeECR.Scanning.Rules[0].RepositoryFilters, 2, "should have added the cache filter to the scan on push rule")
+	assert.Len(t, fakeECR.Scanning.Rules[1].RepositoryFilters, 1)
+}
+
+func TestEnsureRegistryScanningConfigurationInvalid(t *testing.T) {
+	testCases := []struct {
+		name          string
+		organisation  string
+		scanType      string
+		scanFrequency string
+		filters       []string
+		expected      string
+	}{
+		{
+			name:          "unknown scan type",
+			scanType:      "CHEESE",
+			scanFrequency: "SCAN_ON_PUSH",
+			expected:      "invalid option: --scan-type CHEESE",
+		},
+		{
+			name:          "basic continuous",
+			scanType:      "BASIC",
+			scanFrequency: "CONTINUOUS_SCAN",
+			expected:      "invalid scan frequency 'CONTINUOUS_SCAN' of scan filter 'myorg/*' for BASIC scanning",
+		},
+		{
+			name:          "empty filter",
+			scanType:      "ENHANCED",
+			scanFrequency: "SCAN_ON_PUSH",
+			filters:       []string{"=SCAN_ON_PUSH"},
+			expected:      "invalid scan filter",
+		},
+		{
+			name:          "no organisation",
+			organisation:  "-",
+			scanType:      "ENHANCED",
+			scanFrequency: "CONTINUOUS_SCAN",
+			expected:      "use --scan-filter '*' to scan every repository",
+		},
+	}
+	for _, tc := range testCases {
+		organisation := "myorg"
+		if tc.organisation == "-" {
+			organisation = ""
+		}
+		fakeECR := fakeecr.NewFakeECR()
+		o := &ecrs.Options{
+			ECRClient:            fakeECR,
+			RegistryOrganisation: organisation,
+			ScanType:             tc.scanType,
+			ScanFrequency:        tc.scanFrequency,
+			ScanFilters:          tc.filters,
+		}
+		o.AWSRegion = "us-east-1"
+		o.Config = &aws.Config{}
+
+		err := o.EnsureRegistryScanningConfiguration()
+		require.Error(t, err, "for %s", tc.name)
+		assert.Contains(t, err.Error(), tc.expected, "for %s", tc.name)
+		assert.Nil(t, fakeECR.Scanning, "should not change the configuration for %s", tc.name)
+	}
+}
@@ -0,0 +1,235 @@
+package ecrs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// ValidateRepositorySettings validates the tag mutability, scanning and repository policy settings
+func (o *Options) ValidateRepositorySettings() error {
+	err := o.RepositoryPolicyBuilder().Validate()
+	if err != nil {
+		return err
+	}
+	if o.LifecyclePolicyMode != "" && stringhelpers.StringArrayIndex(PolicyModes, o.LifecyclePolicyMode) < 0 {
+		return options.InvalidOption("lifecycle-policy-mode", o.LifecyclePolicyMode, PolicyModes)
+	}
+	if o.RepositoryPolicyMode != "" && stringhelpers.StringArrayIndex(PolicyModes, o.RepositoryPolicyMode) < 0 {
+		return options.InvalidOption("repository-policy-mode", o.RepositoryPolicyMode, PolicyModes)
+	}
+	if o.ImageTagMutability != "" {
+		o.ImageTagMutability = strings.ToUpper(o.ImageTagMutability)
+		found := false
+		var values []string
+		for _, v := range types.ImageTagMutability("").Values() {
+			values = append(values, string(v))
+			if string(v) == o.ImageTagMutability {
+				found = true
+			}
+		}
+		if !found {
+			return options.InvalidOption("image-tag-mutability", o.ImageTagMutability, values)
+		}
+	}
+	withExclusion := strings.HasSuffix(o.ImageTagMutability, "_WITH_EXCLUSION")
+	if withExclusion && len(o.ImageTagMutabilityExclusions) == 0 {
+		return fmt.Errorf("image tag mutability %s requires at least one --image-tag-mutability-exclusion", o.ImageTagMutability)
+	}
+	if !withExclusion && len(o.ImageTagMutabilityExclusions) > 0 {
+		return fmt.Errorf("--image-tag-mutability-exclusion can only be used with an image tag mutability ending in _WITH_EXCLUSION")
+	}
+	if o.ScanOnPush != "" {
+		_, err = strconv.ParseBool(o.ScanOnPush)
+		if err != nil {
+			return options.InvalidOption("scan-on-push", o.ScanOnPush, []string{"true", "false"})
+		}
+	}
+	return o.validateEncryption()
+}
+
+func (o *Options) validateEncryption() error {
+	o.EncryptionType = strings.ToUpper(o.EncryptionType)
+	if o.EncryptionType == "" && o.KMSKey != "" {
+		o.EncryptionType = string(types.EncryptionTypeKms)
+	}
+	if o.EncryptionType == "" {
+		return nil
+	}
+	found := false
+	var values []string
+	for _, v := range types.EncryptionType("").Values() {
+		values = append(values, string(v))
+		if string(v) == o.EncryptionType {
+			found = true
+		}
+	}
+	if !found {
+		return options.InvalidOption("encryption-type", o.EncryptionType, values)
+	}
+	if o.KMSKey != "" && o.EncryptionType == string(types.EncryptionTypeAes256) {
+		return fmt.Errorf("--kms-key cannot be used with the encryption type %s", o.EncryptionType)
+	}
+	return nil
+}
+
+// applyRepositorySettings adds the configured repository settings to the create input
+func (o *Options) applyRepositorySettings(input *ecr.CreateRepositoryInput) {
+	if o.ImageTagMutability != "" {
+		input.ImageTagMutability = types.ImageTagMutability(o.ImageTagMutability)
+		input.ImageTagMutabilityExclusionFilters = o.imageTagMutabilityExclusionFilters()
+	}
+	scanOnPush, ok := o.scanOnPush()
+	if ok {
+		input.ImageScanningConfiguration = &types.ImageScanningConfiguration{
+			ScanOnPush: scanOnPush,
+		}
+	}
+	if o.EncryptionType != "" {
+		input.EncryptionConfiguration = &types.EncryptionConfiguration{
+			EncryptionType: types.EncryptionType(o.EncryptionType),
+		}
+		if o.KMSKey != "" {
+			input.EncryptionConfiguration.KmsKey = aws.String(o.KMSKey)
+		}
+	}
+}
+
+// EnsureRepositorySettings reconciles the tag mutability and scanning settings of an existing repository
+func (o *Options) EnsureRepositorySettings(repo *types.Repository) error {
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+	repoName := aws.ToString(repo.RepositoryName)
+
+	drift := o.EncryptionDrift(repo)
+	if drift != "" {
+		log.Logger().Warnf("%s the ECR repository %s has %s. The encryption of a repository cannot be changed after it is created so the repository needs to be recreated",
+			termcolor.ColorWarning("ENCRYPTION DRIFT:"), termcolor.ColorWarning(repoName), drift)
+	}
+
+	if o.ImageTagMutability != "" && !o.imageTagMutabilityMatches(repo) {
+		err = o.putImageTagMutability(client, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	scanOnPush, ok := o.scanOnPush()
+	current := repo.ImageScanningConfiguration != nil && repo.ImageScanningConfiguration.ScanOnPush
+	if ok && scanOnPush != current {
+		if o.DryRun {
+			log.Logger().Infof("would update ECR repository %s scan on push to %s", termcolor.ColorInfo(repoName), termcolor.ColorInfo(strconv.FormatBool(scanOnPush)))
+			return nil
+		}
+		_, err = client.PutImageScanningConfiguration(ctx, &ecr.PutImageScanningConfigurationInput{
+			RepositoryName: repo.RepositoryName,
+			RegistryId:     o.registryIDPointer(),
+			ImageScanningConfiguration: &types.ImageScanningConfiguration{
+				ScanOnPush: scanOnPush,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put image scanning configuration for the ECR repository %s: %w", repoName, err)
+		}
+		log.Logger().Infof("updated ECR repository %s scan on push to %s", termcolor.ColorInfo(repoName), termcolor.ColorInfo(strconv.FormatBool(scanOnPush)))
+	}
+	return nil
+}
+
+func (o *Options) putImageTagMutability(client ECRClient, repo *types.Repository) error {
+	repoName := aws.ToString(repo.RepositoryName)
+	if o.DryRun {
+		log.Logger().Infof("would update ECR repository %s image tag mutability from %s to %s", termcolor.ColorInfo(repoName),
+			string(repo.ImageTagMutability), termcolor.ColorInfo(o.ImageTagMutability))
+		return nil
+	}
+	_, err := client.PutImageTagMutability(o.GetContext(), &ecr.PutImageTagMutabilityInput{
+		RepositoryName:                     repo.RepositoryName,
+		RegistryId:                         o.registryIDPointer(),
+		ImageTagMutability:                 types.ImageTagMutability(o.ImageTagMutability),
+		ImageTagMutabilityExclusionFilters: o.imageTagMutabilityExclusionFilters(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put image tag mutability %s for the ECR repository %s: %w", o.ImageTagMutability, repoName, err)
+	}
+	log.Logger().Infof("updated ECR repository %s image tag mutability from %s to %s", termcolor.ColorInfo(repoName),
+		string(repo.ImageTagMutability), termcolor.ColorInfo(o.ImageTagMutability))
+	return nil
+}
+
+// EncryptionDrift returns a description of how the encryption of the repository differs from the configured
+// encryption or an empty string if there is no drift
+func (o *Options) EncryptionDrift(repo *types.Repository) string {
+	if o.EncryptionType == "" {
+		return ""
+	}
+	actualType := types.EncryptionTypeAes256
+	actualKey := ""
+	if repo.EncryptionConfiguration != nil {
+		if repo.EncryptionConfiguration.EncryptionType != "" {
+			actualType = repo.EncryptionConfiguration.EncryptionType
+		}
+		actualKey = aws.ToString(repo.EncryptionConfiguration.KmsKey)
+	}
+	if string(actualType) != o.EncryptionType {
+		return fmt.Sprintf("encryption type %s but %s was requested", string(actualType), o.EncryptionType)
+	}
+	if o.KMSKey == "" || o.KMSKey == actualKey {
+		return ""
+	}
+	if strings.HasPrefix(o.KMSKey, "alias/") || strings.Contains(o.KMSKey, ":alias/") {
+		// ECR reports the key ARN so we cannot compare an alias without resolving it via KMS
+		log.Logger().Debugf("cannot verify that the KMS key of ECR repository %s matches the alias %s", aws.ToString(repo.RepositoryName), o.KMSKey)
+		return ""
+	}
+	if !strings.HasPrefix(o.KMSKey, "arn:") && strings.HasSuffix(actualKey, ":key/"+o.KMSKey) {
+		return ""
+	}
+	return fmt.Sprintf("KMS key %s but %s was requested", actualKey, o.KMSKey)
+}
+
+func (o *Options) imageTagMutabilityMatches(repo *types.Repository) bool {
+	if string(repo.ImageTagMutability) != o.ImageTagMutability {
+		return false
+	}
+	var actual []string
+	for _, f := range repo.ImageTagMutabilityExclusionFilters {
+		actual = append(actual, aws.ToString(f.Filter))
+	}
+	expected := append([]string{}, o.ImageTagMutabilityExclusions...)
+	sort.Strings(actual)
+	sort.Strings(expected)
+	return strings.Join(actual, ",") == strings.Join(expected, ",")
+}
+
+func (o *Options) imageTagMutabilityExclusionFilters() []types.ImageTagMutabilityExclusionFilter {
+	var answer []types.ImageTagMutabilityExclusionFilter
+	for _, f := range o.ImageTagMutabilityExclusions {
+		answer = append(answer, types.ImageTagMutabilityExclusionFilter{
+			Filter:     aws.String(f),
+			FilterType: types.ImageTagMutabilityExclusionFilterTypeWildcard,
+		})
+	}
+	return answer
+}
+
+// scanOnPush returns the scan on push setting and whether it has been configured
+func (o *Options) scanOnPush() (value, ok bool) {
+	if o.ScanOnPush == "" {
+		return false, false
+	}
+	value, err := strconv.ParseBool(o.ScanOnPush)
+	return value, err == nil
+}
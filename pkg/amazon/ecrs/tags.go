@@ -0,0 +1,179 @@
+package ecrs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// TagPrefix the prefix of the tags managed by jx-registry
+	TagPrefix = "jenkins-x.io/"
+
+	// TagOwner the tag for the git owner of the repository
+	TagOwner = TagPrefix + "owner"
+
+	// TagRepository the tag for the git repository name
+	TagRepository = TagPrefix + "repository"
+
+	// TagApp the tag for the app name
+	TagApp = TagPrefix + "app"
+
+	// TagCluster the tag for the cluster name
+	TagCluster = TagPrefix + "cluster"
+)
+
+// ResourceTags returns the tags to apply to the repository for the given app name.
+// Explicit tags override the automatic tags. An explicit tag with the TagPrefix and an empty value is not applied
+// as it removes the automatic tag, see RemovedTagKeys
+func (o *Options) ResourceTags(appName string) (map[string]string, error) {
+	answer := map[string]string{}
+	if o.AutoTags {
+		autoTags := map[string]string{
+			TagOwner:      o.Owner,
+			TagRepository: o.Repository,
+			TagApp:        appName,
+			TagCluster:    o.ClusterName,
+		}
+		for k, v := range autoTags {
+			if v != "" {
+				answer[k] = v
+			}
+		}
+	}
+	for _, t := range o.Tags {
+		k, v, ok := strings.Cut(t, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid tag '%s' should be of the form key=value", t)
+		}
+		v = strings.TrimSpace(v)
+		if v == "" && strings.HasPrefix(k, TagPrefix) {
+			delete(answer, k)
+			continue
+		}
+		answer[k] = v
+	}
+	return answer, nil
+}
+
+// RemovedTagKeys returns the sorted keys of the tags with the TagPrefix which should be removed from existing
+// repositories. These are the explicit tags with an empty value as the automatic tags would otherwise have set
+// them. Nothing is removed if automatic tagging is disabled
+func (o *Options) RemovedTagKeys() []string {
+	if !o.AutoTags {
+		return nil
+	}
+	var answer []string
+	for _, t := range o.Tags {
+		k, v, ok := strings.Cut(t, "=")
+		k = strings.TrimSpace(k)
+		if ok && strings.TrimSpace(v) == "" && strings.HasPrefix(k, TagPrefix) {
+			answer = append(answer, k)
+		}
+	}
+	sort.Strings(answer)
+	return answer
+}
+
+// EnsureTags reconciles the tags of the given repository with the given tags. Any other tags are left alone
+// apart from the tags returned by RemovedTagKeys which are removed
+func (o *Options) EnsureTags(repo *types.Repository, tags map[string]string) error {
+	if repo.RepositoryArn == nil {
+		return nil
+	}
+	client, err := o.GetECRClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+	repoName := aws.ToString(repo.RepositoryName)
+
+	output, err := client.ListTagsForResource(ctx, &ecr.ListTagsForResourceInput{
+		ResourceArn: repo.RepositoryArn,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list tags for the ECR repository %s: %w", repoName, err)
+	}
+	current := map[string]string{}
+	for _, t := range output.Tags {
+		current[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+
+	var removeKeys []string
+	for _, k := range o.RemovedTagKeys() {
+		if _, ok := current[k]; ok {
+			removeKeys = append(removeKeys, k)
+		}
+	}
+	changed := map[string]string{}
+	for k, v := range tags {
+		if cv, ok := current[k]; !ok || cv != v {
+			changed[k] = v
+		}
+	}
+
+	if o.DryRun {
+		if len(changed) > 0 {
+			log.Logger().Infof("would tag ECR repository %s with %s", termcolor.ColorInfo(repoName), termcolor.ColorInfo(TagsString(changed)))
+		}
+		if len(removeKeys) > 0 {
+			log.Logger().Infof("would remove tags %s from ECR repository %s", termcolor.ColorInfo(strings.Join(removeKeys, ", ")), termcolor.ColorInfo(repoName))
+		}
+		return nil
+	}
+	if len(changed) > 0 {
+		_, err = client.TagResource(ctx, &ecr.TagResourceInput{
+			ResourceArn: repo.RepositoryArn,
+			Tags:        ToECRTags(changed),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to tag the ECR repository %s: %w", repoName, err)
+		}
+		log.Logger().Infof("tagged ECR repository %s with %s", termcolor.ColorInfo(repoName), termcolor.ColorInfo(TagsString(changed)))
+	}
+	if len(removeKeys) > 0 {
+		_, err = client.UntagResource(ctx, &ecr.UntagResourceInput{
+			ResourceArn: repo.RepositoryArn,
+			TagKeys:     removeKeys,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to untag the ECR repository %s: %w", repoName, err)
+		}
+		log.Logger().Infof("removed tags %s from ECR repository %s", termcolor.ColorInfo(strings.Join(removeKeys, ", ")), termcolor.ColorInfo(repoName))
+	}
+	return nil
+}
+
+// ToECRTags converts the map of tags to ECR tags sorted by key
+func ToECRTags(tags map[string]string) []types.Tag {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var answer []types.Tag
+	for _, k := range keys {
+		answer = append(answer, types.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(tags[k]),
+		})
+	}
+	return answer
+}
+
+// TagsString returns a sorted key=value string of the tags for logging
+func TagsString(tags map[string]string) string {
+	var values []string
+	for _, t := range ToECRTags(tags) {
+		values = append(values, aws.ToString(t.Key)+"="+aws.ToString(t.Value))
+	}
+	return strings.Join(values, ", ")
+}
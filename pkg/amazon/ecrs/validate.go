@@ -0,0 +1,364 @@
+package ecrs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// PolicyError a validation error at a position in a policy document
+type PolicyError struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+// Error returns the error message including the position
+func (e *PolicyError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d column %d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// PolicyErrors the validation errors of a policy document
+type PolicyErrors []*PolicyError
+
+// Error returns the errors one per line
+func (e PolicyErrors) Error() string {
+	var lines []string
+	for _, pe := range e {
+		lines = append(lines, pe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateLifecyclePolicy validates the lifecycle policy text against the ECR lifecycle policy rules without
+// calling ECR. The returned error is a PolicyErrors listing the position of each problem. Anything ECR may still
+// accept such as unknown fields or action types is logged as a warning
+func ValidateLifecyclePolicy(text string) error {
+	warnings, err := CheckLifecyclePolicy(text)
+	for _, w := range warnings {
+		log.Logger().Warnf("lifecycle policy %s", w.Error())
+	}
+	return err
+}
+
+// CheckLifecyclePolicy validates the lifecycle policy text returning the warnings for anything ECR may still accept
+// such as unknown fields or action types along with a PolicyErrors error for the problems ECR would reject
+func CheckLifecyclePolicy(text string) (PolicyErrors, error) {
+	data := []byte(text)
+	var raw interface{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// the offset is after the invalid character
+			return nil, policyErrorAt(data, syntaxErr.Offset-1, "", syntaxErr.Error())
+		}
+		return nil, policyErrorAt(data, int64(len(data)), "", err.Error())
+	}
+	positions := map[string]int64{}
+	err = indexPositions(json.NewDecoder(bytes.NewReader(data)), "", positions)
+	if err != nil {
+		return nil, policyErrorAt(data, int64(len(data)), "", err.Error())
+	}
+
+	policy := &LifecyclePolicy{}
+	err = json.Unmarshal(data, policy)
+	if err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			path := fieldPath(typeErr.Field)
+			offset, ok := positions[path]
+			if !ok {
+				offset = typeErr.Offset
+			}
+			return nil, policyErrorAt(data, offset, path, fmt.Sprintf("should be of type %s but was %s", typeErr.Type.String(), typeErr.Value))
+		}
+		return nil, policyErrorAt(data, int64(len(data)), "", err.Error())
+	}
+
+	v := &lifecycleValidator{
+		data:      data,
+		positions: positions,
+	}
+	v.checkUnknownFields(raw, reflect.TypeOf(policy).Elem(), "")
+	v.validate(policy)
+	sortPolicyErrors(v.warnings)
+	if len(v.errors) == 0 {
+		return v.warnings, nil
+	}
+	sortPolicyErrors(v.errors)
+	return v.warnings, v.errors
+}
+
+func sortPolicyErrors(errs PolicyErrors) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+}
+
+type lifecycleValidator struct {
+	data      []byte
+	positions map[string]int64
+	errors    PolicyErrors
+	warnings  PolicyErrors
+}
+
+func (v *lifecycleValidator) addError(path, message string, args ...interface{}) {
+	v.errors = append(v.errors, v.policyError(path, message, args...))
+}
+
+func (v *lifecycleValidator) addWarning(path, message string, args ...interface{}) {
+	v.warnings = append(v.warnings, v.policyError(path, message, args...))
+}
+
+func (v *lifecycleValidator) policyError(path, message string, args ...interface{}) *PolicyError {
+	// lets use the closest parent path which is in the document
+	offset, ok := v.positions[path]
+	for p := path; !ok && p != ""; {
+		idx := strings.LastIndexAny(p, ".[")
+		if idx < 0 {
+			p = ""
+		} else {
+			p = p[0:idx]
+		}
+		offset, ok = v.positions[p]
+	}
+	line, col := lineAndColumn(v.data, offset)
+	return &PolicyError{
+		Line:    line,
+		Column:  col,
+		Path:    path,
+		Message: fmt.Sprintf(message, args...),
+	}
+}
+
+// checkUnknownFields warns about any keys which are not fields of the lifecycle policy types
+func (v *lifecycleValidator) checkUnknownFields(raw interface{}, t reflect.Type, path string) {
+	switch value := raw.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			fields[name] = f.Type
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			ft, ok := fields[k]
+			if !ok {
+				v.addWarning(childPath, "unknown field which may not be supported by ECR")
+				continue
+			}
+			v.checkUnknownFields(value[k], ft, childPath)
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for i, item := range value {
+			v.checkUnknownFields(item, t.Elem(), path+"["+strconv.Itoa(i)+"]")
+		}
+	}
+}
+
+func (v *lifecycleValidator) validate(policy *LifecyclePolicy) {
+	if len(policy.Rules) == 0 {
+		v.addError("rules", "at least one rule is required")
+		return
+	}
+	priorities := map[int]string{}
+	anyRule := ""
+	maxPriority := 0
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		path := "rules[" + strconv.Itoa(i) + "]"
+		if rule.RulePriority <= 0 {
+			v.addError(path+".rulePriority", "must be a positive number")
+		} else if other, ok := priorities[rule.RulePriority]; ok {
+			v.addError(path+".rulePriority", "duplicate rule priority %d also used by %s", rule.RulePriority, other)
+		} else {
+			priorities[rule.RulePriority] = path
+		}
+		if rule.RulePriority > maxPriority {
+			maxPriority = rule.RulePriority
+		}
+		if rule.Action.Type != "" && rule.Action.Type != ActionExpire {
+			v.addWarning(path+".action.type", "unknown action type '%s' which may not be supported by ECR", rule.Action.Type)
+		}
+		if rule.Selection.TagStatus == TagStatusAny {
+			if anyRule != "" {
+				v.addError(path+".selection.tagStatus", "only one rule can use tagStatus %s but it is also used by %s", TagStatusAny, anyRule)
+			} else {
+				anyRule = path
+			}
+		}
+		v.validateSelection(path+".selection", &rule.Selection)
+	}
+	if anyRule != "" {
+		for i := range policy.Rules {
+			rule := &policy.Rules[i]
+			if rule.Selection.TagStatus == TagStatusAny && rule.RulePriority != maxPriority {
+				v.addError(anyRule+".rulePriority", "the rule with tagStatus %s must have the highest rule priority", TagStatusAny)
+				break
+			}
+		}
+	}
+}
+
+func (v *lifecycleValidator) validateSelection(path string, s *LifecycleSelection) {
+	switch s.TagStatus {
+	case TagStatusTagged:
+		if len(s.TagPrefixList) == 0 && len(s.TagPatternList) == 0 {
+			v.addError(path+".tagStatus", "tagPrefixList or tagPatternList is required when tagStatus is %s", TagStatusTagged)
+		}
+		if len(s.TagPrefixList) > 0 && len(s.TagPatternList) > 0 {
+			v.addError(path+".tagPatternList", "cannot be used with tagPrefixList")
+		}
+		for i, prefix := range s.TagPrefixList {
+			if prefix == "" {
+				v.addError(path+".tagPrefixList["+strconv.Itoa(i)+"]", "must not be empty")
+			}
+		}
+		for i, pattern := range s.TagPatternList {
+			if pattern == "" {
+				v.addError(path+".tagPatternList["+strconv.Itoa(i)+"]", "must not be empty")
+			}
+		}
+	case TagStatusUntagged, TagStatusAny:
+		if len(s.TagPrefixList) > 0 {
+			v.addError(path+".tagPrefixList", "can only be used when tagStatus is %s", TagStatusTagged)
+		}
+		if len(s.TagPatternList) > 0 {
+			v.addError(path+".tagPatternList", "can only be used when tagStatus is %s", TagStatusTagged)
+		}
+	default:
+		v.addError(path+".tagStatus", "must be one of %s, %s or %s but was '%s'", TagStatusTagged, TagStatusUntagged, TagStatusAny, s.TagStatus)
+	}
+
+	switch s.CountType {
+	case CountTypeSinceImagePushed:
+		if s.CountUnit != CountUnitDays {
+			v.addError(path+".countUnit", "must be %s when countType is %s", CountUnitDays, CountTypeSinceImagePushed)
+		}
+	case CountTypeImageCountMoreThan:
+		if s.CountUnit != "" {
+			v.addError(path+".countUnit", "cannot be used when countType is %s", CountTypeImageCountMoreThan)
+		}
+	default:
+		v.addError(path+".countType", "must be one of %s or %s but was '%s'", CountTypeSinceImagePushed, CountTypeImageCountMoreThan, s.CountType)
+	}
+	if s.CountNumber <= 0 {
+		v.addError(path+".countNumber", "must be a positive number")
+	}
+}
+
+// indexPositions walks the JSON tokens recording the offset of each value by its path
+func indexPositions(dec *json.Decoder, path string, positions map[string]int64) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if _, ok := positions[path]; !ok {
+		positions[path] = dec.InputOffset()
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+	switch delim {
+	case '{':
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			positions[childPath] = dec.InputOffset()
+			err = indexPositions(dec, childPath, positions)
+			if err != nil {
+				return err
+			}
+		}
+	case '[':
+		for i := 0; dec.More(); i++ {
+			err = indexPositions(dec, path+"["+strconv.Itoa(i)+"]", positions)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// lets consume the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// fieldPath converts the dotted field of an unmarshal error such as rules.0.rulePriority into rules[0].rulePriority
+func fieldPath(field string) string {
+	var buf strings.Builder
+	for i, name := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(name); err == nil {
+			buf.WriteString("[" + name + "]")
+			continue
+		}
+		if i > 0 {
+			buf.WriteString(".")
+		}
+		buf.WriteString(name)
+	}
+	return buf.String()
+}
+
+func policyErrorAt(data []byte, offset int64, path, message string) error {
+	line, col := lineAndColumn(data, offset)
+	return PolicyErrors{
+		{
+			Line:    line,
+			Column:  col,
+			Path:    path,
+			Message: message,
+		},
+	}
+}
+
+// lineAndColumn converts the byte offset into a 1 based line and column
+func lineAndColumn(data []byte, offset int64) (line, column int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	before := data[0:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
@@ -0,0 +1,158 @@
+package ecrs_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLifecyclePolicy(t *testing.T) {
+	defaultPolicy, err := ecrs.DefaultLifecyclePolicy().ToJSON()
+	require.NoError(t, err, "failed to marshal default policy")
+
+	testCases := []struct {
+		name     string
+		policy   string
+		expected []string
+		warnings []string
+	}{
+		{
+			name:   "default",
+			policy: defaultPolicy,
+		},
+		{
+			name: "syntax",
+			policy: `{
+  "rules": [
+    {
+      "rulePriority": 1,
+    }
+  ]
+}`,
+			expected: []string{"line 5 column 5: invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			name: "type",
+			policy: `{
+  "rules": [
+    {
+      "rulePriority": "1"
+    }
+  ]
+}`,
+			expected: []string{`line 4 column 21: rules[0].rulePriority: should be of type int but was string`},
+		},
+		{
+			name: "unknown field",
+			policy: `{
+  "rules": [
+    {
+      "rulePriority": 1,
+      "selection": {
+        "tagStatus": "untagged",
+        "countType": "sinceImagePushed",
+        "countUnit": "days",
+        "countNumber": 14,
+        "storageClass": "standard"
+      },
+      "action": {"type": "transition", "targetStorageClass": "archive"}
+    }
+  ]
+}`,
+			warnings: []string{
+				"line 10 column 23: rules[0].selection.storageClass: unknown field which may not be supported by ECR",
+				"line 12 column 24: rules[0].action.type: unknown action type 'transition' which may not be supported by ECR",
+				"line 12 column 60: rules[0].action.targetStorageClass: unknown field which may not be supported by ECR",
+			},
+		},
+		{
+			name:     "no rules",
+			policy:   `{"rules": []}`,
+			expected: []string{"line 1 column 9: rules: at least one rule is required"},
+		},
+		{
+			name:   "missing action",
+			policy: `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}}]}`,
+		},
+		{
+			name: "invalid rules",
+			policy: `{
+  "rules": [
+    {
+      "rulePriority": 1,
+      "selection": {
+        "tagStatus": "tagged",
+        "countType": "sinceImagePushed",
+        "countNumber": 14
+      },
+      "action": {"type": "expire"}
+    },
+    {
+      "rulePriority": 1,
+      "selection": {
+        "tagStatus": "untagged",
+        "tagPrefixList": ["0.0.0-"],
+        "countType": "imageCountMoreThan",
+        "countUnit": "days",
+        "countNumber": 5
+      },
+      "action": {"type": "expire"}
+    },
+    {
+      "rulePriority": 2,
+      "selection": {
+        "tagStatus": "any",
+        "countType": "imageCountMoreThan",
+        "countNumber": 100
+      },
+      "action": {"type": "expire"}
+    },
+    {
+      "rulePriority": 3,
+      "selection": {
+        "tagStatus": "any",
+        "countType": "imageCountMoreThan",
+        "countNumber": 100
+      },
+      "action": {"type": "expire"}
+    }
+  ]
+}`,
+			expected: []string{
+				"line 5 column 18: rules[0].selection.countUnit: must be days when countType is sinceImagePushed",
+				"line 6 column 20: rules[0].selection.tagStatus: tagPrefixList or tagPatternList is required when tagStatus is tagged",
+				"line 13 column 21: rules[1].rulePriority: duplicate rule priority 1 also used by rules[0]",
+				"line 16 column 24: rules[1].selection.tagPrefixList: can only be used when tagStatus is tagged",
+				"line 18 column 20: rules[1].selection.countUnit: cannot be used when countType is imageCountMoreThan",
+				"line 24 column 21: rules[2].rulePriority: the rule with tagStatus any must have the highest rule priority",
+				"line 35 column 20: rules[3].selection.tagStatus: only one rule can use tagStatus any but it is also used by rules[2]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		warnings, err := ecrs.CheckLifecyclePolicy(tc.policy)
+		var warningMessages []string
+		for _, w := range warnings {
+			warningMessages = append(warningMessages, w.Error())
+		}
+		assert.Equal(t, tc.warnings, warningMessages, "warnings for %s", tc.name)
+		if len(tc.expected) == 0 {
+			assert.NoError(t, err, "for %s", tc.name)
+			continue
+		}
+		require.Error(t, err, "for %s", tc.name)
+
+		var policyErrors ecrs.PolicyErrors
+		require.True(t, errors.As(err, &policyErrors), "should return PolicyErrors for %s", tc.name)
+
+		var messages []string
+		for _, pe := range policyErrors {
+			messages = append(messages, pe.Error())
+		}
+		assert.Equal(t, tc.expected, messages, "for %s", tc.name)
+	}
+}
@@ -0,0 +1,31 @@
+package fakests
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// FakeSTS a fake STS implementation for testing
+type FakeSTS struct {
+	Account string
+	Calls   int
+}
+
+func (f *FakeSTS) GetCallerIdentity(_ context.Context, _ *sts.GetCallerIdentityInput, _ ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	f.Calls++
+	arn := "arn:aws:iam::" + f.Account + ":role/jx-registry"
+	return &sts.GetCallerIdentityOutput{
+		Account:        &f.Account,
+		Arn:            &arn,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// NewFakeSTS creates a new fake STS for the given caller account
+func NewFakeSTS(account string) *FakeSTS {
+	return &FakeSTS{
+		Account: account,
+	}
+}
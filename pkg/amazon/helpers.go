@@ -6,18 +6,35 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
 	"github.com/sethvargo/go-envconfig"
 	"github.com/spf13/cobra"
 )
 
+const (
+	// DefaultSessionName the default session name used when assuming roles
+	DefaultSessionName = "jx-registry"
+)
+
+// STSClient the subset of the STS API we use
+type STSClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
 // Options helper options for creating an AWS config
 type Options struct {
-	AWSProfile string `env:"AWS_PROFILE"`
-	AWSRegion  string `env:"AWS_REGION"`
-	Context    context.Context
-	Config     *aws.Config
+	AWSProfile     string   `env:"AWS_PROFILE"`
+	AWSRegion      string   `env:"AWS_REGION"`
+	AWSRoleARNs    []string `env:"AWS_ASSUME_ROLE_ARN"`
+	AWSExternalID  string   `env:"AWS_ASSUME_ROLE_EXTERNAL_ID"`
+	AWSSessionName string   `env:"AWS_ASSUME_ROLE_SESSION_NAME"`
+	Context        context.Context
+	Config         *aws.Config
+	STSClient      STSClient
 }
 
 func (o *Options) GetConfig() (*aws.Config, error) {
@@ -32,15 +49,64 @@ func (o *Options) GetConfig() (*aws.Config, error) {
 	if o.AWSRegion != "" {
 		ops = append(ops, config.WithRegion(o.AWSRegion))
 	}
-	log.Logger().Infof("loading config with AWS region: '%s'", o.AWSRegion)
+	if o.AWSProfile != "" {
+		ops = append(ops, config.WithSharedConfigProfile(o.AWSProfile))
+	}
+	log.Logger().Infof("loading config with AWS region: '%s' and profile: '%s'", o.AWSRegion, o.AWSProfile)
 	cfg, err := config.LoadDefaultConfig(o.Context, ops...)
-	o.Config = &cfg
 	if err != nil {
 		return nil, fmt.Errorf("failed to create AWS config: %w", err)
 	}
+
+	// lets assume each role in turn using the credentials of the previous one
+	for i, roleARN := range o.AWSRoleARNs {
+		externalID := ""
+		if i == len(o.AWSRoleARNs)-1 {
+			externalID = o.AWSExternalID
+		}
+		log.Logger().Infof("assuming AWS role %s", termcolor.ColorInfo(roleARN))
+		cfg = AssumeRole(cfg, roleARN, externalID, o.AWSSessionName)
+	}
+	o.Config = &cfg
 	return o.Config, nil
 }
 
+// GetCallerAccountID returns the AWS account ID of the current caller
+func (o *Options) GetCallerAccountID() (string, error) {
+	if o.STSClient == nil {
+		cfg, err := o.GetConfig()
+		if err != nil {
+			return "", err
+		}
+		o.STSClient = sts.NewFromConfig(*cfg)
+	}
+	output, err := o.STSClient.GetCallerIdentity(o.GetContext(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the caller identity: %w", err)
+	}
+	if output == nil || output.Account == nil {
+		return "", fmt.Errorf("no account returned for the caller identity")
+	}
+	return *output.Account, nil
+}
+
+// AssumeRole returns a copy of the given config whose credentials are provided by assuming the given role
+// via STS using the credentials of the given config
+func AssumeRole(cfg aws.Config, roleARN, externalID, sessionName string) aws.Config {
+	if sessionName == "" {
+		sessionName = DefaultSessionName
+	}
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(ao *stscreds.AssumeRoleOptions) {
+		ao.RoleSessionName = sessionName
+		if externalID != "" {
+			ao.ExternalID = aws.String(externalID)
+		}
+	})
+	answer := cfg.Copy()
+	answer.Credentials = aws.NewCredentialsCache(provider)
+	return answer
+}
+
 // AddFlags adds the flags
 func (o *Options) AddFlags(cmd *cobra.Command) {
 	if o.Context == nil {
@@ -49,6 +115,9 @@ func (o *Options) AddFlags(cmd *cobra.Command) {
 	o.GetContext()
 	cmd.Flags().StringVarP(&o.AWSProfile, "aws-profile", "", o.AWSProfile, "The AWS profile to use. Defaults to $AWS_PROFILE")
 	cmd.Flags().StringVarP(&o.AWSRegion, "aws-region", "", o.AWSRegion, "The AWS region. Defaults to $AWS_REGION or its read from the 'jx-requirements.yml' for the development environment")
+	cmd.Flags().StringArrayVarP(&o.AWSRoleARNs, "aws-role-arn", "", o.AWSRoleARNs, "The AWS role(s) to assume. If specified multiple times the roles are assumed in order as a chain. Defaults to the comma separated $AWS_ASSUME_ROLE_ARN")
+	cmd.Flags().StringVarP(&o.AWSExternalID, "aws-external-id", "", o.AWSExternalID, "The external ID used when assuming the last role in the chain. Defaults to $AWS_ASSUME_ROLE_EXTERNAL_ID")
+	cmd.Flags().StringVarP(&o.AWSSessionName, "aws-session-name", "", o.AWSSessionName, "The session name used when assuming roles. Defaults to $AWS_ASSUME_ROLE_SESSION_NAME or "+DefaultSessionName)
 }
 
 // EnvProcess processes the environment variable defaults
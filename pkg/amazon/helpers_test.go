@@ -0,0 +1,48 @@
+package amazon_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConfigUsesProfile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(configFile, []byte("[profile hub]\nregion = eu-west-1\n"), 0o600)
+	require.NoError(t, err, "failed to write AWS config file")
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	o := &amazon.Options{
+		AWSProfile: "hub",
+	}
+	cfg, err := o.GetConfig()
+	require.NoError(t, err, "failed to get config")
+	assert.Equal(t, "eu-west-1", cfg.Region, "region should be read from the profile")
+}
+
+func TestGetConfigAssumesRoleChain(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	o := &amazon.Options{
+		AWSRegion: "us-east-1",
+		AWSRoleARNs: []string{
+			"arn:aws:iam::111111111111:role/hub",
+			"arn:aws:iam::222222222222:role/spoke",
+		},
+		AWSExternalID: "my-external-id",
+	}
+	cfg, err := o.GetConfig()
+	require.NoError(t, err, "failed to get config")
+	require.IsType(t, &aws.CredentialsCache{}, cfg.Credentials, "should use assume role credentials")
+	assert.Equal(t, "us-east-1", cfg.Region)
+}
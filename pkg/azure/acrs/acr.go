@@ -0,0 +1,277 @@
+package acrs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/containers/azcontainerregistry"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// HostSuffix the suffix of ACR registry hosts
+	HostSuffix = ".azurecr.io"
+
+	// DefaultUntaggedRetentionDays the default number of days untagged manifests are retained
+	DefaultUntaggedRetentionDays = 7
+
+	// DefaultPurgeSchedule the default cron schedule of the purge tasks
+	DefaultPurgeSchedule = "0 1 * * *"
+)
+
+// Options the options for ensuring the settings, retention policy and purge tasks of ACR repositories
+type Options struct {
+	Context               context.Context
+	Registry              string
+	RegistryOrganisation  string
+	SubscriptionID        string `env:"AZURE_SUBSCRIPTION_ID"`
+	ResourceGroup         string `env:"ACR_RESOURCE_GROUP"`
+	WriteEnabled          bool   `env:"ACR_WRITE_ENABLED,default=true"`
+	DeleteEnabled         bool   `env:"ACR_DELETE_ENABLED,default=true"`
+	UntaggedRetentionDays int    `env:"ACR_UNTAGGED_RETENTION_DAYS,default=7"`
+	PurgeSchedule         string `env:"ACR_PURGE_SCHEDULE,default=0 1 * * *"`
+	DryRun                bool
+	Client                Client
+
+	retentionEnsured bool
+}
+
+// AddFlags adds the ACR flags to the command
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.SubscriptionID, "azure-subscription", "", o.SubscriptionID, "The Azure subscription of the ACR registry used to configure its retention policy and purge tasks. Defaults to $AZURE_SUBSCRIPTION_ID or the registrySubscription in the requirements")
+	cmd.Flags().StringVarP(&o.ResourceGroup, "acr-resource-group", "", o.ResourceGroup, "The resource group of the ACR registry. Looked up from the subscription if not specified")
+	cmd.Flags().BoolVarP(&o.WriteEnabled, "acr-write-enabled", "", o.WriteEnabled, "Whether images can be pushed to the ACR repositories")
+	cmd.Flags().BoolVarP(&o.DeleteEnabled, "acr-delete-enabled", "", o.DeleteEnabled, "Whether images can be deleted from the ACR repositories")
+	cmd.Flags().IntVarP(&o.UntaggedRetentionDays, "acr-untagged-retention-days", "", o.UntaggedRetentionDays, "The number of days the ACR registry retains untagged manifests. Use 0 to disable the retention policy")
+	cmd.Flags().StringVarP(&o.PurgeSchedule, "acr-purge-schedule", "", o.PurgeSchedule, "The cron schedule of the ACR tasks purging pull request and cache images")
+}
+
+// EnvProcess processes the environment variable defaults
+func (o *Options) EnvProcess() {
+	err := envconfig.Process(o.GetContext(), o)
+	if err != nil {
+		log.Logger().Warnf("failed to default env vars: %s", err.Error())
+	}
+}
+
+// RegistryName returns the name of the ACR registry from its host such as myregistry.azurecr.io
+func RegistryName(registry string) string {
+	host := registryHost(registry)
+	return strings.ToLower(strings.TrimSuffix(host, HostSuffix))
+}
+
+// RepositoryName returns the repository name for the app name and registry organisation
+func (o *Options) RepositoryName(appName string) string {
+	appName, _, _ = strings.Cut(appName, ":")
+	repoName := appName
+	if o.RegistryOrganisation != "" {
+		repoName = o.RegistryOrganisation + "/" + appName
+	}
+	return strings.ToLower(repoName)
+}
+
+// EnsureRepository ensures the settings of the repository of the image along with the retention policy of the
+// registry and the purge task of the repository. ACR creates repositories on their first push so the settings of a
+// repository which does not exist yet are applied on a later run
+func (o *Options) EnsureRepository(image string, cache bool) error {
+	if len(image) <= 2 {
+		return fmt.Errorf("invalid image name '%s' should be longer than 2 characters", image)
+	}
+	name := o.RepositoryName(image)
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+	repo, err := client.GetRepositoryProperties(ctx, name)
+	if err != nil {
+		if !IsNotFound(err) {
+			return fmt.Errorf("failed to get the properties of ACR repository %s: %w", name, err)
+		}
+		log.Logger().Infof("ACR repository %s will be created by its first push", termcolor.ColorInfo(o.URI(name)))
+	} else {
+		err = o.ensureRepositorySettings(name, repo)
+		if err != nil {
+			return err
+		}
+	}
+	return o.EnsureRetentionPolicy(image, cache)
+}
+
+// EnsureRetentionPolicy ensures the untagged manifest retention policy of the registry and the purge task of the
+// repository of the image which mirrors the ECR lifecycle policies
+func (o *Options) EnsureRetentionPolicy(image string, cache bool) error {
+	if o.SubscriptionID == "" {
+		log.Logger().Warnf("not configuring the retention policy and purge tasks of ACR registry %s as no Azure subscription is specified via --azure-subscription", o.Registry)
+		return nil
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	registry, err := client.GetRegistry(o.GetContext())
+	if err != nil {
+		return fmt.Errorf("failed to get ACR registry %s: %w", RegistryName(o.Registry), err)
+	}
+	if !o.retentionEnsured {
+		err = o.ensureRegistryRetentionPolicy(registry)
+		if err != nil {
+			return err
+		}
+		o.retentionEnsured = true
+	}
+	task := o.DefaultPurgeTask()
+	if cache {
+		task = o.CachePurgeTask(o.RepositoryName(image))
+	}
+	return o.ensurePurgeTask(registry, task)
+}
+
+// ListRepositories lists the repositories in the registry filtered by the registry organisation if specified
+func (o *Options) ListRepositories() ([]string, error) {
+	client, err := o.GetClient()
+	if err != nil {
+		return nil, err
+	}
+	names, err := client.ListRepositories(o.GetContext())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the repositories of ACR registry %s: %w", RegistryName(o.Registry), err)
+	}
+	prefix := ""
+	if o.RegistryOrganisation != "" {
+		prefix = strings.ToLower(o.RegistryOrganisation) + "/"
+	}
+	var answer []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			answer = append(answer, name)
+		}
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
+
+// DeleteRepository deletes the repository of the image along with all of its images
+func (o *Options) DeleteRepository(image string) error {
+	name := o.RepositoryName(image)
+	if o.DryRun {
+		log.Logger().Infof("would delete ACR repository %s", termcolor.ColorInfo(o.URI(name)))
+		return nil
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	err = client.DeleteRepository(o.GetContext(), name)
+	if err != nil {
+		return fmt.Errorf("failed to delete ACR repository %s: %w", name, err)
+	}
+	log.Logger().Infof("Deleted ACR repository %s", termcolor.ColorInfo(o.URI(name)))
+	return nil
+}
+
+// URI returns the URI of the repository
+func (o *Options) URI(name string) string {
+	return registryHost(o.Registry) + "/" + name
+}
+
+// GetClient lazily creates the client using the default Azure credentials
+func (o *Options) GetClient() (Client, error) {
+	if o.Client == nil {
+		var err error
+		o.Client, err = NewClient(registryHost(o.Registry), o.SubscriptionID, o.ResourceGroup)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return o.Client, nil
+}
+
+// GetContext returns the context defaulting to a new context
+func (o *Options) GetContext() context.Context {
+	if o.Context == nil {
+		o.Context = context.TODO()
+	}
+	return o.Context
+}
+
+// ensureRepositorySettings updates the write and delete settings of the repository if they differ
+func (o *Options) ensureRepositorySettings(name string, repo *azcontainerregistry.ContainerRepositoryProperties) error {
+	current := repo.ChangeableAttributes
+	if current == nil {
+		current = &azcontainerregistry.RepositoryWriteableProperties{}
+	}
+	if valueOrDefault(current.CanWrite, true) == o.WriteEnabled && valueOrDefault(current.CanDelete, true) == o.DeleteEnabled {
+		log.Logger().Debugf("settings of ACR repository %s are up to date", o.URI(name))
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would set write enabled to %t and delete enabled to %t on ACR repository %s", o.WriteEnabled, o.DeleteEnabled, termcolor.ColorInfo(o.URI(name)))
+		return nil
+	}
+	err := o.Client.UpdateRepositoryProperties(o.GetContext(), name, &azcontainerregistry.RepositoryWriteableProperties{
+		CanWrite:  to.Ptr(o.WriteEnabled),
+		CanDelete: to.Ptr(o.DeleteEnabled),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the properties of ACR repository %s: %w", name, err)
+	}
+	log.Logger().Infof("Set write enabled to %t and delete enabled to %t on ACR repository %s", o.WriteEnabled, o.DeleteEnabled, termcolor.ColorInfo(o.URI(name)))
+	return nil
+}
+
+// ensureRegistryRetentionPolicy ensures the retention policy of untagged manifests which is only supported by
+// Premium registries
+func (o *Options) ensureRegistryRetentionPolicy(registry *armcontainerregistry.Registry) error {
+	name := RegistryName(o.Registry)
+	if registry.SKU == nil || valueOrDefault(registry.SKU.Tier, "") != armcontainerregistry.SKUTierPremium {
+		log.Logger().Warnf("not configuring the retention policy of ACR registry %s as it requires the Premium SKU", name)
+		return nil
+	}
+	desired := &armcontainerregistry.RetentionPolicy{
+		Status: to.Ptr(armcontainerregistry.PolicyStatusDisabled),
+	}
+	if o.UntaggedRetentionDays > 0 {
+		desired.Status = to.Ptr(armcontainerregistry.PolicyStatusEnabled)
+		desired.Days = to.Ptr(int32(o.UntaggedRetentionDays))
+	}
+
+	var current *armcontainerregistry.RetentionPolicy
+	if registry.Properties != nil && registry.Properties.Policies != nil {
+		current = registry.Properties.Policies.RetentionPolicy
+	}
+	if current != nil && valueOrDefault(current.Status, "") == *desired.Status &&
+		(o.UntaggedRetentionDays <= 0 || valueOrDefault(current.Days, 0) == *desired.Days) {
+		log.Logger().Debugf("retention policy of ACR registry %s is up to date", name)
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would set the retention of untagged manifests in ACR registry %s to %d days", termcolor.ColorInfo(name), o.UntaggedRetentionDays)
+		return nil
+	}
+	err := o.Client.UpdateRegistry(o.GetContext(), &armcontainerregistry.RegistryUpdateParameters{
+		Properties: &armcontainerregistry.RegistryPropertiesUpdateParameters{
+			Policies: &armcontainerregistry.Policies{
+				RetentionPolicy: desired,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the retention policy of ACR registry %s: %w", name, err)
+	}
+	log.Logger().Infof("Set the retention of untagged manifests in ACR registry %s to %d days", termcolor.ColorInfo(name), o.UntaggedRetentionDays)
+	return nil
+}
+
+// registryHost returns the host of the registry removing any path
+func registryHost(registry string) string {
+	host, _, _ := strings.Cut(strings.TrimSpace(registry), "/")
+	return host
+}
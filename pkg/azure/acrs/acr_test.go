@@ -0,0 +1,121 @@
+package acrs_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs/fakeacr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOptions(fakeACR *fakeacr.FakeACR) *acrs.Options {
+	return &acrs.Options{
+		Registry:              "myregistry.azurecr.io",
+		RegistryOrganisation:  "myorg",
+		SubscriptionID:        "00000000-0000-0000-0000-000000000000",
+		WriteEnabled:          true,
+		DeleteEnabled:         false,
+		UntaggedRetentionDays: acrs.DefaultUntaggedRetentionDays,
+		PurgeSchedule:         acrs.DefaultPurgeSchedule,
+		Client:                fakeACR,
+	}
+}
+
+func TestEnsureRepository(t *testing.T) {
+	fakeACR := fakeacr.NewFakeACR()
+	fakeACR.AddRepository("myorg/myapp")
+	o := newOptions(fakeACR)
+
+	err := o.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository")
+
+	props := fakeACR.Repositories["myorg/myapp"]
+	assert.True(t, *props.CanWrite, "should be write enabled")
+	assert.False(t, *props.CanDelete, "should be delete disabled")
+
+	retention := fakeACR.Registry.Properties.Policies.RetentionPolicy
+	require.NotNil(t, retention, "should have a retention policy")
+	assert.Equal(t, armcontainerregistry.PolicyStatusEnabled, *retention.Status)
+	assert.Equal(t, int32(7), *retention.Days)
+
+	task := fakeACR.Tasks["jx-registry-purge-myorg"]
+	require.NotNil(t, task, "should have created the purge task")
+	assert.Equal(t, "westeurope", *task.Location)
+	assert.Equal(t, acrs.DefaultPurgeSchedule, *task.Properties.Trigger.TimerTriggers[0].Schedule)
+	step := task.Properties.Step.(*armcontainerregistry.EncodedTaskStep)
+	content, err := base64.StdEncoding.DecodeString(*step.EncodedTaskContent)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `acr purge --filter 'myorg/.*:^0\.0\.0-.*' --ago 14d`)
+
+	// a second run should not replace the task
+	fakeACR.Tasks["jx-registry-purge-myorg"].Name = nil
+	err = o.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository again")
+	assert.Nil(t, fakeACR.Tasks["jx-registry-purge-myorg"].Name, "should not have replaced the purge task")
+}
+
+func TestEnsureRepositoryForCache(t *testing.T) {
+	fakeACR := fakeacr.NewFakeACR()
+	o := newOptions(fakeACR)
+
+	err := o.EnsureRepository("myapp/cache", true)
+	require.NoError(t, err, "failed to ensure cache repository")
+
+	assert.Empty(t, fakeACR.Repositories, "should not create repositories as ACR creates them on push")
+	task := fakeACR.Tasks["jx-registry-purge-cache-myorg-myapp-cache"]
+	require.NotNil(t, task, "should have created the cache purge task")
+	step := task.Properties.Step.(*armcontainerregistry.EncodedTaskStep)
+	content, err := base64.StdEncoding.DecodeString(*step.EncodedTaskContent)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `acr purge --filter 'myorg/myapp/cache:^$' --untagged --ago 1d`)
+}
+
+func TestEnsureRepositorySkipsRetentionForStandardSKU(t *testing.T) {
+	fakeACR := fakeacr.NewFakeACR()
+	tier := armcontainerregistry.SKUTierStandard
+	fakeACR.Registry.SKU.Tier = &tier
+	o := newOptions(fakeACR)
+
+	err := o.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository")
+	assert.Nil(t, fakeACR.Registry.Properties.Policies.RetentionPolicy, "should not set a retention policy")
+	assert.Len(t, fakeACR.Tasks, 1, "should still create the purge task")
+}
+
+func TestEnsureRepositoryWithoutSubscription(t *testing.T) {
+	fakeACR := fakeacr.NewFakeACR()
+	fakeACR.AddRepository("myorg/myapp")
+	o := newOptions(fakeACR)
+	o.SubscriptionID = ""
+
+	err := o.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository")
+	assert.False(t, *fakeACR.Repositories["myorg/myapp"].CanDelete, "should still update the repository settings")
+	assert.Empty(t, fakeACR.Tasks, "should not create purge tasks without a subscription")
+}
+
+func TestEnsureRepositoryDryRun(t *testing.T) {
+	fakeACR := fakeacr.NewFakeACR()
+	fakeACR.AddRepository("myorg/myapp")
+	o := newOptions(fakeACR)
+	o.DryRun = true
+
+	err := o.EnsureRepository("myapp", true)
+	require.NoError(t, err, "failed to run dry run")
+	assert.True(t, *fakeACR.Repositories["myorg/myapp"].CanDelete, "should not update the repository in dry run mode")
+	assert.Nil(t, fakeACR.Registry.Properties.Policies.RetentionPolicy, "should not set a retention policy in dry run mode")
+	assert.Empty(t, fakeACR.Tasks, "should not create purge tasks in dry run mode")
+}
+
+func TestCachePurgeTaskName(t *testing.T) {
+	o := &acrs.Options{RegistryOrganisation: "my-very-long-organisation-name"}
+	task := o.CachePurgeTask(o.RepositoryName("my-very-long-application-name/cache"))
+	assert.LessOrEqual(t, len(task.Name), 50, "task name %s should be at most 50 characters", task.Name)
+	assert.Regexp(t, `^jx-registry-purge-cache-[a-z0-9-]+-[0-9a-f]{8}$`, task.Name)
+
+	other := o.CachePurgeTask(o.RepositoryName("my-very-long-application-name/cache2"))
+	assert.NotEqual(t, task.Name, other.Name, "long names should remain unique")
+}
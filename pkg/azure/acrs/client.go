@@ -0,0 +1,192 @@
+package acrs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/containers/azcontainerregistry"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+)
+
+// Client the subset of the ACR data plane and Azure Resource Manager APIs we use for a single registry.
+// Pollers are waited on and pagers are read fully so that the client can be faked
+type Client interface {
+	GetRepositoryProperties(ctx context.Context, name string) (*azcontainerregistry.ContainerRepositoryProperties, error)
+	UpdateRepositoryProperties(ctx context.Context, name string, properties *azcontainerregistry.RepositoryWriteableProperties) error
+	ListRepositories(ctx context.Context) ([]string, error)
+	DeleteRepository(ctx context.Context, name string) error
+	GetRegistry(ctx context.Context) (*armcontainerregistry.Registry, error)
+	UpdateRegistry(ctx context.Context, parameters *armcontainerregistry.RegistryUpdateParameters) error
+	GetTask(ctx context.Context, name string) (*armcontainerregistry.Task, error)
+	CreateTask(ctx context.Context, name string, task *armcontainerregistry.Task) error
+}
+
+// IsNotFound returns true if the error is a not found response from Azure
+func IsNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+// NewClient creates a client for the registry host using the default Azure credentials. If the resource group is
+// not specified it is looked up from the registries of the subscription
+func NewClient(host, subscriptionID, resourceGroup string) (Client, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the default Azure credential: %w", err)
+	}
+	data, err := azcontainerregistry.NewClient("https://"+host, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the ACR client for %s: %w", host, err)
+	}
+	c := &client{
+		registryName:  RegistryName(host),
+		resourceGroup: resourceGroup,
+		data:          data,
+	}
+	if subscriptionID != "" {
+		c.registries, err = armcontainerregistry.NewRegistriesClient(subscriptionID, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the ACR registries client: %w", err)
+		}
+		c.tasks, err = armcontainerregistry.NewTasksClient(subscriptionID, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the ACR tasks client: %w", err)
+		}
+	}
+	return c, nil
+}
+
+type client struct {
+	registryName  string
+	resourceGroup string
+	data          *azcontainerregistry.Client
+	registries    *armcontainerregistry.RegistriesClient
+	tasks         *armcontainerregistry.TasksClient
+}
+
+func (c *client) GetRepositoryProperties(ctx context.Context, name string) (*azcontainerregistry.ContainerRepositoryProperties, error) {
+	resp, err := c.data.GetRepositoryProperties(ctx, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.ContainerRepositoryProperties, nil
+}
+
+func (c *client) UpdateRepositoryProperties(ctx context.Context, name string, properties *azcontainerregistry.RepositoryWriteableProperties) error {
+	_, err := c.data.UpdateRepositoryProperties(ctx, name, &azcontainerregistry.ClientUpdateRepositoryPropertiesOptions{Value: properties})
+	return err
+}
+
+func (c *client) ListRepositories(ctx context.Context) ([]string, error) {
+	var answer []string
+	pager := c.data.NewListRepositoriesPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range page.Names {
+			if name != nil {
+				answer = append(answer, *name)
+			}
+		}
+	}
+	return answer, nil
+}
+
+func (c *client) DeleteRepository(ctx context.Context, name string) error {
+	_, err := c.data.DeleteRepository(ctx, name, nil)
+	return err
+}
+
+func (c *client) GetRegistry(ctx context.Context) (*armcontainerregistry.Registry, error) {
+	resourceGroup, err := c.getResourceGroup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.registries.Get(ctx, resourceGroup, c.registryName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Registry, nil
+}
+
+func (c *client) UpdateRegistry(ctx context.Context, parameters *armcontainerregistry.RegistryUpdateParameters) error {
+	resourceGroup, err := c.getResourceGroup(ctx)
+	if err != nil {
+		return err
+	}
+	poller, err := c.registries.BeginUpdate(ctx, resourceGroup, c.registryName, *parameters, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+func (c *client) GetTask(ctx context.Context, name string) (*armcontainerregistry.Task, error) {
+	resourceGroup, err := c.getResourceGroup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.tasks.Get(ctx, resourceGroup, c.registryName, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Task, nil
+}
+
+func (c *client) CreateTask(ctx context.Context, name string, task *armcontainerregistry.Task) error {
+	resourceGroup, err := c.getResourceGroup(ctx)
+	if err != nil {
+		return err
+	}
+	poller, err := c.tasks.BeginCreate(ctx, resourceGroup, c.registryName, name, *task, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+// getResourceGroup lazily finds the resource group of the registry from the registries in the subscription
+func (c *client) getResourceGroup(ctx context.Context) (string, error) {
+	if c.registries == nil {
+		return "", fmt.Errorf("no Azure subscription specified for ACR registry %s", c.registryName)
+	}
+	if c.resourceGroup != "" {
+		return c.resourceGroup, nil
+	}
+	pager := c.registries.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list ACR registries: %w", err)
+		}
+		for _, r := range page.Value {
+			if r == nil || r.ID == nil || !strings.EqualFold(valueOrDefault(r.Name, ""), c.registryName) {
+				continue
+			}
+			id, err := arm.ParseResourceID(*r.ID)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse the resource ID %s of ACR registry %s: %w", *r.ID, c.registryName, err)
+			}
+			c.resourceGroup = id.ResourceGroupName
+			return c.resourceGroup, nil
+		}
+	}
+	return "", fmt.Errorf("could not find ACR registry %s in the subscription", c.registryName)
+}
+
+func valueOrDefault[T any](p *T, defaultValue T) T {
+	if p == nil {
+		return defaultValue
+	}
+	return *p
+}
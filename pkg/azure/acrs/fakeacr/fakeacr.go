@@ -0,0 +1,141 @@
+package fakeacr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/containers/azcontainerregistry"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+)
+
+// FakeACR a fake ACR client storing a single registry along with its repositories and tasks in memory
+type FakeACR struct {
+	Registry     *armcontainerregistry.Registry
+	Repositories map[string]*azcontainerregistry.RepositoryWriteableProperties
+	Tasks        map[string]*armcontainerregistry.Task
+}
+
+// NewFakeACR creates a new fake client for a Premium registry
+func NewFakeACR() *FakeACR {
+	return &FakeACR{
+		Registry: &armcontainerregistry.Registry{
+			Name:     to.Ptr("myregistry"),
+			Location: to.Ptr("westeurope"),
+			SKU: &armcontainerregistry.SKU{
+				Name: to.Ptr(armcontainerregistry.SKUNamePremium),
+				Tier: to.Ptr(armcontainerregistry.SKUTierPremium),
+			},
+			Properties: &armcontainerregistry.RegistryProperties{
+				Policies: &armcontainerregistry.Policies{},
+			},
+		},
+		Repositories: map[string]*azcontainerregistry.RepositoryWriteableProperties{},
+		Tasks:        map[string]*armcontainerregistry.Task{},
+	}
+}
+
+func (f *FakeACR) GetRepositoryProperties(_ context.Context, name string) (*azcontainerregistry.ContainerRepositoryProperties, error) {
+	props := f.Repositories[name]
+	if props == nil {
+		return nil, notFound("repository %s", name)
+	}
+	copied := *props
+	return &azcontainerregistry.ContainerRepositoryProperties{
+		Name:                 to.Ptr(name),
+		ChangeableAttributes: &copied,
+	}, nil
+}
+
+func (f *FakeACR) UpdateRepositoryProperties(_ context.Context, name string, properties *azcontainerregistry.RepositoryWriteableProperties) error {
+	props := f.Repositories[name]
+	if props == nil {
+		return notFound("repository %s", name)
+	}
+	if properties.CanDelete != nil {
+		props.CanDelete = properties.CanDelete
+	}
+	if properties.CanList != nil {
+		props.CanList = properties.CanList
+	}
+	if properties.CanRead != nil {
+		props.CanRead = properties.CanRead
+	}
+	if properties.CanWrite != nil {
+		props.CanWrite = properties.CanWrite
+	}
+	return nil
+}
+
+func (f *FakeACR) ListRepositories(_ context.Context) ([]string, error) {
+	var answer []string
+	for name := range f.Repositories {
+		answer = append(answer, name)
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
+
+func (f *FakeACR) DeleteRepository(_ context.Context, name string) error {
+	if f.Repositories[name] == nil {
+		return notFound("repository %s", name)
+	}
+	delete(f.Repositories, name)
+	return nil
+}
+
+func (f *FakeACR) GetRegistry(_ context.Context) (*armcontainerregistry.Registry, error) {
+	copied := *f.Registry
+	return &copied, nil
+}
+
+func (f *FakeACR) UpdateRegistry(_ context.Context, parameters *armcontainerregistry.RegistryUpdateParameters) error {
+	if parameters.Properties != nil && parameters.Properties.Policies != nil && parameters.Properties.Policies.RetentionPolicy != nil {
+		if f.Registry.Properties == nil {
+			f.Registry.Properties = &armcontainerregistry.RegistryProperties{}
+		}
+		if f.Registry.Properties.Policies == nil {
+			f.Registry.Properties.Policies = &armcontainerregistry.Policies{}
+		}
+		f.Registry.Properties.Policies.RetentionPolicy = parameters.Properties.Policies.RetentionPolicy
+	}
+	return nil
+}
+
+func (f *FakeACR) GetTask(_ context.Context, name string) (*armcontainerregistry.Task, error) {
+	task := f.Tasks[name]
+	if task == nil {
+		return nil, notFound("task %s", name)
+	}
+	return task, nil
+}
+
+func (f *FakeACR) CreateTask(_ context.Context, name string, task *armcontainerregistry.Task) error {
+	task.Name = to.Ptr(name)
+	f.Tasks[name] = task
+	return nil
+}
+
+// AddRepository adds a repository with the default settings such as when an image is first pushed
+func (f *FakeACR) AddRepository(name string) {
+	f.Repositories[name] = &azcontainerregistry.RepositoryWriteableProperties{
+		CanDelete: to.Ptr(true),
+		CanList:   to.Ptr(true),
+		CanRead:   to.Ptr(true),
+		CanWrite:  to.Ptr(true),
+	}
+}
+
+func notFound(format string, args ...any) error {
+	return &azcore.ResponseError{
+		ErrorCode:  "NotFound",
+		StatusCode: http.StatusNotFound,
+		RawResponse: &http.Response{
+			StatusCode: http.StatusNotFound,
+			Status:     fmt.Sprintf(format+" not found", args...),
+		},
+	}
+}
@@ -0,0 +1,146 @@
+package acrs
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// PurgeTaskPrefix the prefix of the names of the ACR tasks purging pull request images
+	PurgeTaskPrefix = "jx-registry-purge"
+
+	// cachePurgeTaskPrefix the prefix of the names of the ACR tasks purging untagged cache images
+	cachePurgeTaskPrefix = "jx-registry-purge-cache-"
+
+	// maxTaskNameLength the maximum length of an ACR task name
+	maxTaskNameLength = 50
+
+	purgeTaskTimeout = 3600
+)
+
+var (
+	invalidTaskNameChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// PurgeTask a scheduled ACR task running acr purge
+type PurgeTask struct {
+	Name    string
+	Command string
+}
+
+// DefaultPurgeTask returns the purge task equivalent to the default ECR lifecycle policy which deletes pull request
+// images of the registry organisation after 14 days
+func (o *Options) DefaultPurgeTask() *PurgeTask {
+	name := PurgeTaskPrefix
+	repoFilter := ".*"
+	if o.RegistryOrganisation != "" {
+		org := strings.ToLower(o.RegistryOrganisation)
+		name += "-" + org
+		repoFilter = regexp.QuoteMeta(org) + "/.*"
+	}
+	return &PurgeTask{
+		Name:    taskName(name),
+		Command: fmt.Sprintf("acr purge --filter '%s:^%s.*' --ago %dd", repoFilter, regexp.QuoteMeta(ecrs.DefaultExpireTagPrefix), ecrs.DefaultExpireTagPrefixDays),
+	}
+}
+
+// CachePurgeTask returns the purge task equivalent to the default ECR cache lifecycle policy which deletes the
+// untagged images of the cache repository after 1 day
+func (o *Options) CachePurgeTask(repoName string) *PurgeTask {
+	return &PurgeTask{
+		Name:    taskName(cachePurgeTaskPrefix + repoName),
+		Command: fmt.Sprintf("acr purge --filter '%s:^$' --untagged --ago %dd", regexp.QuoteMeta(repoName), ecrs.DefaultCacheExpireUntaggedAfterDays),
+	}
+}
+
+// EncodedTaskContent returns the base64 encoded multi-step task YAML running the purge command
+func (t *PurgeTask) EncodedTaskContent() string {
+	content := fmt.Sprintf("version: v1.1.0\nsteps:\n  - cmd: %s\n    disableWorkingDirectoryOverride: true\n    timeout: %d\n", t.Command, purgeTaskTimeout)
+	return base64.StdEncoding.EncodeToString([]byte(content))
+}
+
+// ensurePurgeTask creates or replaces the purge task if its command or schedule differ
+func (o *Options) ensurePurgeTask(registry *armcontainerregistry.Registry, purgeTask *PurgeTask) error {
+	client := o.Client
+	ctx := o.GetContext()
+	content := purgeTask.EncodedTaskContent()
+
+	current, err := client.GetTask(ctx, purgeTask.Name)
+	if err != nil {
+		if !IsNotFound(err) {
+			return fmt.Errorf("failed to get ACR task %s: %w", purgeTask.Name, err)
+		}
+		current = nil
+	}
+	if current != nil && purgeTaskUpToDate(current, content, o.PurgeSchedule) {
+		log.Logger().Debugf("ACR task %s is up to date", purgeTask.Name)
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would put ACR task %s running %s on schedule %s", termcolor.ColorInfo(purgeTask.Name), termcolor.ColorInfo(purgeTask.Command), o.PurgeSchedule)
+		return nil
+	}
+	err = client.CreateTask(ctx, purgeTask.Name, &armcontainerregistry.Task{
+		Location: registry.Location,
+		Properties: &armcontainerregistry.TaskProperties{
+			Status:   to.Ptr(armcontainerregistry.TaskStatusEnabled),
+			Platform: &armcontainerregistry.PlatformProperties{OS: to.Ptr(armcontainerregistry.OSLinux)},
+			Timeout:  to.Ptr(int32(purgeTaskTimeout)),
+			Step: &armcontainerregistry.EncodedTaskStep{
+				Type:               to.Ptr(armcontainerregistry.StepTypeEncodedTask),
+				EncodedTaskContent: to.Ptr(content),
+			},
+			Trigger: &armcontainerregistry.TriggerProperties{
+				TimerTriggers: []*armcontainerregistry.TimerTrigger{
+					{
+						Name:     to.Ptr("schedule"),
+						Schedule: to.Ptr(o.PurgeSchedule),
+						Status:   to.Ptr(armcontainerregistry.TriggerStatusEnabled),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put ACR task %s: %w", purgeTask.Name, err)
+	}
+	log.Logger().Infof("Put ACR task %s running %s on schedule %s", termcolor.ColorInfo(purgeTask.Name), termcolor.ColorInfo(purgeTask.Command), o.PurgeSchedule)
+	return nil
+}
+
+func purgeTaskUpToDate(task *armcontainerregistry.Task, content, schedule string) bool {
+	props := task.Properties
+	if props == nil || valueOrDefault(props.Status, "") != armcontainerregistry.TaskStatusEnabled {
+		return false
+	}
+	step, ok := props.Step.(*armcontainerregistry.EncodedTaskStep)
+	if !ok || valueOrDefault(step.EncodedTaskContent, "") != content {
+		return false
+	}
+	if props.Trigger == nil || len(props.Trigger.TimerTriggers) != 1 {
+		return false
+	}
+	trigger := props.Trigger.TimerTriggers[0]
+	return valueOrDefault(trigger.Schedule, "") == schedule && valueOrDefault(trigger.Status, "") == armcontainerregistry.TriggerStatusEnabled
+}
+
+// taskName sanitises the task name shortening long names with a hash suffix to keep them unique
+func taskName(name string) string {
+	name = strings.Trim(invalidTaskNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) <= maxTaskNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	return strings.TrimRight(name[:maxTaskNameLength-len(hash)-1], "-") + "-" + hash
+}
@@ -4,18 +4,23 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/jenkins-x-plugins/jx-gitops/pkg/variablefinders"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/oci"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/acrprovider"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/ecrprovider"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/garprovider"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/harborprovider"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/ociprovider"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
-	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
-	"github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
@@ -25,10 +30,48 @@ import (
 var (
 	info = termcolor.ColorInfo
 
+	backends = []string{ecrprovider.Name, garprovider.Name, acrprovider.Name, harborprovider.Name}
+
 	cmdLong = templates.LongDesc(`
-		Lazy create a container registry for ECR as well as putting a lifecycle policy in place. The default policy
+		Lazy create a container registry repository. The registry backend is chosen from the cluster provider and registry host
+		which for an eks cluster using an ECR registry is ECR, for a gke cluster using a LOCATION-docker.pkg.dev registry
+		is Google Artifact Registry and for an aks cluster using a *.azurecr.io registry is ACR. Each backend claims the
+		registry hosts ending with its default suffix or with the suffixes specified via --registry-suffix backend=suffix.
+
+		For ACR the write and delete settings of the repository are ensured along with the retention of untagged manifests
+		in the registry and scheduled acr purge tasks which expire the 0.0.0- images after 14 days and untagged cache
+		images after 1 day. The retention policy and purge tasks require --azure-subscription.
+
+		Harbor registries on any cluster are claimed via --registry-suffix harbor=HOST or --harbor-url. The project named
+		by the registry organisation is lazily created with the --harbor-public, --harbor-auto-scan and
+		--harbor-storage-limit settings along with a tag retention policy which expires the 0.0.0- images after 14 days.
+		The credentials are read from the username and password keys of the --harbor-secret Secret.
+
+		Any other registry is checked via the OCI distribution API before the build starts. The check fails if the
+		registry is not reachable or if the credentials in the docker config JSON of the --oci-secret Secret do not allow
+		pushing to ORG/APP, which is checked by starting a blob upload and then cancelling it. Use --oci-preflight=false
+		to disable the check.
+
+		For ECR a lifecycle policy is also put in place. The default policy
 	    will make images with a tag prefix of 0.0.0- expire after 14 days. This prefix is the default for pull request builds.
         If a policy exist and the default policy isn't overridden (see --ecr-lifecycle-policy) no policy will be put.
+		A policy can also be generated via --expire-untagged-after-days, --expire-tag-prefix and --keep-last-n-tagged.
+
+		If no policy is specified via a flag or file then a repository without a policy uses the lifecycle-policy.json and
+		repository-policy.json keys of the jx-registry-policies ConfigMap in the jx namespace, falling back to the files
+		in the .jx/registry directory of the dev environment git repository.
+
+		The policies and settings can be overridden for repositories matching a glob or regex via --repository-rules-file
+		such as to expire cache images more aggressively than release images.
+
+		When --cache-suffix is specified the cache repository uses --ecr-cache-lifecycle-policy. If not specified a cache
+		repository without a lifecycle policy expires untagged images after 1 day and keeps the last 10 tagged images.
+
+		Cross account access can be granted via --allow-pull-account, --allow-push-role-arn and --allow-pull-org-id which
+		generate repository policy statements merged with the existing statements of the repository by Sid.
+
+		Repositories can be created in several regions via --regions. The registry replication configuration can
+		replicate the repositories of the organisation to other regions or accounts via --replication-destination.
 `)
 
 	cmdExample = templates.Examples(`
@@ -41,15 +84,19 @@ var (
 type Options struct {
 	options.BaseOptions
 	ecrs.Options
-
-	ECRSuffix     string
-	Namespace     string
-	Owner         string
-	Repository    string
-	JXClient      versioned.Interface
-	GitClient     gitclient.Interface
-	CommandRunner cmdrunner.CommandRunner
-	Requirements  *jxcore.RequirementsConfig
+	requirements.FinderOptions
+
+	ACROptions             acrs.Options
+	HarborOptions          harbor.Options
+	OCIOptions             oci.Options
+	ECRSuffix              string
+	Owner                  string
+	Repository             string
+	RegistrySuffixes       []string
+	Providers              []providers.RegistryProvider
+	ArtifactRegistryClient artifactregistry.Client
+
+	hostSuffixes map[string]providers.HostSuffixes
 }
 
 // NewCmdCreate creates a command object for the command
@@ -71,34 +118,33 @@ func NewCmdCreate() (*cobra.Command, *Options) {
 		o.Context = cmd.Context()
 	}
 	o.Options.EnvProcess()
+	o.ACROptions.Context = o.Context
+	o.ACROptions.EnvProcess()
+	o.HarborOptions.Context = o.Context
+	o.HarborOptions.EnvProcess()
+	o.OCIOptions.Context = o.Context
+	o.OCIOptions.EnvProcess()
 
 	o.Options.AddFlags(cmd)
-
-	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "The namespace. Defaults to the current namespace")
-	cmd.Flags().StringVarP(&o.ECRSuffix, "ecr-registry-suffix", "", ".amazonaws.com", "The registry suffix to check if we are using ECR")
+	o.FinderOptions.AddFlags(cmd)
+	o.ACROptions.AddFlags(cmd)
+	o.HarborOptions.AddFlags(cmd)
+	o.OCIOptions.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.ECRSuffix, "ecr-registry-suffix", "", "", "The registry suffix to check if we are using ECR")
+	_ = cmd.Flags().MarkDeprecated("ecr-registry-suffix", "use --registry-suffix ecr=SUFFIX instead")
+	cmd.Flags().StringArrayVarP(&o.RegistrySuffixes, "registry-suffix", "", nil, fmt.Sprintf("The registry host suffix claimed by a backend of the form backend=suffix for one of the backends %s. Backends claim their default suffixes if none are specified", strings.Join(backends, ", ")))
 	cmd.Flags().StringVarP(&o.CacheSuffix, "cache-suffix", "", o.CacheSuffix, "If specified (or enabled via $CACHE_SUFFIX) we will make sure an ECR is created for the cache image too")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "Only reports which repositories would be created and the changes to their settings and policies without changing anything")
 
 	o.BaseOptions.AddBaseFlags(cmd)
 	return cmd, o
 }
 
 func (o *Options) Validate() error {
-	if o.GitClient == nil {
-		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
-	}
-	if o.Requirements == nil {
-		var err error
-		o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
-		if err != nil {
-			return fmt.Errorf("failed to create jxClient: %w", err)
-		}
-		o.Requirements, err = variablefinders.FindRequirements(o.GitClient, o.JXClient, o.Namespace, "", o.Owner, o.Repository)
-		if err != nil {
-			return fmt.Errorf("failed to load requirements from dev environment: %w", err)
-		}
-	}
-	if o.Requirements == nil {
-		return fmt.Errorf("no requirements found for dev environment")
+	_, err := o.FindRequirements(o.Owner, o.Repository)
+	if err != nil {
+		return err
 	}
 
 	if o.AWSRegion == "" {
@@ -107,6 +153,34 @@ func (o *Options) Validate() error {
 	if o.Registry == "" {
 		o.Registry = o.Requirements.Cluster.Registry
 	}
+	if o.ClusterName == "" {
+		o.ClusterName = o.Requirements.Cluster.ClusterName
+	}
+	if o.ACROptions.SubscriptionID == "" && o.Requirements.Cluster.AzureConfig != nil {
+		o.ACROptions.SubscriptionID = o.Requirements.Cluster.AzureConfig.RegistrySubscription
+	}
+
+	err = o.ValidateReplication()
+	if err != nil {
+		return err
+	}
+	err = o.HarborOptions.Validate()
+	if err != nil {
+		return err
+	}
+
+	o.hostSuffixes, err = providers.ParseHostSuffixes(o.RegistrySuffixes)
+	if err != nil {
+		return err
+	}
+	for backend := range o.hostSuffixes {
+		if stringhelpers.StringArrayIndex(backends, backend) < 0 {
+			return options.InvalidOption("registry-suffix", backend, backends)
+		}
+	}
+	if o.ECRSuffix != "" {
+		o.hostSuffixes[ecrprovider.Name] = append(o.hostSuffixes[ecrprovider.Name], o.ECRSuffix)
+	}
 	return nil
 
 }
@@ -115,27 +189,59 @@ func (o *Options) Run() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate options: %w", err)
 	}
-	if o.Requirements.Cluster.Provider != "eks" {
-		log.Logger().Infof("no ECR code necessary as using provider %s", o.Requirements.Cluster.Provider)
-		return nil
-	}
+	clusterProvider := o.Requirements.Cluster.Provider
 	registry := o.Requirements.Cluster.Registry
-	if registry != "" && registry != "ecr.io" && !strings.HasSuffix(registry, o.ECRSuffix) {
-		log.Logger().Infof("ignoring registry %s ", registry)
+	registryProviders := o.Providers
+	if registryProviders == nil {
+		registryProviders = o.defaultProviders()
+	}
+	provider := providers.Find(registryProviders, clusterProvider, registry)
+	if provider == nil {
+		log.Logger().Infof("no registry code necessary as using provider %s and registry %s", info(clusterProvider), info(registry))
 		return nil
 	}
 
-	log.Logger().Infof("verifying that container registry %s with organisation %s and app name %s has an ECR associated with it", info(registry), info(o.RegistryOrganisation), info(o.AppName))
+	log.Logger().Infof("verifying that container registry %s with organisation %s and app name %s has a %s repository associated with it", info(registry), info(o.RegistryOrganisation), info(o.AppName), info(provider.Name()))
 
-	images := []string{o.AppName}
-	if o.CacheSuffix != "" {
-		images = append(images, o.AppName+o.CacheSuffix)
+	err = provider.EnsureRepository(o.AppName, false)
+	if err != nil {
+		return err
 	}
-	for _, image := range images {
-		err = o.Options.LazyCreateRegistry(image)
-		if err != nil {
-			return fmt.Errorf("failed to lazy create the ECR registry for %s: %w", image, err)
-		}
+	if o.CacheSuffix == "" {
+		return nil
+	}
+
+	// lets use the cache retention policy for the cache image
+	return provider.EnsureRepository(o.AppName+o.CacheSuffix, true)
+}
+
+// defaultProviders returns the registry providers supported by the command
+func (o *Options) defaultProviders() []providers.RegistryProvider {
+	o.ACROptions.Registry = o.Registry
+	o.ACROptions.RegistryOrganisation = o.RegistryOrganisation
+	o.ACROptions.DryRun = o.DryRun
+	o.HarborOptions.Registry = o.Registry
+	o.HarborOptions.RegistryOrganisation = o.RegistryOrganisation
+	o.HarborOptions.DryRun = o.DryRun
+	o.HarborOptions.KubeClient = o.KubeClient
+	o.HarborOptions.Namespace = o.Namespace
+	o.OCIOptions.Registry = o.Registry
+	o.OCIOptions.RegistryOrganisation = o.RegistryOrganisation
+	o.OCIOptions.DryRun = o.DryRun
+	o.OCIOptions.KubeClient = o.KubeClient
+	o.OCIOptions.Namespace = o.Namespace
+	return []providers.RegistryProvider{
+		ecrprovider.NewProvider(&o.Options, &o.FinderOptions, o.hostSuffixes[ecrprovider.Name]),
+		garprovider.NewProvider(&artifactregistry.Options{
+			Context:              o.Context,
+			Registry:             o.Registry,
+			RegistryOrganisation: o.RegistryOrganisation,
+			DryRun:               o.DryRun,
+			Client:               o.ArtifactRegistryClient,
+		}, o.hostSuffixes[garprovider.Name]),
+		acrprovider.NewProvider(&o.ACROptions, o.hostSuffixes[acrprovider.Name]),
+		harborprovider.NewProvider(&o.HarborOptions, o.hostSuffixes[harborprovider.Name]),
+		// the OCI distribution preflight is last as it matches any registry
+		ociprovider.NewProvider(&o.OCIOptions),
 	}
-	return nil
 }
@@ -1,13 +1,31 @@
 package create_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs/fakeacr"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/create"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry/fakegar"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor/fakeharbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/oci/fakeoci"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestCreateForNonEKS(t *testing.T) {
@@ -24,37 +42,582 @@ func TestCreateForNonEKS(t *testing.T) {
 }
 
 func TestCreateForEKS(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.CacheSuffix = "/cache"
+	o.RegistryID = "123456789012"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	// lets check we have a repository
+	require.Len(t, fakeECR.Repositories, 2, "should have created 2 repositories")
+
+	for _, v := range fakeECR.Repositories {
+		require.NotNil(t, v.RepositoryUri, "should have a repository URI")
+		t.Logf("found ECR registry ID %s, Repository Name: %s ARN: %s URI: %s\n", ToString(v.RegistryId), ToString(v.RepositoryName), ToString(v.RepositoryArn), ToString(v.RepositoryUri))
+	}
+}
+
+func TestCreateForGKEArtifactRegistry(t *testing.T) {
 	_, o := create.NewCmdCreate()
 
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.Provider = "gke"
+	o.Requirements.Cluster.Registry = "us-docker.pkg.dev/myproject/myrepo"
+
+	o.AppName = "myapp"
+	o.CacheSuffix = "/cache"
+	fakeGAR := fakegar.NewFakeArtifactRegistry()
+	o.ArtifactRegistryClient = fakeGAR
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	repo := fakeGAR.Repositories["projects/myproject/locations/us/repositories/myrepo"]
+	require.NotNil(t, repo, "should have created the Artifact Registry repository")
+	assert.Contains(t, repo.CleanupPolicies, artifactregistry.CleanupPolicyExpirePullRequests)
+	assert.Contains(t, repo.CleanupPolicies, artifactregistry.CleanupPolicyExpireCache)
+}
+
+func TestCreateForAKS(t *testing.T) {
+	_, o := create.NewCmdCreate()
+
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.Provider = "aks"
+	o.Requirements.Cluster.Registry = "myregistry.azurecr.io"
+	o.Requirements.Cluster.AzureConfig = &jxcore.AzureConfig{RegistrySubscription: "00000000-0000-0000-0000-000000000000"}
+
+	o.AppName = "myapp"
+	fakeACR := fakeacr.NewFakeACR()
+	fakeACR.AddRepository("myapp")
+	o.ACROptions.Client = fakeACR
+	o.ACROptions.DeleteEnabled = false
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	assert.False(t, *fakeACR.Repositories["myapp"].CanDelete, "should have disabled delete")
+	assert.Contains(t, fakeACR.Tasks, "jx-registry-purge", "should have created the purge task")
+	assert.Equal(t, int32(7), *fakeACR.Registry.Properties.Policies.RetentionPolicy.Days)
+}
+
+func TestCreateWithRegistrySuffix(t *testing.T) {
+	_, o := create.NewCmdCreate()
+
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.Provider = "aks"
+	o.Requirements.Cluster.Registry = "myregistry.example.com"
+
+	o.AppName = "myapp"
+	fakeACR := fakeacr.NewFakeACR()
+	fakeACR.AddRepository("myapp")
+	o.ACROptions.Client = fakeACR
+	o.ACROptions.DeleteEnabled = false
+	o.OCIOptions.Enabled = false
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.True(t, *fakeACR.Repositories["myapp"].CanDelete, "should not claim the registry without a suffix")
+
+	o.RegistrySuffixes = []string{"acr=.example.com"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.False(t, *fakeACR.Repositories["myapp"].CanDelete, "should claim the registry with the suffix")
+
+	o.RegistrySuffixes = []string{"quay=.example.com"}
+	err = o.Run()
+	require.Error(t, err, "should fail for an unknown backend")
+}
+
+func TestCreateForHarbor(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+
+	_, o := create.NewCmdCreate()
+
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.Provider = "kubernetes"
+	o.Requirements.Cluster.Registry = "harbor.example.com"
+	o.RegistryOrganisation = "myorg"
+	o.RegistrySuffixes = []string{"harbor=harbor.example.com"}
+	o.HarborOptions.URL = fakeHarbor.URL()
+	o.AppName = "myapp"
+	fakeClusterClients(o, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: harbor.DefaultSecretName, Namespace: "jx"},
+		Data: map[string][]byte{
+			harbor.SecretUsernameKey: []byte(fakeharbor.Username),
+			harbor.SecretPasswordKey: []byte(fakeharbor.Password),
+		},
+	})
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	project := fakeHarbor.Projects["myorg"]
+	require.NotNil(t, project, "should have created the Harbor project")
+	assert.Equal(t, "true", project.Metadata["auto_scan"])
+	assert.NotEmpty(t, project.Metadata["retention_id"], "should have a retention policy")
+}
+
+func TestCreateWithOCIPreflight(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("Basic")
+	defer registry.Close()
+	registry.PushRepositories = []string{"myorg/myapp"}
+
+	_, o := create.NewCmdCreate()
+
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.Provider = "kind"
+	o.Requirements.Cluster.Registry = registry.Host()
+	o.RegistryOrganisation = "myorg"
+	o.AppName = "myapp"
+	o.OCIOptions.Insecure = true
+	o.OCIOptions.Username = fakeoci.Username
+	o.OCIOptions.Password = fakeoci.Password
+	fakeClusterClients(o)
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Contains(t, registry.Requests, "POST /v2/myorg/myapp/blobs/uploads/")
+
+	o.CacheSuffix = "/cache"
+	err = o.Run()
+	require.Error(t, err, "should fail as pushes to the cache repository are denied")
+	assert.Contains(t, err.Error(), "do not allow pushing to myorg/myapp/cache")
+}
+
+// newEKSOptions returns the create options for an EKS cluster using a fake ECR client and fake cluster clients
+func newEKSOptions(t *testing.T) (*create.Options, *fakeecr.FakeECR) {
+	t.Helper()
+	_, o := create.NewCmdCreate()
 	o.Requirements = &jxcore.RequirementsConfig{
 		Cluster: jxcore.ClusterConfig{
 			Provider: "eks",
 		},
 	}
-
 	o.AWSRegion = "dummy"
 	o.Config = &aws.Config{}
 	o.AppName = "myapp"
 	fakeECR := fakeecr.NewFakeECR()
 	o.ECRClient = fakeECR
+	fakeClusterClients(o)
+	return o, fakeECR
+}
+
+// fakeClusterClients lets use fake kubernetes clients so we don't look for policies in a real cluster
+func fakeClusterClients(o *create.Options, objects ...runtime.Object) {
+	o.Namespace = "jx"
+	o.KubeClient = fake.NewSimpleClientset(objects...)
+	o.JXClient = jxfake.NewSimpleClientset()
+}
+
+func ToString(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+func TestCreateDryRun(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.CreateECRRepositoryPolicy = true
+	o.ScanOnPush = "true"
+	o.DryRun = true
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	require.Empty(t, fakeECR.Repositories, "should not have created any repositories")
+
+	// lets create the repository then check we don't modify it
+	o.DryRun = false
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	require.Len(t, fakeECR.Repositories, 1, "should have created the repository")
+	lifecyclePolicy := fakeECR.LifecyclePolicies["myapp"]
+	require.NotEmpty(t, lifecyclePolicy, "should have put a lifecycle policy")
+
+	o.DryRun = true
+	o.ScanOnPush = "false"
+	o.ECRLifecyclePolicy = `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 30}, "action": {"type": "expire"}}]}`
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	require.Equal(t, lifecyclePolicy, fakeECR.LifecyclePolicies["myapp"], "should not have changed the lifecycle policy")
+	require.True(t, fakeECR.Repositories["myapp"].ImageScanningConfiguration.ScanOnPush, "should not have changed scan on push")
+}
+
+func TestCreateDoesNotReputEquivalentPolicy(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.ECRLifecyclePolicy = `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}}]}`
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	// lets simulate ECR normalising the policy text
+	normalised := `{"rules":[{"action":{"type":"expire"},"rulePriority":1,"selection":{"countNumber":1,"countType":"sinceImagePushed","countUnit":"days","tagStatus":"untagged"}}]}`
+	fakeECR.LifecyclePolicies["myapp"] = normalised
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	require.Equal(t, normalised, fakeECR.LifecyclePolicies["myapp"], "should not have put an equivalent lifecycle policy")
+}
+
+func TestCreateRejectsInvalidLifecyclePolicy(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.ECRLifecyclePolicy = `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "tagged", "countType": "imageCountMoreThan", "countNumber": 10}, "action": {"type": "expire"}}]}`
+
+	err := o.Run()
+	require.Error(t, err, "should have failed to validate the lifecycle policy")
+	assert.Contains(t, err.Error(), "tagPrefixList or tagPatternList is required")
+	assert.Empty(t, fakeECR.Repositories, "should not have created any repositories")
+}
+
+func TestCreateWithLifecyclePolicyFlags(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
 	o.CacheSuffix = "/cache"
-	o.RegistryID = "123456789012"
+	o.ExpireUntaggedAfterDays = 3
+	o.KeepLastNTagged = 50
 
 	err := o.Run()
 	require.NoError(t, err, "failed to run")
 
-	// lets check we have a repository
+	policy := fakeECR.LifecyclePolicies["myapp"]
+	require.Contains(t, policy, "Expire untagged images older than 3 days", "lifecycle policy for myapp")
+	require.Contains(t, policy, "Keep the last 50 tagged images", "lifecycle policy for myapp")
+
+	// the cache repository uses the cache lifecycle policy
+	expected, err := ecrs.DefaultCacheLifecyclePolicy().ToJSON()
+	require.NoError(t, err, "failed to marshal the default cache lifecycle policy")
+	require.Equal(t, expected, fakeECR.LifecyclePolicies["myapp/cache"], "lifecycle policy for myapp/cache")
+}
+
+func TestCreateWithCacheLifecyclePolicy(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.CacheSuffix = "-cache"
+	o.ECRCacheLifecyclePolicy = `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "any", "countType": "imageCountMoreThan", "countNumber": 5}, "action": {"type": "expire"}}]}`
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	expected, err := ecrs.DefaultLifecyclePolicy().ToJSON()
+	require.NoError(t, err, "failed to marshal the default lifecycle policy")
+	assert.Equal(t, expected, fakeECR.LifecyclePolicies["myapp"], "should use the default lifecycle policy for the app")
+	assert.Equal(t, o.ECRCacheLifecyclePolicy, fakeECR.LifecyclePolicies["myapp-cache"], "should use the cache lifecycle policy for the cache")
+}
+
+func TestCreateKeepsExistingCacheLifecyclePolicy(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.CacheSuffix = "-cache"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	expected, err := ecrs.DefaultCacheLifecyclePolicy().ToJSON()
+	require.NoError(t, err, "failed to marshal the default cache lifecycle policy")
+	require.Equal(t, expected, fakeECR.LifecyclePolicies["myapp-cache"], "should use the default cache lifecycle policy for a new cache")
+
+	// lets simulate a hand managed lifecycle policy on the cache repository
+	custom := `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "any", "countType": "imageCountMoreThan", "countNumber": 5}, "action": {"type": "expire"}}]}`
+	fakeECR.LifecyclePolicies["myapp-cache"] = custom
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, custom, fakeECR.LifecyclePolicies["myapp-cache"], "should not have changed the existing cache lifecycle policy")
+}
+
+func TestCreateWithPoliciesFromConfigMapAndFiles(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	lifecyclePolicy := `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 7}, "action": {"type": "expire"}}]}`
+	repositoryPolicy := `{"Version": "2012-10-17", "Statement": [{"Sid": "AllowPull", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::210987654321:root"}, "Action": ["ecr:BatchGetImage"]}]}`
+
+	// lets put the repository policy in the dev environment git repository
+	devEnvDir := t.TempDir()
+	policyDir := filepath.Join(devEnvDir, requirements.PolicyDir)
+	require.NoError(t, os.MkdirAll(policyDir, 0o755), "failed to create dir")
+	require.NoError(t, os.WriteFile(filepath.Join(policyDir, requirements.RepositoryPolicyKey), []byte(repositoryPolicy), 0o600), "failed to write file")
+
+	o.CreateECRRepositoryPolicy = true
+	fakeClusterClients(o, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      requirements.PolicyConfigMapName,
+			Namespace: "jx",
+		},
+		Data: map[string]string{
+			requirements.LifecyclePolicyKey: lifecyclePolicy,
+		},
+	})
+	o.DevEnvironmentDir = devEnvDir
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, lifecyclePolicy, fakeECR.LifecyclePolicies["myapp"], "should have used the lifecycle policy from the ConfigMap")
+	assert.Equal(t, repositoryPolicy, fakeECR.RepositoryPolicies["myapp"], "should have used the repository policy from the dev environment repository")
+
+	// lets check a policy file takes precedence
+	fileLifecyclePolicy := `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}}]}`
+	file := filepath.Join(t.TempDir(), "lifecycle-policy.json")
+	require.NoError(t, os.WriteFile(file, []byte(fileLifecyclePolicy), 0o600), "failed to write file")
+
+	o.ECRLifecyclePolicy = ""
+	o.ECRLifecyclePolicyFile = file
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, fileLifecyclePolicy, fakeECR.LifecyclePolicies["myapp"], "should have used the lifecycle policy file")
+
+	o.ECRLifecyclePolicy = lifecyclePolicy
+	err = o.Run()
+	require.Error(t, err, "should fail when both a policy and a policy file are specified")
+}
+
+func TestCreateWithRepositoryRules(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(file, []byte(`rules:
+- glob: myorg/*-cache
+  imageTagMutability: MUTABLE
+  lifecyclePolicy: |
+    {"rules": [{"rulePriority": 1, "selection": {"tagStatus": "any", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}]}
+`), 0o600)
+	require.NoError(t, err, "failed to write file")
+
+	o.RegistryOrganisation = "myorg"
+	o.CacheSuffix = "-cache"
+	o.ImageTagMutability = "IMMUTABLE"
+	o.KeepLastNTagged = 20
+	o.RepositoryRulesFile = file
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
 	require.Len(t, fakeECR.Repositories, 2, "should have created 2 repositories")
 
-	for _, v := range fakeECR.Repositories {
-		require.NotNil(t, v.RepositoryUri, "should have a repository URI")
-		t.Logf("found ECR registry ID %s, Repository Name: %s ARN: %s URI: %s\n", ToString(v.RegistryId), ToString(v.RepositoryName), ToString(v.RepositoryArn), ToString(v.RepositoryUri))
+	assert.Equal(t, "IMMUTABLE", string(fakeECR.Repositories["myorg/myapp"].ImageTagMutability))
+	assert.Contains(t, fakeECR.LifecyclePolicies["myorg/myapp"], `"imageCountMoreThan"`, "should use the keep last n tagged policy for the release image")
+
+	assert.Equal(t, "MUTABLE", string(fakeECR.Repositories["myorg/myapp-cache"].ImageTagMutability))
+	assert.Contains(t, fakeECR.LifecyclePolicies["myorg/myapp-cache"], `"tagStatus": "any"`, "should use the rule policy for the cache image")
+}
+
+func TestCreateMergesAllowStatementsIntoRepositoryPolicy(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	existing := `{"Version": "2012-10-17", "Statement": [{"Sid": "Existing", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::333333333333:root"}, "Action": "ecr:BatchGetImage"}]}`
+	fakeECR.RepositoryPolicies["myapp"] = existing
+
+	o.AllowPullAccounts = []string{"111111111111"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	policy := fakeECR.RepositoryPolicies["myapp"]
+	assert.Contains(t, policy, `"Existing"`, "should keep the existing statement")
+	assert.Contains(t, policy, ecrs.SidAllowPull, "should add the pull statement")
+	assert.Contains(t, policy, "arn:aws:iam::111111111111:root", "should allow the account to pull")
+
+	// lets check we don't put the policy again
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, policy, fakeECR.RepositoryPolicies["myapp"], "should not have changed the repository policy")
+}
+
+func TestCreateWithRepositoryPolicyMergeMode(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	fakeECR.RepositoryPolicies["myapp"] = `{"Version": "2012-10-17", "Statement": [{"Sid": "OtherTeam", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::333333333333:root"}, "Action": "ecr:BatchGetImage"}]}`
+
+	o.CreateECRRepositoryPolicy = true
+	o.RepositoryPolicyMode = ecrs.PolicyModeMerge
+	o.ECRRepositoryPolicy = `{"Version": "2012-10-17", "Statement": [{"Sid": "Ours", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111111111111:root"}, "Action": "ecr:BatchGetImage"}]}`
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+
+	policy := fakeECR.RepositoryPolicies["myapp"]
+	assert.Contains(t, policy, `"OtherTeam"`, "should keep the statements of other teams")
+	assert.Contains(t, policy, `"Ours"`, "should add our statement")
+
+	o.RepositoryPolicyMode = "append"
+	err = o.Run()
+	require.Error(t, err, "should fail for an invalid mode")
+}
+
+func TestCreateInMultipleRegions(t *testing.T) {
+	o, usEast := newEKSOptions(t)
+
+	o.AWSRegion = "us-east-1"
+	o.Regions = []string{"us-east-1", "eu-west-1"}
+	o.RegistryOrganisation = "myorg"
+	euWest := fakeecr.NewFakeECR()
+	euWest.Region = "eu-west-1"
+	o.ECRClients = map[string]ecrs.ECRClient{
+		"eu-west-1": euWest,
+	}
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	for region, fakeECR := range map[string]*fakeecr.FakeECR{"us-east-1": usEast, "eu-west-1": euWest} {
+		require.Len(t, fakeECR.Repositories, 1, "should have created the repository in %s", region)
+		assert.NotEmpty(t, fakeECR.LifecyclePolicies["myorg/myapp"], "should have put the lifecycle policy in %s", region)
 	}
 }
 
-func ToString(p *string) string {
-	if p == nil {
-		return ""
+func TestCreateWithReplicationConfiguration(t *testing.T) {
+	o, fakeECR := newEKSOptions(t)
+
+	o.AWSRegion = "us-east-1"
+	o.RegistryID = "123456789012"
+	o.RegistryOrganisation = "myorg"
+	o.ReplicationDestinations = []string{"eu-west-1", "us-west-2=210987654321"}
+	fakeECR.Replication = &types.ReplicationConfiguration{
+		Rules: []types.ReplicationRule{
+			{
+				Destinations: []types.ReplicationDestination{
+					{
+						Region:     aws.String("ap-south-1"),
+						RegistryId: aws.String("123456789012"),
+					},
+				},
+				RepositoryFilters: []types.RepositoryFilter{
+					{
+						Filter:     aws.String("otherorg/"),
+						FilterType: types.RepositoryFilterTypePrefixMatch,
+					},
+				},
+			},
+		},
 	}
-	return *p
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	require.Len(t, fakeECR.Replication.Rules, 2, "should have added a rule")
+
+	rule := fakeECR.Replication.Rules[1]
+	require.Len(t, rule.RepositoryFilters, 1)
+	assert.Equal(t, "myorg/", aws.ToString(rule.RepositoryFilters[0].Filter))
+	require.Len(t, rule.Destinations, 2)
+	assert.Equal(t, "eu-west-1", aws.ToString(rule.Destinations[0].Region))
+	assert.Equal(t, "123456789012", aws.ToString(rule.Destinations[0].RegistryId))
+	assert.Equal(t, "210987654321", aws.ToString(rule.Destinations[1].RegistryId))
+
+	// lets check we don't add a duplicate rule
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	require.Len(t, fakeECR.Replication.Rules, 2, "should not have added a duplicate rule")
+}
+
+func TestCreateRejectsOverlappingReplicationDestinations(t *testing.T) {
+	testCases := []struct {
+		organisation string
+		regions      []string
+		destinations []string
+		expected     string
+	}{
+		{
+			organisation: "myorg",
+			destinations: []string{"us-east-1"},
+			expected:     "is the source region",
+		},
+		{
+			organisation: "myorg",
+			destinations: []string{"us-east-1=123456789012"},
+			expected:     "is the source region",
+		},
+		{
+			organisation: "myorg",
+			regions:      []string{"us-east-1", "eu-west-1"},
+			destinations: []string{"eu-west-1"},
+			expected:     "is also one of the --regions",
+		},
+		{
+			destinations: []string{"eu-west-1"},
+			expected:     "no registry organisation",
+		},
+	}
+	for _, tc := range testCases {
+		o, fakeECR := newEKSOptions(t)
+		o.AWSRegion = "us-east-1"
+		o.RegistryID = "123456789012"
+		o.RegistryOrganisation = tc.organisation
+		o.Regions = tc.regions
+		o.ReplicationDestinations = tc.destinations
+
+		err := o.Run()
+		require.Error(t, err, "should fail for regions %v and destinations %v", tc.regions, tc.destinations)
+		assert.Contains(t, err.Error(), tc.expected)
+		assert.Empty(t, fakeECR.Repositories, "should not have created any repositories")
+	}
+
+	// a different account in the same region is not an overlap
+	o, _ := newEKSOptions(t)
+	o.AWSRegion = "us-east-1"
+	o.RegistryID = "123456789012"
+	o.RegistryOrganisation = "myorg"
+	o.ReplicationDestinations = []string{"us-east-1=210987654321"}
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+}
+
+// fakeProvider records the repositories it is asked to ensure
+type fakeProvider struct {
+	registry     string
+	repositories []string
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Matches(_, registry string) bool {
+	return registry == p.registry
+}
+
+func (p *fakeProvider) EnsureRepository(image string, cache bool) error {
+	if cache {
+		image += " (cache)"
+	}
+	p.repositories = append(p.repositories, image)
+	return nil
+}
+
+func (p *fakeProvider) EnsureRetentionPolicy(_ string, _ bool) error {
+	return nil
+}
+
+func (p *fakeProvider) DeleteRepository(_ string, _ bool) error {
+	return nil
+}
+
+func (p *fakeProvider) ListRepositories() ([]providers.Repository, error) {
+	return nil, nil
+}
+
+func TestCreateWithCustomProvider(t *testing.T) {
+	_, o := create.NewCmdCreate()
+
+	o.Requirements = &jxcore.RequirementsConfig{
+		Cluster: jxcore.ClusterConfig{
+			Provider: "kubernetes",
+		},
+	}
+	o.Requirements.Cluster.Registry = "registry.example.com"
+	provider := &fakeProvider{registry: "registry.example.com"}
+	o.Providers = []providers.RegistryProvider{&fakeProvider{registry: "other.example.com"}, provider}
+	o.AppName = "myapp"
+	o.CacheSuffix = "/cache"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Equal(t, []string{"myapp", "myapp/cache (cache)"}, provider.repositories)
 }
@@ -0,0 +1,118 @@
+package delete
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Deletes the ECR repository for an app along with its cache repository if a cache suffix is specified.
+		Repositories containing images are only deleted if --force is specified. Repositories created in several regions
+		are deleted from each region given via --regions.
+`)
+
+	cmdExample = templates.Examples(`
+		# lets see which repositories would be deleted
+		%s delete --app myapp --cache-suffix /cache --dry-run
+
+		# deletes the repository and any images it contains
+		%s delete --app myapp --force
+	`)
+)
+
+// Options the options for this command
+type Options struct {
+	options.BaseOptions
+	ecrs.Options
+	requirements.FinderOptions
+
+	Force bool
+}
+
+// NewCmdDelete creates a command object for the command
+func NewCmdDelete() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "delete",
+		Short:   "Deletes the ECR repository for an app",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Aliases: []string{"rm"},
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	if o.Context == nil {
+		o.Context = cmd.Context()
+	}
+	o.Options.EnvProcess()
+
+	o.Options.AddRegistryFlags(cmd)
+	o.FinderOptions.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.AppName, "app", "a", o.AppName, "The app name to use. Defaults to $APP_NAME")
+	cmd.Flags().StringSliceVarP(&o.Regions, "regions", "", o.Regions, "The comma separated AWS regions to delete the repositories from. Defaults to the AWS region. Can be specified in $ECR_REGIONS")
+	cmd.Flags().StringVarP(&o.CacheSuffix, "cache-suffix", "", o.CacheSuffix, "If specified (or enabled via $CACHE_SUFFIX) we will delete the cache repository too")
+	cmd.Flags().BoolVarP(&o.Force, "force", "f", false, "Deletes the repositories even if they contain images")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "Only reports which repositories would be deleted")
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+func (o *Options) Validate() error {
+	if len(o.AppName) <= 2 {
+		return options.MissingOption("app")
+	}
+	if o.AWSRegion == "" {
+		requirements, err := o.FindRequirements(o.Owner, o.Repository)
+		if err != nil {
+			return err
+		}
+		o.AWSRegion = requirements.Cluster.Region
+		if o.Registry == "" {
+			o.Registry = requirements.Cluster.Registry
+		}
+	}
+	if o.AWSRegion == "" {
+		return options.MissingOption("aws-region")
+	}
+	return nil
+}
+
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	images := []string{o.AppName}
+	if o.CacheSuffix != "" {
+		images = append(images, o.AppName+o.CacheSuffix)
+	}
+	for _, image := range images {
+		repoName := o.RepositoryName(image)
+		log.Logger().Infof("deleting ECR repository %s", info(repoName))
+		err = o.DeleteRepository(repoName, o.Force)
+		if err != nil {
+			return fmt.Errorf("failed to delete the ECR repository for %s: %w", image, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package delete_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/create"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/delete"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDelete(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+
+	_, co := create.NewCmdCreate()
+	co.Requirements = &jxcore.RequirementsConfig{
+		Cluster: jxcore.ClusterConfig{
+			Provider: "eks",
+		},
+	}
+	co.AWSRegion = "dummy"
+	co.Config = &aws.Config{}
+	co.AppName = "MyApp"
+	co.RegistryOrganisation = "myorg"
+	co.CacheSuffix = "/cache"
+	co.ECRClient = fakeECR
+	co.Namespace = "jx"
+	co.KubeClient = fake.NewSimpleClientset()
+	co.JXClient = jxfake.NewSimpleClientset()
+
+	err := co.Run()
+	require.NoError(t, err, "failed to create repositories")
+	require.Len(t, fakeECR.Repositories, 2, "should have created 2 repositories")
+	fakeECR.Images["myorg/myapp"] = []types.ImageIdentifier{
+		{
+			ImageTag: aws.String("1.0.0"),
+		},
+	}
+
+	_, o := delete.NewCmdDelete()
+	o.AWSRegion = "dummy"
+	o.Config = &aws.Config{}
+	o.AppName = "MyApp"
+	o.RegistryOrganisation = "myorg"
+	o.CacheSuffix = "/cache"
+	o.ECRClient = fakeECR
+
+	err = o.Run()
+	require.Error(t, err, "should not delete a repository with images without --force")
+	assert.Contains(t, err.Error(), "--force")
+
+	o.Force = true
+	o.DryRun = true
+	err = o.Run()
+	require.NoError(t, err, "failed to run with --dry-run")
+	assert.Len(t, fakeECR.Repositories, 2, "should not delete repositories with --dry-run")
+
+	o.DryRun = false
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Empty(t, fakeECR.Repositories, "should have deleted the repositories")
+
+	err = o.Run()
+	require.NoError(t, err, "should ignore missing repositories")
+}
+
+func TestDeleteInRegions(t *testing.T) {
+	usEast := fakeecr.NewFakeECR()
+	euWest := fakeecr.NewFakeECR()
+	euWest.Region = "eu-west-1"
+
+	_, co := create.NewCmdCreate()
+	co.Requirements = &jxcore.RequirementsConfig{
+		Cluster: jxcore.ClusterConfig{
+			Provider: "eks",
+		},
+	}
+	co.AWSRegion = "us-east-1"
+	co.Regions = []string{"us-east-1", "eu-west-1"}
+	co.Config = &aws.Config{}
+	co.AppName = "myapp"
+	co.RegistryOrganisation = "myorg"
+	co.ECRClient = usEast
+	co.ECRClients = map[string]ecrs.ECRClient{
+		"eu-west-1": euWest,
+	}
+	co.Namespace = "jx"
+	co.KubeClient = fake.NewSimpleClientset()
+	co.JXClient = jxfake.NewSimpleClientset()
+
+	err := co.Run()
+	require.NoError(t, err, "failed to create repositories")
+	require.Len(t, usEast.Repositories, 1, "should have created the repository in us-east-1")
+	require.Len(t, euWest.Repositories, 1, "should have created the repository in eu-west-1")
+
+	_, o := delete.NewCmdDelete()
+	o.AWSRegion = "us-east-1"
+	o.Regions = []string{"us-east-1", "eu-west-1"}
+	o.Config = &aws.Config{}
+	o.AppName = "myapp"
+	o.RegistryOrganisation = "myorg"
+	o.ECRClient = usEast
+	o.ECRClients = map[string]ecrs.ECRClient{
+		"eu-west-1": euWest,
+	}
+
+	err = o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Empty(t, usEast.Repositories, "should have deleted the repository in us-east-1")
+	assert.Empty(t, euWest.Repositories, "should have deleted the repository in eu-west-1")
+}
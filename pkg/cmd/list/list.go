@@ -0,0 +1,138 @@
+package list
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/outputformat"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Lists the ECR repositories in the registry. If an organisation is specified only the repositories for that organisation are listed.
+`)
+
+	cmdExample = templates.Examples(`
+		# lists the ECR repositories for the organisation
+		%s list
+
+		# lists the ECR repositories as YAML
+		%s list --output yaml
+	`)
+
+	outputFormats = []string{"table", "json", "yaml"}
+)
+
+// Options the options for this command
+type Options struct {
+	options.BaseOptions
+	ecrs.Options
+	requirements.FinderOptions
+
+	Output string
+	Out    io.Writer
+}
+
+// NewCmdList creates a command object for the command
+func NewCmdList() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "Lists the ECR repositories",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Aliases: []string{"ls"},
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	if o.Context == nil {
+		o.Context = cmd.Context()
+	}
+	o.Options.EnvProcess()
+
+	o.Options.AddRegistryFlags(cmd)
+	o.FinderOptions.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.Output, "output", "", "table", "The output format. One of: table, json or yaml")
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+func (o *Options) Validate() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.Output == "" {
+		o.Output = "table"
+	}
+	if stringhelpers.StringArrayIndex(outputFormats, o.Output) < 0 {
+		return options.InvalidOption("output", o.Output, outputFormats)
+	}
+	if o.AWSRegion == "" {
+		requirements, err := o.FindRequirements(o.Owner, o.Repository)
+		if err != nil {
+			return err
+		}
+		o.AWSRegion = requirements.Cluster.Region
+		if o.Registry == "" {
+			o.Registry = requirements.Cluster.Registry
+		}
+	}
+	if o.AWSRegion == "" {
+		return options.MissingOption("aws-region")
+	}
+	return nil
+}
+
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	repos, err := o.ListRepositories()
+	if err != nil {
+		return fmt.Errorf("failed to list the ECR repositories: %w", err)
+	}
+
+	if o.Output != "table" {
+		err = outputformat.Marshal(repos, o.Out, o.Output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the repositories as %s: %w", o.Output, err)
+		}
+		_, err = fmt.Fprintln(o.Out)
+		return err
+	}
+
+	t := table.CreateTable(o.Out)
+	t.AddRow("NAME", "URI", "CREATED", "TAG MUTABILITY", "SCAN ON PUSH", "ENCRYPTION", "LIFECYCLE POLICY", "REPOSITORY POLICY")
+	for i := range repos {
+		r := &repos[i]
+		created := ""
+		if r.CreatedAt != nil {
+			created = r.CreatedAt.Format(time.RFC3339)
+		}
+		t.AddRow(r.Name, r.URI, created, r.ImageTagMutability, strconv.FormatBool(r.ScanOnPush), r.Encryption,
+			strconv.FormatBool(r.LifecyclePolicy), strconv.FormatBool(r.RepositoryPolicy))
+	}
+	t.Render()
+	return nil
+}
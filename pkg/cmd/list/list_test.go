@@ -0,0 +1,59 @@
+package list_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/list"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                fakeECR,
+		RegistryOrganisation:     "myorg",
+		CreateECRLifeCyclePolicy: true,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+	for _, app := range []string{"app1", "app2"} {
+		err := o.LazyCreateRegistry(app)
+		require.NoError(t, err, "failed to create repository for %s", app)
+	}
+	o.RegistryOrganisation = "other"
+	err := o.LazyCreateRegistry("app3")
+	require.NoError(t, err, "failed to create repository for app3")
+
+	_, lo := list.NewCmdList()
+	out := &bytes.Buffer{}
+	lo.Out = out
+	lo.Output = "json"
+	lo.AWSRegion = "us-east-1"
+	lo.Config = &aws.Config{}
+	lo.ECRClient = fakeECR
+	lo.RegistryOrganisation = "myorg"
+
+	err = lo.Run()
+	require.NoError(t, err, "failed to run")
+
+	var repos []ecrs.RepositoryInfo
+	err = json.Unmarshal(out.Bytes(), &repos)
+	require.NoError(t, err, "failed to parse output %s", out.String())
+	require.Len(t, repos, 2, "should have found the repositories for the organisation")
+	assert.Equal(t, "myorg/app1", repos[0].Name)
+	assert.Equal(t, "myorg/app2", repos[1].Name)
+	assert.True(t, repos[0].LifecyclePolicy, "should have a lifecycle policy")
+
+	out.Reset()
+	lo.Output = "table"
+	err = lo.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Contains(t, out.String(), "myorg/app2")
+	assert.NotContains(t, out.String(), "other/app3")
+}
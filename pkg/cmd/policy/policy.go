@@ -0,0 +1,25 @@
+package policy
+
+import (
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/policy/validate"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdPolicy creates the command
+func NewCmdPolicy() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "policy",
+		Short:   "Commands for working with ECR policies",
+		Aliases: []string{"policies"},
+		Run: func(cmd *cobra.Command, _ []string) {
+			err := cmd.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	cmd.AddCommand(cobras.SplitCommand(validate.NewCmdPolicyValidate()))
+	return cmd
+}
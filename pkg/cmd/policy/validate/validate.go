@@ -0,0 +1,72 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Validates an ECR lifecycle policy file without calling ECR.
+
+		Checks the rule priorities are unique, the tagStatus, countType and countUnit combinations are valid, that tagPrefixList or tagPatternList is present for tagged rules and that only one rule uses tagStatus any. Each problem is reported with its line and column. Unknown fields and action types are reported as warnings as newer ECR versions may accept them.
+`)
+
+	cmdExample = templates.Examples(`
+		# validates a lifecycle policy file
+		%s policy validate --file lifecycle-policy.json
+	`)
+)
+
+// Options the options for this command
+type Options struct {
+	options.BaseOptions
+
+	File string
+}
+
+// NewCmdPolicyValidate creates a command object for the command
+func NewCmdPolicyValidate() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "validate",
+		Short:   "Validates an ECR lifecycle policy file",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "The lifecycle policy file to validate")
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.File == "" {
+		return options.MissingOption("file")
+	}
+	data, err := os.ReadFile(o.File)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", o.File, err)
+	}
+	err = ecrs.ValidateLifecyclePolicy(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid lifecycle policy %s:\n%w", o.File, err)
+	}
+	log.Logger().Infof("lifecycle policy %s is valid", termcolor.ColorInfo(o.File))
+	return nil
+}
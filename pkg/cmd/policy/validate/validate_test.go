@@ -0,0 +1,54 @@
+package validate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/policy/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   string
+		expected string
+	}{
+		{
+			name:   "valid",
+			policy: `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1}, "action": {"type": "expire"}}]}`,
+		},
+		{
+			name:   "unknown field",
+			policy: `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 1, "storageClass": "standard"}}]}`,
+		},
+		{
+			name:     "invalid",
+			policy:   `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "tagged", "countType": "imageCountMoreThan", "countNumber": 10}}]}`,
+			expected: "tagPrefixList or tagPatternList is required",
+		},
+	}
+	for _, tc := range testCases {
+		file := filepath.Join(t.TempDir(), "lifecycle-policy.json")
+		err := os.WriteFile(file, []byte(tc.policy), 0o600)
+		require.NoError(t, err, "failed to write file %s", file)
+
+		_, o := validate.NewCmdPolicyValidate()
+		o.File = file
+		err = o.Run()
+		if tc.expected == "" {
+			assert.NoError(t, err, "for %s", tc.name)
+			continue
+		}
+		require.Error(t, err, "for %s", tc.name)
+		assert.Contains(t, err.Error(), tc.expected, "for %s", tc.name)
+	}
+}
+
+func TestPolicyValidateMissingFile(t *testing.T) {
+	_, o := validate.NewCmdPolicyValidate()
+	err := o.Run()
+	require.Error(t, err, "should fail without a file")
+}
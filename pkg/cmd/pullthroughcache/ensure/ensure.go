@@ -0,0 +1,129 @@
+package ensure
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Creates or updates the ECR pull through cache rules from a declarative YAML file.
+
+		Each rule has an upstreamRegistryUrl, an ecrRepositoryPrefix and an optional credentialArn of the Secrets Manager secret
+		of the upstream registry credentials, upstreamRegistry kind and lifecyclePolicy.
+
+		The lifecycle policy of the repositories ECR has created for each rule is then reconciled. The lifecycle policy of the rule is used
+		falling back to the lifecycle policy flags then the default cache lifecycle policy which expires untagged images after 1 day
+		and keeps the last 10 tagged images.
+`)
+
+	cmdExample = templates.Examples(`
+		# creates or updates the pull through cache rules
+		%s pull-through-cache ensure --file pull-through-cache.yaml
+
+		# reports the changes which would be made without changing anything
+		%s pull-through-cache ensure --file pull-through-cache.yaml --dry-run
+	`)
+)
+
+// Options the options for this command
+type Options struct {
+	options.BaseOptions
+	ecrs.Options
+	requirements.FinderOptions
+
+	File string
+}
+
+// NewCmdPullThroughCacheEnsure creates a command object for the command
+func NewCmdPullThroughCacheEnsure() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "ensure",
+		Short:   "Creates or updates the ECR pull through cache rules and the lifecycle policy of their repositories",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	if o.Context == nil {
+		o.Context = cmd.Context()
+	}
+	o.Options.EnvProcess()
+
+	o.Options.AddRegistryFlags(cmd)
+	o.Options.AddLifecyclePolicyFlags(cmd)
+	o.FinderOptions.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.File, "file", "f", "", "The YAML file of the pull through cache rules")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "Only reports which pull through cache rules would be created or updated and the changes to the lifecycle policies of their repositories without changing anything")
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+func (o *Options) Validate() error {
+	if o.File == "" {
+		return options.MissingOption("file")
+	}
+	if o.LifecyclePolicyMode != "" && stringhelpers.StringArrayIndex(ecrs.PolicyModes, o.LifecyclePolicyMode) < 0 {
+		return options.InvalidOption("lifecycle-policy-mode", o.LifecyclePolicyMode, ecrs.PolicyModes)
+	}
+	err := o.LoadPolicyFiles()
+	if err != nil {
+		return err
+	}
+	policy, err := o.DesiredLifecyclePolicy()
+	if err != nil {
+		return err
+	}
+	if policy != "" {
+		err = ecrs.ValidateLifecyclePolicy(policy)
+		if err != nil {
+			return fmt.Errorf("invalid lifecycle policy:\n%w", err)
+		}
+	}
+	if o.AWSRegion == "" {
+		requirements, err := o.FindRequirements(o.Owner, o.Repository)
+		if err != nil {
+			return err
+		}
+		o.AWSRegion = requirements.Cluster.Region
+		if o.Registry == "" {
+			o.Registry = requirements.Cluster.Registry
+		}
+	}
+	if o.AWSRegion == "" {
+		return options.MissingOption("aws-region")
+	}
+	return nil
+}
+
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	rules, err := ecrs.LoadPullThroughCacheRules(o.File)
+	if err != nil {
+		return err
+	}
+	err = o.EnsurePullThroughCacheRules(rules)
+	if err != nil {
+		return fmt.Errorf("failed to ensure the pull through cache rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package ensure_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/pullthroughcache/ensure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullThroughCacheEnsure(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	fakeECR.Repositories["quay/jetstack/cert-manager-controller"] = &types.Repository{RepositoryName: aws.String("quay/jetstack/cert-manager-controller")}
+	fakeECR.Repositories["ecr-public/docker/library/nginx"] = &types.Repository{RepositoryName: aws.String("ecr-public/docker/library/nginx")}
+
+	_, o := ensure.NewCmdPullThroughCacheEnsure()
+	o.File = "testdata/pull-through-cache.yaml"
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+	o.ECRClient = fakeECR
+	o.KeepLastNTagged = 20
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	assert.Len(t, fakeECR.PullThroughCache, 2, "should have created the rules")
+	assert.Equal(t, "quay.io", aws.ToString(fakeECR.PullThroughCache["quay"].UpstreamRegistryUrl))
+
+	assert.Contains(t, fakeECR.LifecyclePolicies["quay/jetstack/cert-manager-controller"], "Keep the last 5 images", "should use the lifecycle policy of the rule")
+
+	policy := &ecrs.LifecyclePolicy{}
+	err = json.Unmarshal([]byte(fakeECR.LifecyclePolicies["ecr-public/docker/library/nginx"]), policy)
+	require.NoError(t, err, "failed to parse lifecycle policy")
+	require.Len(t, policy.Rules, 1, "should use the lifecycle policy flags")
+	assert.Equal(t, 20, policy.Rules[0].Selection.CountNumber)
+}
+
+func TestPullThroughCacheEnsureRequiresFile(t *testing.T) {
+	_, o := ensure.NewCmdPullThroughCacheEnsure()
+	o.AWSRegion = "us-east-1"
+	o.ECRClient = fakeecr.NewFakeECR()
+
+	err := o.Run()
+	require.Error(t, err, "should fail without a file")
+	assert.Contains(t, err.Error(), "file")
+}
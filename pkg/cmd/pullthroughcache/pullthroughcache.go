@@ -0,0 +1,25 @@
+package pullthroughcache
+
+import (
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/pullthroughcache/ensure"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdPullThroughCache creates the command
+func NewCmdPullThroughCache() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "pull-through-cache",
+		Short:   "Commands for working with ECR pull through cache rules",
+		Aliases: []string{"ptc"},
+		Run: func(cmd *cobra.Command, _ []string) {
+			err := cmd.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	cmd.AddCommand(cobras.SplitCommand(ensure.NewCmdPullThroughCacheEnsure()))
+	return cmd
+}
@@ -2,6 +2,12 @@ package cmd
 
 import (
 	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/create"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/delete"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/list"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/policy"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/pullthroughcache"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/scanfindings"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/scanning"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/version"
 	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
@@ -25,6 +31,12 @@ func Main() *cobra.Command {
 	}
 
 	cmd.AddCommand(cobras.SplitCommand(create.NewCmdCreate()))
+	cmd.AddCommand(cobras.SplitCommand(delete.NewCmdDelete()))
+	cmd.AddCommand(cobras.SplitCommand(list.NewCmdList()))
+	cmd.AddCommand(policy.NewCmdPolicy())
+	cmd.AddCommand(pullthroughcache.NewCmdPullThroughCache())
+	cmd.AddCommand(cobras.SplitCommand(scanfindings.NewCmdScanFindings()))
+	cmd.AddCommand(scanning.NewCmdScanning())
 	cmd.AddCommand(cobras.SplitCommand(version.NewCmdVersion()))
 	return cmd
 }
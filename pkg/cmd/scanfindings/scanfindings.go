@@ -0,0 +1,192 @@
+package scanfindings
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/outputformat"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Waits for the ECR scan of an image to complete then prints its findings by severity.
+
+		The repository name of the image is resolved in the same way as the create command so the image can be the app name and tag.
+		Any registry host is removed and names which already start with the organisation are used as they are.
+
+		Fails if the number of findings of a severity exceeds its maximum so that it can be used as a promotion gate in a release pipeline.
+`)
+
+	cmdExample = templates.Examples(`
+		# fails if the image has any critical or more than 5 high findings
+		%s scan-findings --image myapp:1.2.3 --max-critical 0 --max-high 5
+
+		# prints the findings as JSON waiting up to 30 minutes for the scan
+		%s scan-findings --image myapp:1.2.3 --timeout 30m --output json
+	`)
+
+	outputFormats = []string{"table", "json", "yaml"}
+)
+
+// Options the options for this command
+type Options struct {
+	options.BaseOptions
+	ecrs.Options
+	requirements.FinderOptions
+
+	Image        string
+	Timeout      time.Duration
+	PollInterval time.Duration
+	MaxCritical  int
+	MaxHigh      int
+	MaxMedium    int
+	MaxLow       int
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdScanFindings creates a command object for the command
+func NewCmdScanFindings() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "scan-findings",
+		Short:   "Waits for the ECR scan of an image and fails if its findings exceed the thresholds",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName),
+		Aliases: []string{"findings"},
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	if o.Context == nil {
+		o.Context = cmd.Context()
+	}
+	o.Options.EnvProcess()
+
+	o.Options.AddRegistryFlags(cmd)
+	o.FinderOptions.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.Image, "image", "i", "", "The image to check of the form name:tag or name@digest")
+	cmd.Flags().DurationVarP(&o.Timeout, "timeout", "t", 10*time.Minute, "The maximum time to wait for the scan to complete")
+	cmd.Flags().DurationVarP(&o.PollInterval, "poll-interval", "", 5*time.Second, "The minimum time between checks of the scan status")
+	cmd.Flags().IntVarP(&o.MaxCritical, "max-critical", "", -1, "The maximum number of CRITICAL findings. Negative values are not checked")
+	cmd.Flags().IntVarP(&o.MaxHigh, "max-high", "", -1, "The maximum number of HIGH findings. Negative values are not checked")
+	cmd.Flags().IntVarP(&o.MaxMedium, "max-medium", "", -1, "The maximum number of MEDIUM findings. Negative values are not checked")
+	cmd.Flags().IntVarP(&o.MaxLow, "max-low", "", -1, "The maximum number of LOW findings. Negative values are not checked")
+	cmd.Flags().StringVarP(&o.Output, "output", "", "table", "The output format. One of: table, json or yaml")
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+func (o *Options) Validate() error {
+	if o.Image == "" {
+		return options.MissingOption("image")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.Output == "" {
+		o.Output = "table"
+	}
+	if stringhelpers.StringArrayIndex(outputFormats, o.Output) < 0 {
+		return options.InvalidOption("output", o.Output, outputFormats)
+	}
+	if o.Timeout <= 0 {
+		return fmt.Errorf("the --timeout must be greater than zero")
+	}
+	if o.PollInterval <= 0 {
+		return fmt.Errorf("the --poll-interval must be greater than zero")
+	}
+	if o.AWSRegion == "" {
+		requirements, err := o.FindRequirements(o.Owner, o.Repository)
+		if err != nil {
+			return err
+		}
+		o.AWSRegion = requirements.Cluster.Region
+		if o.Registry == "" {
+			o.Registry = requirements.Cluster.Registry
+		}
+	}
+	if o.AWSRegion == "" {
+		return options.MissingOption("aws-region")
+	}
+	return nil
+}
+
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	repoName, imageID, err := o.ImageReference(o.Image)
+	if err != nil {
+		return err
+	}
+	result, err := o.WaitForImageScanFindings(repoName, imageID, o.Timeout, o.PollInterval)
+	if err != nil {
+		return err
+	}
+
+	err = o.printResult(result)
+	if err != nil {
+		return err
+	}
+	return result.CheckThresholds(map[string]int{
+		string(types.FindingSeverityCritical): o.MaxCritical,
+		string(types.FindingSeverityHigh):     o.MaxHigh,
+		string(types.FindingSeverityMedium):   o.MaxMedium,
+		string(types.FindingSeverityLow):      o.MaxLow,
+	})
+}
+
+func (o *Options) printResult(result *ecrs.ImageScanResult) error {
+	if o.Output != "table" {
+		err := outputformat.Marshal(result, o.Out, o.Output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the scan findings as %s: %w", o.Output, err)
+		}
+		_, err = fmt.Fprintln(o.Out)
+		return err
+	}
+
+	t := table.CreateTable(o.Out)
+	t.AddRow("SEVERITY", "COUNT")
+	for _, severity := range ecrs.Severities {
+		t.AddRow(severity, strconv.Itoa(result.SeverityCounts[severity]))
+	}
+	t.Render()
+
+	if len(result.Findings) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(o.Out)
+	if err != nil {
+		return err
+	}
+	t = table.CreateTable(o.Out)
+	t.AddRow("SEVERITY", "NAME", "PACKAGE", "VERSION", "URI")
+	for i := range result.Findings {
+		f := &result.Findings[i]
+		t.AddRow(f.Severity, f.Name, f.Package, f.Version, f.URI)
+	}
+	t.Render()
+	return nil
+}
@@ -0,0 +1,56 @@
+package scanfindings_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/scanfindings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanFindings(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	fakeECR.ScanInProgressCalls = 1
+	fakeECR.ScanFindings["myorg/myapp:1.2.3"] = &types.ImageScanFindings{
+		EnhancedFindings: []types.EnhancedImageScanFinding{
+			{
+				Title:    aws.String("CVE-2024-0001 - openssl"),
+				Severity: aws.String("HIGH"),
+				PackageVulnerabilityDetails: &types.PackageVulnerabilityDetails{
+					VulnerabilityId: aws.String("CVE-2024-0001"),
+					SourceUrl:       aws.String("https://nvd.nist.gov/vuln/detail/CVE-2024-0001"),
+					VulnerablePackages: []types.VulnerablePackage{
+						{Name: aws.String("openssl"), Version: aws.String("3.0.1")},
+					},
+				},
+			},
+		},
+	}
+
+	_, o := scanfindings.NewCmdScanFindings()
+	out := &bytes.Buffer{}
+	o.Out = out
+	o.Image = "myapp:1.2.3"
+	o.PollInterval = time.Millisecond
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+	o.ECRClient = fakeECR
+	o.RegistryOrganisation = "myorg"
+	o.MaxCritical = 0
+	o.MaxHigh = 1
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+	assert.Contains(t, out.String(), "CVE-2024-0001")
+	assert.Contains(t, out.String(), "openssl")
+
+	o.MaxHigh = 0
+	err = o.Run()
+	require.Error(t, err, "should fail when the findings exceed the thresholds")
+	assert.Contains(t, err.Error(), "1 HIGH findings, more than the maximum of 0")
+}
@@ -0,0 +1,108 @@
+package ensure
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/rootcmd"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Ensures the registry wide ECR scanning configuration uses the scan type and scans the repositories matching the scan filters.
+
+		By default ENHANCED scanning is used with CONTINUOUS_SCAN of the repositories of the organisation so that every repository
+		created by this plugin is scanned continuously. Scanning every repository in the registry requires an explicit --scan-filter '*'. The scan on push setting of each repository is ignored by ECR when using ENHANCED scanning.
+
+		Each filter is moved to the rule of its scan frequency and the filters of any other rules are left unchanged.
+`)
+
+	cmdExample = templates.Examples(`
+		# ensures the repositories of the organisation are continuously scanned with enhanced scanning
+		%s scanning ensure
+
+		# scans the organisation continuously and the cache repositories on push
+		%s scanning ensure --scan-filter 'myorg/*' --scan-filter 'myorg/*-cache=SCAN_ON_PUSH'
+
+		# uses basic scanning on push
+		%s scanning ensure --scan-type BASIC --scan-frequency SCAN_ON_PUSH --dry-run
+	`)
+)
+
+// Options the options for this command
+type Options struct {
+	options.BaseOptions
+	ecrs.Options
+	requirements.FinderOptions
+}
+
+// NewCmdScanningEnsure creates a command object for the command
+func NewCmdScanningEnsure() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "ensure",
+		Short:   "Ensures the ECR registry scanning configuration",
+		Long:    cmdLong,
+		Example: fmt.Sprintf(cmdExample, rootcmd.BinaryName, rootcmd.BinaryName, rootcmd.BinaryName),
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	if o.Context == nil {
+		o.Context = cmd.Context()
+	}
+	o.Options.EnvProcess()
+
+	o.Options.AddRegistryFlags(cmd)
+	o.Options.AddScanningFlags(cmd)
+	o.FinderOptions.AddFlags(cmd)
+
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "Only reports the changes to the registry scanning configuration without changing anything")
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	return cmd, o
+}
+
+func (o *Options) Validate() error {
+	// the organisation is only needed to default the scan filter
+	if o.AWSRegion == "" || (o.RegistryOrganisation == "" && len(o.ScanFilters) == 0) {
+		requirements, err := o.FindRequirements(o.Owner, o.Repository)
+		if err != nil {
+			return err
+		}
+		if o.AWSRegion == "" {
+			o.AWSRegion = requirements.Cluster.Region
+		}
+		if o.Registry == "" {
+			o.Registry = requirements.Cluster.Registry
+		}
+		if o.RegistryOrganisation == "" {
+			o.RegistryOrganisation = requirements.Cluster.DockerRegistryOrg
+		}
+	}
+	if o.AWSRegion == "" {
+		return options.MissingOption("aws-region")
+	}
+	return nil
+}
+
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	err = o.EnsureRegistryScanningConfiguration()
+	if err != nil {
+		return fmt.Errorf("failed to ensure the registry scanning configuration: %w", err)
+	}
+	return nil
+}
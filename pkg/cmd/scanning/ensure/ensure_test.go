@@ -0,0 +1,63 @@
+package ensure_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/scanning/ensure"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanningEnsure(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+
+	_, o := ensure.NewCmdScanningEnsure()
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+	o.ECRClient = fakeECR
+	o.RegistryOrganisation = "MyOrg"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	require.NotNil(t, fakeECR.Scanning, "should have put the scanning configuration")
+	assert.Equal(t, types.ScanTypeEnhanced, fakeECR.Scanning.ScanType, "should default to enhanced scanning")
+	require.Len(t, fakeECR.Scanning.Rules, 1)
+	rule := fakeECR.Scanning.Rules[0]
+	assert.Equal(t, types.ScanFrequencyContinuousScan, rule.ScanFrequency, "should default to continuous scanning")
+	require.Len(t, rule.RepositoryFilters, 1)
+	assert.Equal(t, "myorg/*", aws.ToString(rule.RepositoryFilters[0].Filter), "should default to the organisation filter")
+}
+
+func TestScanningEnsureFindsOrganisationWithRegion(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+
+	_, o := ensure.NewCmdScanningEnsure()
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+	o.ECRClient = fakeECR
+	o.Requirements = &jxcore.RequirementsConfig{}
+	o.Requirements.Cluster.DockerRegistryOrg = "myorg"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run")
+
+	require.NotNil(t, fakeECR.Scanning, "should have put the scanning configuration")
+	require.Len(t, fakeECR.Scanning.Rules, 1)
+	require.Len(t, fakeECR.Scanning.Rules[0].RepositoryFilters, 1)
+	assert.Equal(t, "myorg/*", aws.ToString(fakeECR.Scanning.Rules[0].RepositoryFilters[0].Filter), "should use the organisation of the requirements")
+
+	// lets check we never default to scanning every repository
+	o.RegistryOrganisation = ""
+	o.Requirements.Cluster.DockerRegistryOrg = ""
+	err = o.Run()
+	require.Error(t, err, "should fail without an organisation or scan filter")
+
+	o.ScanFilters = []string{"*"}
+	err = o.Run()
+	require.NoError(t, err, "failed to run with an explicit scan filter")
+}
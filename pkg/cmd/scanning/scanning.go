@@ -0,0 +1,25 @@
+package scanning
+
+import (
+	"github.com/jenkins-x-plugins/jx-registry/pkg/cmd/scanning/ensure"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdScanning creates the command
+func NewCmdScanning() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "scanning",
+		Short:   "Commands for working with the ECR registry scanning configuration",
+		Aliases: []string{"scan"},
+		Run: func(cmd *cobra.Command, _ []string) {
+			err := cmd.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	cmd.AddCommand(cobras.SplitCommand(ensure.NewCmdScanningEnsure()))
+	return cmd
+}
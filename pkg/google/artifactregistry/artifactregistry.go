@@ -0,0 +1,282 @@
+package artifactregistry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/artifactregistry/apiv1/artifactregistrypb"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// HostSuffix the suffix of the hosts of Docker format Artifact Registry repositories
+	HostSuffix = "-docker.pkg.dev"
+)
+
+// Options the options for lazily creating Artifact Registry repositories
+type Options struct {
+	Context              context.Context
+	Registry             string
+	RegistryOrganisation string
+	DryRun               bool
+	Client               Client
+}
+
+// RepositoryRef the Artifact Registry repository and package of an image
+type RepositoryRef struct {
+	Host       string
+	Location   string
+	Project    string
+	Repository string
+	Package    string
+}
+
+// Name returns the resource name of the repository
+func (r *RepositoryRef) Name() string {
+	return fmt.Sprintf("projects/%s/locations/%s/repositories/%s", r.Project, r.Location, r.Repository)
+}
+
+// Parent returns the resource name of the project location of the repository
+func (r *RepositoryRef) Parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", r.Project, r.Location)
+}
+
+// PackageName returns the resource name of the package
+func (r *RepositoryRef) PackageName() string {
+	return r.Name() + "/packages/" + url.PathEscape(r.Package)
+}
+
+// URI returns the URI of the repository
+func (r *RepositoryRef) URI() string {
+	return r.Host + "/" + r.Project + "/" + r.Repository
+}
+
+// RepositoryRef returns the repository and package of the image from the registry such as
+// us-docker.pkg.dev/project/repo and the registry organisation. The registry organisation can also specify the
+// repository such as when the registry is us-docker.pkg.dev/project
+func (o *Options) RepositoryRef(image string) (*RepositoryRef, error) {
+	host, path, _ := strings.Cut(strings.TrimSuffix(o.Registry, "/"), "/")
+	if !strings.HasSuffix(host, HostSuffix) {
+		return nil, fmt.Errorf("registry %s is not an Artifact Registry host ending with %s", o.Registry, HostSuffix)
+	}
+	var paths []string
+	for _, p := range strings.Split(path+"/"+o.RegistryOrganisation, "/") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	if len(paths) < 2 {
+		return nil, fmt.Errorf("registry %s with organisation %s should be of the form LOCATION%s/PROJECT/REPOSITORY", o.Registry, o.RegistryOrganisation, HostSuffix)
+	}
+	image, _, _ = strings.Cut(image, ":")
+	pkg := strings.ToLower(strings.Join(append(paths[2:], image), "/"))
+	return &RepositoryRef{
+		Host:       host,
+		Location:   strings.TrimSuffix(host, HostSuffix),
+		Project:    paths[0],
+		Repository: paths[1],
+		Package:    pkg,
+	}, nil
+}
+
+// LazyCreateRepository lazily creates the Docker format repository of the image then ensures its cleanup policies
+func (o *Options) LazyCreateRepository(image string, cache bool) error {
+	ref, err := o.RepositoryRef(image)
+	if err != nil {
+		return err
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+	repo, err := client.GetRepository(ctx, &artifactregistrypb.GetRepositoryRequest{Name: ref.Name()})
+	if err == nil {
+		return o.ensureCleanupPolicies(client, ref, repo, cache)
+	}
+	if status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to get Artifact Registry repository %s: %w", ref.Name(), err)
+	}
+
+	repo = &artifactregistrypb.Repository{
+		Format:          artifactregistrypb.Repository_DOCKER,
+		Description:     "Created by jx-registry",
+		CleanupPolicies: desiredCleanupPolicies(ref, cache, nil),
+	}
+	if o.DryRun {
+		log.Logger().Infof("would create Artifact Registry repository %s with cleanup policies %s", termcolor.ColorInfo(ref.URI()), termcolor.ColorInfo(cleanupPolicyIDs(repo.CleanupPolicies)))
+		return nil
+	}
+	_, err = client.CreateRepository(ctx, &artifactregistrypb.CreateRepositoryRequest{
+		Parent:       ref.Parent(),
+		RepositoryId: ref.Repository,
+		Repository:   repo,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create Artifact Registry repository %s: %w", ref.Name(), err)
+	}
+	log.Logger().Infof("Created Artifact Registry repository %s", termcolor.ColorInfo(ref.URI()))
+	return nil
+}
+
+// EnsureCleanupPolicies ensures the cleanup policies of the existing repository of the image
+func (o *Options) EnsureCleanupPolicies(image string, cache bool) error {
+	ref, err := o.RepositoryRef(image)
+	if err != nil {
+		return err
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	repo, err := client.GetRepository(o.GetContext(), &artifactregistrypb.GetRepositoryRequest{Name: ref.Name()})
+	if err != nil {
+		return fmt.Errorf("failed to get Artifact Registry repository %s: %w", ref.Name(), err)
+	}
+	return o.ensureCleanupPolicies(client, ref, repo, cache)
+}
+
+// ensureCleanupPolicies adds or updates the cleanup policies of the repository leaving any other policies unchanged
+func (o *Options) ensureCleanupPolicies(client Client, ref *RepositoryRef, repo *artifactregistrypb.Repository, cache bool) error {
+	if repo.Format != artifactregistrypb.Repository_DOCKER {
+		return fmt.Errorf("Artifact Registry repository %s has the format %s rather than DOCKER", ref.Name(), repo.Format.String())
+	}
+	policies := map[string]*artifactregistrypb.CleanupPolicy{}
+	for k, v := range repo.CleanupPolicies {
+		policies[k] = v
+	}
+	var changed []string
+	for id, policy := range desiredCleanupPolicies(ref, cache, repo.CleanupPolicies) {
+		if proto.Equal(policies[id], policy) {
+			continue
+		}
+		policies[id] = policy
+		changed = append(changed, id)
+	}
+	if len(changed) == 0 {
+		log.Logger().Debugf("cleanup policies of Artifact Registry repository %s are up to date", ref.URI())
+		return nil
+	}
+	sort.Strings(changed)
+	if o.DryRun {
+		log.Logger().Infof("would put cleanup policies %s on Artifact Registry repository %s", termcolor.ColorInfo(strings.Join(changed, ", ")), termcolor.ColorInfo(ref.URI()))
+		return nil
+	}
+	_, err := client.UpdateRepository(o.GetContext(), &artifactregistrypb.UpdateRepositoryRequest{
+		Repository: &artifactregistrypb.Repository{
+			Name:            ref.Name(),
+			CleanupPolicies: policies,
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"cleanup_policies"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the cleanup policies of Artifact Registry repository %s: %w", ref.Name(), err)
+	}
+	log.Logger().Infof("Put cleanup policies %s on Artifact Registry repository %s", termcolor.ColorInfo(strings.Join(changed, ", ")), termcolor.ColorInfo(ref.URI()))
+	return nil
+}
+
+// ListPackages lists the packages of the repository whose names start with the registry organisation path
+func (o *Options) ListPackages() ([]*RepositoryRef, error) {
+	ref, err := o.RepositoryRef("")
+	if err != nil {
+		return nil, err
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return nil, err
+	}
+	packages, err := client.ListPackages(o.GetContext(), &artifactregistrypb.ListPackagesRequest{Parent: ref.Name()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the packages of Artifact Registry repository %s: %w", ref.Name(), err)
+	}
+	var answer []*RepositoryRef
+	for _, p := range packages {
+		id := p.Name[strings.LastIndex(p.Name, "/")+1:]
+		name, err := url.PathUnescape(id)
+		if err != nil {
+			name = id
+		}
+		if !strings.HasPrefix(name, ref.Package) {
+			continue
+		}
+		r := *ref
+		r.Package = name
+		answer = append(answer, &r)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Package < answer[j].Package
+	})
+	return answer, nil
+}
+
+// DeletePackage deletes the package of the image along with all of its versions
+func (o *Options) DeletePackage(image string) error {
+	ref, err := o.RepositoryRef(image)
+	if err != nil {
+		return err
+	}
+	if o.DryRun {
+		log.Logger().Infof("would delete Artifact Registry package %s", termcolor.ColorInfo(ref.URI()+"/"+ref.Package))
+		return nil
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	err = client.DeletePackage(o.GetContext(), &artifactregistrypb.DeletePackageRequest{Name: ref.PackageName()})
+	if err != nil {
+		return fmt.Errorf("failed to delete Artifact Registry package %s: %w", ref.PackageName(), err)
+	}
+	log.Logger().Infof("Deleted Artifact Registry package %s", termcolor.ColorInfo(ref.URI()+"/"+ref.Package))
+	return nil
+}
+
+// GetClient lazily creates the client using the application default credentials
+func (o *Options) GetClient() (Client, error) {
+	if o.Client == nil {
+		var err error
+		o.Client, err = NewClient(o.GetContext())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Artifact Registry client: %w", err)
+		}
+	}
+	return o.Client, nil
+}
+
+// GetContext returns the context defaulting to a new context
+func (o *Options) GetContext() context.Context {
+	if o.Context == nil {
+		o.Context = context.TODO()
+	}
+	return o.Context
+}
+
+func desiredCleanupPolicies(ref *RepositoryRef, cache bool, current map[string]*artifactregistrypb.CleanupPolicy) map[string]*artifactregistrypb.CleanupPolicy {
+	policy := DefaultCleanupPolicy()
+	answer := map[string]*artifactregistrypb.CleanupPolicy{
+		policy.Id: policy,
+	}
+	if cache {
+		policy = CacheCleanupPolicy(current[CleanupPolicyExpireCache], ref.Package)
+		answer[policy.Id] = policy
+	}
+	return answer
+}
+
+func cleanupPolicyIDs(policies map[string]*artifactregistrypb.CleanupPolicy) string {
+	var ids []string
+	for id := range policies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,149 @@
+package artifactregistry_test
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/artifactregistry/apiv1/artifactregistrypb"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry/fakegar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const repoName = "projects/myproject/locations/us/repositories/myrepo"
+
+func TestRepositoryRef(t *testing.T) {
+	testCases := []struct {
+		registry     string
+		organisation string
+		image        string
+		expected     artifactregistry.RepositoryRef
+	}{
+		{
+			registry: "us-docker.pkg.dev/myproject/myrepo",
+			image:    "myapp",
+			expected: artifactregistry.RepositoryRef{Host: "us-docker.pkg.dev", Location: "us", Project: "myproject", Repository: "myrepo", Package: "myapp"},
+		},
+		{
+			registry:     "europe-west1-docker.pkg.dev/myproject/myrepo",
+			organisation: "MyOrg",
+			image:        "myapp/cache:1.2.3",
+			expected:     artifactregistry.RepositoryRef{Host: "europe-west1-docker.pkg.dev", Location: "europe-west1", Project: "myproject", Repository: "myrepo", Package: "myorg/myapp/cache"},
+		},
+		{
+			registry:     "us-docker.pkg.dev/myproject",
+			organisation: "myrepo",
+			image:        "myapp",
+			expected:     artifactregistry.RepositoryRef{Host: "us-docker.pkg.dev", Location: "us", Project: "myproject", Repository: "myrepo", Package: "myapp"},
+		},
+	}
+	for _, tc := range testCases {
+		o := &artifactregistry.Options{Registry: tc.registry, RegistryOrganisation: tc.organisation}
+		ref, err := o.RepositoryRef(tc.image)
+		require.NoError(t, err, "for registry %s", tc.registry)
+		assert.Equal(t, tc.expected, *ref, "for registry %s", tc.registry)
+	}
+
+	for _, registry := range []string{"gcr.io/myproject", "us-docker.pkg.dev/myproject", "us-docker.pkg.dev"} {
+		o := &artifactregistry.Options{Registry: registry}
+		_, err := o.RepositoryRef("myapp")
+		assert.Error(t, err, "should fail for registry %s", registry)
+	}
+}
+
+func TestLazyCreateRepository(t *testing.T) {
+	fakeGAR := fakegar.NewFakeArtifactRegistry()
+	o := &artifactregistry.Options{
+		Registry: "us-docker.pkg.dev/myproject/myrepo",
+		Client:   fakeGAR,
+	}
+
+	err := o.LazyCreateRepository("myapp", false)
+	require.NoError(t, err, "failed to create repository")
+
+	repo := fakeGAR.Repositories[repoName]
+	require.NotNil(t, repo, "should have created repository %s", repoName)
+	assert.Equal(t, artifactregistrypb.Repository_DOCKER, repo.Format)
+	require.Len(t, repo.CleanupPolicies, 1)
+	assert.True(t, proto.Equal(artifactregistry.DefaultCleanupPolicy(), repo.CleanupPolicies[artifactregistry.CleanupPolicyExpirePullRequests]), "should have the default cleanup policy")
+
+	// a cache image adds its policy to the existing repository
+	err = o.LazyCreateRepository("myapp/cache", true)
+	require.NoError(t, err, "failed to ensure cache repository")
+
+	repo = fakeGAR.Repositories[repoName]
+	cachePolicy := artifactregistry.CacheCleanupPolicy(nil, "myapp/cache")
+	assert.Equal(t, artifactregistry.CleanupPolicyExpireCache, cachePolicy.Id)
+	assert.True(t, proto.Equal(cachePolicy, repo.CleanupPolicies[cachePolicy.Id]), "should have the cache cleanup policy")
+	assert.Len(t, repo.CleanupPolicies, 2)
+
+	// lets check the cache images of many apps share a single policy as a repository can only have 10 policies
+	var expected []string
+	for i := 0; i < 12; i++ {
+		image := fmt.Sprintf("app%02d/cache", i)
+		expected = append(expected, image)
+		err = o.LazyCreateRepository(image, true)
+		require.NoError(t, err, "failed to ensure cache repository %s", image)
+	}
+	expected = append(expected, "myapp/cache")
+
+	repo = fakeGAR.Repositories[repoName]
+	assert.Len(t, repo.CleanupPolicies, 2)
+	assert.Equal(t, expected, repo.CleanupPolicies[artifactregistry.CleanupPolicyExpireCache].GetCondition().PackageNamePrefixes)
+}
+
+func TestEnsureCleanupPoliciesKeepsOtherPolicies(t *testing.T) {
+	fakeGAR := fakegar.NewFakeArtifactRegistry()
+	other := &artifactregistrypb.CleanupPolicy{
+		Id:     "keep-releases",
+		Action: artifactregistrypb.CleanupPolicy_KEEP,
+	}
+	fakeGAR.Repositories[repoName] = &artifactregistrypb.Repository{
+		Name:   repoName,
+		Format: artifactregistrypb.Repository_DOCKER,
+		CleanupPolicies: map[string]*artifactregistrypb.CleanupPolicy{
+			other.Id: other,
+		},
+	}
+	o := &artifactregistry.Options{
+		Registry: "us-docker.pkg.dev/myproject/myrepo",
+		Client:   fakeGAR,
+	}
+
+	err := o.EnsureCleanupPolicies("myapp", false)
+	require.NoError(t, err, "failed to ensure cleanup policies")
+
+	repo := fakeGAR.Repositories[repoName]
+	assert.Len(t, repo.CleanupPolicies, 2)
+	assert.True(t, proto.Equal(other, repo.CleanupPolicies[other.Id]), "should keep the existing policy")
+}
+
+func TestLazyCreateRepositoryRejectsNonDockerRepository(t *testing.T) {
+	fakeGAR := fakegar.NewFakeArtifactRegistry()
+	fakeGAR.Repositories[repoName] = &artifactregistrypb.Repository{
+		Name:   repoName,
+		Format: artifactregistrypb.Repository_MAVEN,
+	}
+	o := &artifactregistry.Options{
+		Registry: "us-docker.pkg.dev/myproject/myrepo",
+		Client:   fakeGAR,
+	}
+
+	err := o.LazyCreateRepository("myapp", false)
+	require.Error(t, err, "should fail for a maven repository")
+}
+
+func TestLazyCreateRepositoryDryRun(t *testing.T) {
+	fakeGAR := fakegar.NewFakeArtifactRegistry()
+	o := &artifactregistry.Options{
+		Registry: "us-docker.pkg.dev/myproject/myrepo",
+		Client:   fakeGAR,
+		DryRun:   true,
+	}
+
+	err := o.LazyCreateRepository("myapp", true)
+	require.NoError(t, err, "failed to run dry run")
+	assert.Empty(t, fakeGAR.Repositories, "should not create repositories in dry run mode")
+}
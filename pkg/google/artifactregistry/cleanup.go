@@ -0,0 +1,66 @@
+package artifactregistry
+
+import (
+	"sort"
+	"time"
+
+	"cloud.google.com/go/artifactregistry/apiv1/artifactregistrypb"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// CleanupPolicyExpirePullRequests the ID of the cleanup policy deleting pull request images
+	CleanupPolicyExpirePullRequests = "jx-registry-expire-pull-requests"
+
+	// CleanupPolicyExpireCache the ID of the cleanup policy deleting untagged cache images. A single policy is shared
+	// by all the cache packages as a repository can only have 10 cleanup policies
+	CleanupPolicyExpireCache = "jx-registry-expire-cache"
+
+	day = 24 * time.Hour
+)
+
+// DefaultCleanupPolicy returns the cleanup policy equivalent to the default ECR lifecycle policy which deletes
+// pull request images after 14 days
+func DefaultCleanupPolicy() *artifactregistrypb.CleanupPolicy {
+	tagState := artifactregistrypb.CleanupPolicyCondition_TAGGED
+	return &artifactregistrypb.CleanupPolicy{
+		Id:     CleanupPolicyExpirePullRequests,
+		Action: artifactregistrypb.CleanupPolicy_DELETE,
+		ConditionType: &artifactregistrypb.CleanupPolicy_Condition{
+			Condition: &artifactregistrypb.CleanupPolicyCondition{
+				TagState:    &tagState,
+				TagPrefixes: []string{ecrs.DefaultExpireTagPrefix},
+				OlderThan:   durationpb.New(ecrs.DefaultExpireTagPrefixDays * day),
+			},
+		},
+	}
+}
+
+// CacheCleanupPolicy returns the cleanup policy equivalent to the default ECR cache lifecycle policy which deletes
+// untagged images of the cache package after 1 day. The package is added to the package name prefixes of the current
+// policy if there is one
+func CacheCleanupPolicy(current *artifactregistrypb.CleanupPolicy, packageName string) *artifactregistrypb.CleanupPolicy {
+	prefixes := []string{packageName}
+	if condition := current.GetCondition(); condition != nil {
+		for _, prefix := range condition.PackageNamePrefixes {
+			if stringhelpers.StringArrayIndex(prefixes, prefix) < 0 {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	sort.Strings(prefixes)
+	tagState := artifactregistrypb.CleanupPolicyCondition_UNTAGGED
+	return &artifactregistrypb.CleanupPolicy{
+		Id:     CleanupPolicyExpireCache,
+		Action: artifactregistrypb.CleanupPolicy_DELETE,
+		ConditionType: &artifactregistrypb.CleanupPolicy_Condition{
+			Condition: &artifactregistrypb.CleanupPolicyCondition{
+				TagState:            &tagState,
+				PackageNamePrefixes: prefixes,
+				OlderThan:           durationpb.New(ecrs.DefaultCacheExpireUntaggedAfterDays * day),
+			},
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package artifactregistry
+
+import (
+	"context"
+	"errors"
+
+	arapi "cloud.google.com/go/artifactregistry/apiv1"
+	"cloud.google.com/go/artifactregistry/apiv1/artifactregistrypb"
+	"google.golang.org/api/iterator"
+)
+
+// Client the subset of the Artifact Registry API we use. Long running operations are waited on and iterators are
+// read fully so that the client can be faked
+type Client interface {
+	GetRepository(ctx context.Context, req *artifactregistrypb.GetRepositoryRequest) (*artifactregistrypb.Repository, error)
+	CreateRepository(ctx context.Context, req *artifactregistrypb.CreateRepositoryRequest) (*artifactregistrypb.Repository, error)
+	UpdateRepository(ctx context.Context, req *artifactregistrypb.UpdateRepositoryRequest) (*artifactregistrypb.Repository, error)
+	ListPackages(ctx context.Context, req *artifactregistrypb.ListPackagesRequest) ([]*artifactregistrypb.Package, error)
+	DeletePackage(ctx context.Context, req *artifactregistrypb.DeletePackageRequest) error
+}
+
+// NewClient creates a client using the application default credentials
+func NewClient(ctx context.Context) (Client, error) {
+	c, err := arapi.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &client{client: c}, nil
+}
+
+type client struct {
+	client *arapi.Client
+}
+
+func (c *client) GetRepository(ctx context.Context, req *artifactregistrypb.GetRepositoryRequest) (*artifactregistrypb.Repository, error) {
+	return c.client.GetRepository(ctx, req)
+}
+
+func (c *client) CreateRepository(ctx context.Context, req *artifactregistrypb.CreateRepositoryRequest) (*artifactregistrypb.Repository, error) {
+	op, err := c.client.CreateRepository(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return op.Wait(ctx)
+}
+
+func (c *client) UpdateRepository(ctx context.Context, req *artifactregistrypb.UpdateRepositoryRequest) (*artifactregistrypb.Repository, error) {
+	return c.client.UpdateRepository(ctx, req)
+}
+
+func (c *client) ListPackages(ctx context.Context, req *artifactregistrypb.ListPackagesRequest) ([]*artifactregistrypb.Package, error) {
+	var answer []*artifactregistrypb.Package
+	it := c.client.ListPackages(ctx, req)
+	for {
+		p, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return answer, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, p)
+	}
+}
+
+func (c *client) DeletePackage(ctx context.Context, req *artifactregistrypb.DeletePackageRequest) error {
+	op, err := c.client.DeletePackage(ctx, req)
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
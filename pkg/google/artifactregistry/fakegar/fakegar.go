@@ -0,0 +1,96 @@
+package fakegar
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/artifactregistry/apiv1/artifactregistrypb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// FakeArtifactRegistry a fake Artifact Registry client storing repositories and packages in memory
+type FakeArtifactRegistry struct {
+	Repositories map[string]*artifactregistrypb.Repository
+	Packages     map[string]*artifactregistrypb.Package
+}
+
+// NewFakeArtifactRegistry creates a new fake client
+func NewFakeArtifactRegistry() *FakeArtifactRegistry {
+	return &FakeArtifactRegistry{
+		Repositories: map[string]*artifactregistrypb.Repository{},
+		Packages:     map[string]*artifactregistrypb.Package{},
+	}
+}
+
+func (f *FakeArtifactRegistry) GetRepository(_ context.Context, req *artifactregistrypb.GetRepositoryRequest) (*artifactregistrypb.Repository, error) {
+	repo := f.Repositories[req.Name]
+	if repo == nil {
+		return nil, status.Errorf(codes.NotFound, "repository %s not found", req.Name)
+	}
+	return proto.Clone(repo).(*artifactregistrypb.Repository), nil
+}
+
+func (f *FakeArtifactRegistry) CreateRepository(_ context.Context, req *artifactregistrypb.CreateRepositoryRequest) (*artifactregistrypb.Repository, error) {
+	name := req.Parent + "/repositories/" + req.RepositoryId
+	if f.Repositories[name] != nil {
+		return nil, status.Errorf(codes.AlreadyExists, "repository %s already exists", name)
+	}
+	repo := proto.Clone(req.Repository).(*artifactregistrypb.Repository)
+	repo.Name = name
+	f.Repositories[name] = repo
+	return proto.Clone(repo).(*artifactregistrypb.Repository), nil
+}
+
+func (f *FakeArtifactRegistry) UpdateRepository(_ context.Context, req *artifactregistrypb.UpdateRepositoryRequest) (*artifactregistrypb.Repository, error) {
+	repo := f.Repositories[req.Repository.Name]
+	if repo == nil {
+		return nil, status.Errorf(codes.NotFound, "repository %s not found", req.Repository.Name)
+	}
+	for _, path := range req.UpdateMask.GetPaths() {
+		switch path {
+		case "cleanup_policies":
+			repo.CleanupPolicies = req.Repository.CleanupPolicies
+		case "description":
+			repo.Description = req.Repository.Description
+		case "labels":
+			repo.Labels = req.Repository.Labels
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+	return proto.Clone(repo).(*artifactregistrypb.Repository), nil
+}
+
+func (f *FakeArtifactRegistry) ListPackages(_ context.Context, req *artifactregistrypb.ListPackagesRequest) ([]*artifactregistrypb.Package, error) {
+	if f.Repositories[req.Parent] == nil {
+		return nil, status.Errorf(codes.NotFound, "repository %s not found", req.Parent)
+	}
+	var names []string
+	for name := range f.Packages {
+		if strings.HasPrefix(name, req.Parent+"/packages/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var answer []*artifactregistrypb.Package
+	for _, name := range names {
+		answer = append(answer, proto.Clone(f.Packages[name]).(*artifactregistrypb.Package))
+	}
+	return answer, nil
+}
+
+func (f *FakeArtifactRegistry) DeletePackage(_ context.Context, req *artifactregistrypb.DeletePackageRequest) error {
+	if f.Packages[req.Name] == nil {
+		return status.Errorf(codes.NotFound, "package %s not found", req.Name)
+	}
+	delete(f.Packages, req.Name)
+	return nil
+}
+
+// AddPackage adds a package to the repository such as when an image is pushed
+func (f *FakeArtifactRegistry) AddPackage(name string) {
+	f.Packages[name] = &artifactregistrypb.Package{Name: name}
+}
@@ -0,0 +1,252 @@
+package harbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Project a Harbor project
+type Project struct {
+	ProjectID int64             `json:"project_id,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// ProjectReq the request to create or update a Harbor project
+type ProjectReq struct {
+	ProjectName  string            `json:"project_name,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	StorageLimit *int64            `json:"storage_limit,omitempty"`
+}
+
+// Quota the resource quota of a Harbor project
+type Quota struct {
+	ID   int64            `json:"id"`
+	Hard map[string]int64 `json:"hard"`
+}
+
+// Repository a Harbor repository whose name includes the project
+type Repository struct {
+	Name string `json:"name"`
+}
+
+// RetentionPolicy the tag retention policy of a Harbor project
+type RetentionPolicy struct {
+	ID        int64             `json:"id,omitempty"`
+	Algorithm string            `json:"algorithm"`
+	Rules     []RetentionRule   `json:"rules"`
+	Trigger   *RetentionTrigger `json:"trigger,omitempty"`
+	Scope     *RetentionScope   `json:"scope,omitempty"`
+}
+
+// RetentionRule a rule of the tag retention policy retaining the matching artifacts
+type RetentionRule struct {
+	ID             int64                          `json:"id,omitempty"`
+	Priority       int                            `json:"priority,omitempty"`
+	Disabled       bool                           `json:"disabled"`
+	Action         string                         `json:"action"`
+	Template       string                         `json:"template"`
+	Params         map[string]any                 `json:"params,omitempty"`
+	TagSelectors   []RetentionSelector            `json:"tag_selectors"`
+	ScopeSelectors map[string][]RetentionSelector `json:"scope_selectors"`
+}
+
+// RetentionSelector selects the tags or repositories of a retention rule
+type RetentionSelector struct {
+	Kind       string `json:"kind"`
+	Decoration string `json:"decoration"`
+	Pattern    string `json:"pattern"`
+	Extras     string `json:"extras,omitempty"`
+}
+
+// RetentionTrigger triggers the execution of the retention policy
+type RetentionTrigger struct {
+	Kind     string         `json:"kind"`
+	Settings map[string]any `json:"settings,omitempty"`
+}
+
+// RetentionScope the project the retention policy applies to
+type RetentionScope struct {
+	Level string `json:"level"`
+	Ref   int64  `json:"ref"`
+}
+
+// Error an error response from the Harbor API
+type Error struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s returned status %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if the error is a not found response from Harbor
+func IsNotFound(err error) bool {
+	var harborErr *Error
+	return errors.As(err, &harborErr) && harborErr.StatusCode == http.StatusNotFound
+}
+
+// Client a client of the Harbor v2 REST API using basic authentication
+type Client struct {
+	URL        string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// GetProject gets the project with the given name
+func (c *Client) GetProject(ctx context.Context, name string) (*Project, error) {
+	project := &Project{}
+	_, err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(name), nil, project, map[string]string{"X-Is-Resource-Name": "true"})
+	if err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// CreateProject creates a project
+func (c *Client) CreateProject(ctx context.Context, req *ProjectReq) error {
+	_, err := c.do(ctx, http.MethodPost, "/projects", req, nil, nil)
+	return err
+}
+
+// UpdateProject updates the metadata of the project with the given name
+func (c *Client) UpdateProject(ctx context.Context, name string, req *ProjectReq) error {
+	_, err := c.do(ctx, http.MethodPut, "/projects/"+url.PathEscape(name), req, nil, map[string]string{"X-Is-Resource-Name": "true"})
+	return err
+}
+
+// GetProjectQuota gets the quota of the project with the given ID
+func (c *Client) GetProjectQuota(ctx context.Context, projectID int64) (*Quota, error) {
+	var quotas []Quota
+	path := fmt.Sprintf("/quotas?reference=project&reference_id=%d", projectID)
+	_, err := c.do(ctx, http.MethodGet, path, nil, &quotas, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(quotas) == 0 {
+		return nil, &Error{StatusCode: http.StatusNotFound, Method: http.MethodGet, Path: path, Message: "no quota found"}
+	}
+	return &quotas[0], nil
+}
+
+// UpdateQuota updates the hard limits of the quota
+func (c *Client) UpdateQuota(ctx context.Context, quota *Quota) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/quotas/%d", quota.ID), map[string]any{"hard": quota.Hard}, nil, nil)
+	return err
+}
+
+// GetRetentionPolicy gets the retention policy with the given ID
+func (c *Client) GetRetentionPolicy(ctx context.Context, id int64) (*RetentionPolicy, error) {
+	policy := &RetentionPolicy{}
+	_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/retentions/%d", id), nil, policy, nil)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// CreateRetentionPolicy creates the retention policy returning its ID
+func (c *Client) CreateRetentionPolicy(ctx context.Context, policy *RetentionPolicy) (int64, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/retentions", policy, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	location := resp.Header.Get("Location")
+	id, err := strconv.ParseInt(location[strings.LastIndex(location, "/")+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the retention policy ID from the location '%s': %w", location, err)
+	}
+	return id, nil
+}
+
+// UpdateRetentionPolicy updates the retention policy
+func (c *Client) UpdateRetentionPolicy(ctx context.Context, policy *RetentionPolicy) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("/retentions/%d", policy.ID), policy, nil, nil)
+	return err
+}
+
+// ListRepositories lists all the repositories of the project
+func (c *Client) ListRepositories(ctx context.Context, projectName string) ([]Repository, error) {
+	var answer []Repository
+	for page := 1; ; page++ {
+		var repos []Repository
+		path := fmt.Sprintf("/projects/%s/repositories?page=%d&page_size=100", url.PathEscape(projectName), page)
+		_, err := c.do(ctx, http.MethodGet, path, nil, &repos, nil)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, repos...)
+		if len(repos) < 100 {
+			return answer, nil
+		}
+	}
+}
+
+// DeleteRepository deletes the repository of the project along with all of its artifacts
+func (c *Client) DeleteRepository(ctx context.Context, projectName, repositoryName string) error {
+	// the repository name is double escaped so that it can contain slashes
+	path := fmt.Sprintf("/projects/%s/repositories/%s", url.PathEscape(projectName), url.PathEscape(url.PathEscape(repositoryName)))
+	_, err := c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result any, headers map[string]string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+"/api/v2.0"+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request %s %s: %w", method, path, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &Error{StatusCode: resp.StatusCode, Method: method, Path: path, Message: strings.TrimSpace(string(data))}
+	}
+	if result != nil && len(data) > 0 {
+		err = json.Unmarshal(data, result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the response of %s %s: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,290 @@
+package fakeharbor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor"
+)
+
+const (
+	// Username the username accepted by the fake server
+	Username = "admin"
+
+	// Password the password accepted by the fake server
+	Password = "Harbor12345"
+)
+
+// FakeHarbor an httptest stand-in for the Harbor v2 REST API storing projects, quotas, retention policies and
+// repositories in memory
+type FakeHarbor struct {
+	Server       *httptest.Server
+	Projects     map[string]*harbor.Project
+	Quotas       map[int64]*harbor.Quota
+	Retentions   map[int64]*harbor.RetentionPolicy
+	Repositories map[string][]string
+	Requests     []string
+
+	lock   sync.Mutex
+	nextID int64
+}
+
+// NewFakeHarbor creates and starts a new fake Harbor server which should be closed after use
+func NewFakeHarbor() *FakeHarbor {
+	f := &FakeHarbor{
+		Projects:     map[string]*harbor.Project{},
+		Quotas:       map[int64]*harbor.Quota{},
+		Retentions:   map[int64]*harbor.RetentionPolicy{},
+		Repositories: map[string][]string{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v2.0/projects/{name}", f.getProject)
+	mux.HandleFunc("POST /api/v2.0/projects", f.createProject)
+	mux.HandleFunc("PUT /api/v2.0/projects/{name}", f.updateProject)
+	mux.HandleFunc("GET /api/v2.0/quotas", f.listQuotas)
+	mux.HandleFunc("PUT /api/v2.0/quotas/{id}", f.updateQuota)
+	mux.HandleFunc("POST /api/v2.0/retentions", f.createRetention)
+	mux.HandleFunc("GET /api/v2.0/retentions/{id}", f.getRetention)
+	mux.HandleFunc("PUT /api/v2.0/retentions/{id}", f.updateRetention)
+	mux.HandleFunc("GET /api/v2.0/projects/{name}/repositories", f.listRepositories)
+	mux.HandleFunc("DELETE /api/v2.0/projects/{name}/repositories/{repo}", f.deleteRepository)
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != Username || password != Password {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.Requests = append(f.Requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/api/v2.0"))
+		mux.ServeHTTP(w, r)
+	}))
+	return f
+}
+
+// URL returns the URL of the fake server
+func (f *FakeHarbor) URL() string {
+	return f.Server.URL
+}
+
+// Client returns a client of the fake server with valid credentials
+func (f *FakeHarbor) Client() *harbor.Client {
+	return &harbor.Client{
+		URL:        f.Server.URL,
+		Username:   Username,
+		Password:   Password,
+		HTTPClient: f.Server.Client(),
+	}
+}
+
+// Close shuts down the fake server
+func (f *FakeHarbor) Close() {
+	f.Server.Close()
+}
+
+// AddProject adds a project with a quota such as when a project is created via the Harbor UI
+func (f *FakeHarbor) AddProject(name string, metadata map[string]string) *harbor.Project {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.addProject(name, metadata, -1)
+}
+
+func (f *FakeHarbor) addProject(name string, metadata map[string]string, storageLimit int64) *harbor.Project {
+	f.nextID++
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	project := &harbor.Project{
+		ProjectID: f.nextID,
+		Name:      name,
+		Metadata:  metadata,
+	}
+	f.Projects[name] = project
+	f.Quotas[project.ProjectID] = &harbor.Quota{
+		ID:   project.ProjectID,
+		Hard: map[string]int64{"storage": storageLimit},
+	}
+	return project
+}
+
+func (f *FakeHarbor) getProject(w http.ResponseWriter, r *http.Request) {
+	project := f.Projects[r.PathValue("name")]
+	if project == nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+func (f *FakeHarbor) createProject(w http.ResponseWriter, r *http.Request) {
+	req := &harbor.ProjectReq{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	if f.Projects[req.ProjectName] != nil {
+		writeError(w, http.StatusConflict, "project already exists")
+		return
+	}
+	storageLimit := int64(-1)
+	if req.StorageLimit != nil {
+		storageLimit = *req.StorageLimit
+	}
+	project := f.addProject(req.ProjectName, req.Metadata, storageLimit)
+	w.Header().Set("Location", fmt.Sprintf("/api/v2.0/projects/%d", project.ProjectID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *FakeHarbor) updateProject(w http.ResponseWriter, r *http.Request) {
+	project := f.Projects[r.PathValue("name")]
+	if project == nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	req := &harbor.ProjectReq{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	for k, v := range req.Metadata {
+		project.Metadata[k] = v
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *FakeHarbor) listQuotas(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.URL.Query().Get("reference_id"), 10, 64)
+	var answer []*harbor.Quota
+	if quota := f.Quotas[id]; quota != nil && r.URL.Query().Get("reference") == "project" {
+		answer = append(answer, quota)
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+func (f *FakeHarbor) updateQuota(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	quota := f.Quotas[id]
+	if quota == nil {
+		writeError(w, http.StatusNotFound, "quota not found")
+		return
+	}
+	req := &harbor.Quota{}
+	if !readJSON(w, r, req) {
+		return
+	}
+	quota.Hard = req.Hard
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *FakeHarbor) createRetention(w http.ResponseWriter, r *http.Request) {
+	policy := &harbor.RetentionPolicy{}
+	if !readJSON(w, r, policy) {
+		return
+	}
+	var project *harbor.Project
+	for _, p := range f.Projects {
+		if policy.Scope != nil && p.ProjectID == policy.Scope.Ref {
+			project = p
+		}
+	}
+	if project == nil {
+		writeError(w, http.StatusBadRequest, "invalid retention scope")
+		return
+	}
+	f.nextID++
+	policy.ID = f.nextID
+	for i := range policy.Rules {
+		f.nextID++
+		policy.Rules[i].ID = f.nextID
+	}
+	f.Retentions[policy.ID] = policy
+	project.Metadata["retention_id"] = strconv.FormatInt(policy.ID, 10)
+	w.Header().Set("Location", fmt.Sprintf("/api/v2.0/retentions/%d", policy.ID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *FakeHarbor) getRetention(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	policy := f.Retentions[id]
+	if policy == nil {
+		writeError(w, http.StatusNotFound, "retention policy not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, policy)
+}
+
+func (f *FakeHarbor) updateRetention(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if f.Retentions[id] == nil {
+		writeError(w, http.StatusNotFound, "retention policy not found")
+		return
+	}
+	policy := &harbor.RetentionPolicy{}
+	if !readJSON(w, r, policy) {
+		return
+	}
+	policy.ID = id
+	f.Retentions[id] = policy
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *FakeHarbor) listRepositories(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if f.Projects[name] == nil {
+		writeError(w, http.StatusNotFound, "project not found")
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	names := append([]string{}, f.Repositories[name]...)
+	sort.Strings(names)
+	answer := []harbor.Repository{}
+	for i := (page - 1) * pageSize; i >= 0 && i < len(names) && i < page*pageSize; i++ {
+		answer = append(answer, harbor.Repository{Name: name + "/" + names[i]})
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+func (f *FakeHarbor) deleteRepository(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	repo, err := url.PathUnescape(r.PathValue("repo"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	repos := f.Repositories[name]
+	for i, n := range repos {
+		if n == repo {
+			f.Repositories[name] = append(repos[:i], repos[i+1:]...)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "repository not found")
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, value any) bool {
+	err := json.NewDecoder(r.Body).Decode(value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"errors": []map[string]string{{"code": http.StatusText(status), "message": message}},
+	})
+}
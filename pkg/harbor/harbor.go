@@ -0,0 +1,329 @@
+package harbor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultSecretName the default name of the Secret containing the Harbor credentials
+	DefaultSecretName = "jx-registry-harbor"
+
+	// SecretUsernameKey the key of the username in the Harbor credentials Secret
+	SecretUsernameKey = "username"
+
+	// SecretPasswordKey the key of the password in the Harbor credentials Secret
+	SecretPasswordKey = "password"
+
+	publicKey   = "public"
+	autoScanKey = "auto_scan"
+	storageKey  = "storage"
+)
+
+// Options the options for lazily creating Harbor projects
+type Options struct {
+	Context              context.Context
+	Registry             string
+	RegistryOrganisation string
+	URL                  string `env:"HARBOR_URL"`
+	SecretName           string `env:"HARBOR_SECRET,default=jx-registry-harbor"`
+	Public               string `env:"HARBOR_PUBLIC"`
+	AutoScan             string `env:"HARBOR_AUTO_SCAN"`
+	StorageLimit         string `env:"HARBOR_STORAGE_LIMIT"`
+	RetentionSchedule    string `env:"HARBOR_RETENTION_SCHEDULE,default=0 0 0 * * *"`
+	Namespace            string
+	KubeClient           kubernetes.Interface
+	DryRun               bool
+	Client               *Client `env:",noinit"`
+}
+
+// AddFlags adds the Harbor flags to the command
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.URL, "harbor-url", "", o.URL, "The URL of the Harbor API. Defaults to $HARBOR_URL or https:// and the registry host")
+	cmd.Flags().StringVarP(&o.SecretName, "harbor-secret", "", o.SecretName, "The name of the Secret with the username and password keys used to authenticate with Harbor")
+	cmd.Flags().StringVarP(&o.Public, "harbor-public", "", o.Public, "Whether the Harbor project is public: true or false. New projects default to false and existing projects are left unchanged if not specified")
+	cmd.Flags().StringVarP(&o.AutoScan, "harbor-auto-scan", "", o.AutoScan, "Whether the Harbor project scans images on push: true or false. New projects default to true and existing projects are left unchanged if not specified")
+	cmd.Flags().StringVarP(&o.StorageLimit, "harbor-storage-limit", "", o.StorageLimit, "The storage quota of the Harbor project such as 10Gi or -1 for unlimited. The quota is left unchanged if not specified")
+	cmd.Flags().StringVarP(&o.RetentionSchedule, "harbor-retention-schedule", "", o.RetentionSchedule, "The cron schedule of the Harbor tag retention policy")
+}
+
+// EnvProcess processes the environment variable defaults
+func (o *Options) EnvProcess() {
+	err := envconfig.Process(o.GetContext(), o)
+	if err != nil {
+		log.Logger().Warnf("failed to default env vars: %s", err.Error())
+	}
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	_, err := o.projectMetadata(false)
+	if err != nil {
+		return err
+	}
+	_, err = o.storageLimitBytes()
+	return err
+}
+
+// ProjectName returns the name of the project which is the registry organisation
+func (o *Options) ProjectName() (string, error) {
+	if o.RegistryOrganisation == "" {
+		return "", fmt.Errorf("no registry organisation specified to use as the Harbor project of registry %s", o.Registry)
+	}
+	return strings.ToLower(o.RegistryOrganisation), nil
+}
+
+// GetURL returns the URL of the Harbor API defaulting to the registry host
+func (o *Options) GetURL() string {
+	if o.URL != "" {
+		return o.URL
+	}
+	return "https://" + RegistryHost(o.Registry)
+}
+
+// EnsureProject lazily creates the project along with its settings, quota and tag retention policy
+func (o *Options) EnsureProject() error {
+	name, err := o.ProjectName()
+	if err != nil {
+		return err
+	}
+	storageLimit, err := o.storageLimitBytes()
+	if err != nil {
+		return err
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	ctx := o.GetContext()
+
+	project, err := client.GetProject(ctx, name)
+	if err != nil {
+		if !IsNotFound(err) {
+			return fmt.Errorf("failed to get Harbor project %s: %w", name, err)
+		}
+		if o.DryRun {
+			log.Logger().Infof("would create Harbor project %s", termcolor.ColorInfo(name))
+			return nil
+		}
+		metadata, err := o.projectMetadata(true)
+		if err != nil {
+			return err
+		}
+		err = client.CreateProject(ctx, &ProjectReq{
+			ProjectName:  name,
+			Metadata:     metadata,
+			StorageLimit: storageLimit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create Harbor project %s: %w", name, err)
+		}
+		log.Logger().Infof("Created Harbor project %s", termcolor.ColorInfo(name))
+		project, err = client.GetProject(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to get the created Harbor project %s: %w", name, err)
+		}
+	} else {
+		metadata, err := o.projectMetadata(false)
+		if err != nil {
+			return err
+		}
+		err = o.ensureProjectSettings(client, project, metadata, storageLimit)
+		if err != nil {
+			return err
+		}
+	}
+	return o.ensureRetentionPolicy(client, project)
+}
+
+// ListRepositories lists the repositories of the project
+func (o *Options) ListRepositories() ([]string, error) {
+	name, err := o.ProjectName()
+	if err != nil {
+		return nil, err
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return nil, err
+	}
+	repos, err := client.ListRepositories(o.GetContext(), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the repositories of Harbor project %s: %w", name, err)
+	}
+	var answer []string
+	for _, r := range repos {
+		answer = append(answer, r.Name)
+	}
+	sort.Strings(answer)
+	return answer, nil
+}
+
+// DeleteRepository deletes the repository of the image along with all of its artifacts
+func (o *Options) DeleteRepository(image string) error {
+	name, err := o.ProjectName()
+	if err != nil {
+		return err
+	}
+	repoName, _, _ := strings.Cut(strings.ToLower(image), ":")
+	if o.DryRun {
+		log.Logger().Infof("would delete Harbor repository %s", termcolor.ColorInfo(name+"/"+repoName))
+		return nil
+	}
+	client, err := o.GetClient()
+	if err != nil {
+		return err
+	}
+	err = client.DeleteRepository(o.GetContext(), name, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to delete Harbor repository %s/%s: %w", name, repoName, err)
+	}
+	log.Logger().Infof("Deleted Harbor repository %s", termcolor.ColorInfo(name+"/"+repoName))
+	return nil
+}
+
+// GetClient lazily creates the client using the credentials in the Secret
+func (o *Options) GetClient() (*Client, error) {
+	if o.Client != nil {
+		return o.Client, nil
+	}
+	var err error
+	o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the kubernetes client: %w", err)
+	}
+	secret, err := o.KubeClient.CoreV1().Secrets(o.Namespace).Get(o.GetContext(), o.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Harbor credentials Secret %s in namespace %s: %w", o.SecretName, o.Namespace, err)
+	}
+	username := string(secret.Data[SecretUsernameKey])
+	password := string(secret.Data[SecretPasswordKey])
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("the Harbor credentials Secret %s in namespace %s should have the %s and %s keys", o.SecretName, o.Namespace, SecretUsernameKey, SecretPasswordKey)
+	}
+	o.Client = &Client{
+		URL:      o.GetURL(),
+		Username: username,
+		Password: password,
+	}
+	return o.Client, nil
+}
+
+// GetContext returns the context defaulting to a new context
+func (o *Options) GetContext() context.Context {
+	if o.Context == nil {
+		o.Context = context.TODO()
+	}
+	return o.Context
+}
+
+// RegistryHost returns the host of the registry removing any scheme and path
+func RegistryHost(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(registry), "https://"), "http://")
+	host, _, _ := strings.Cut(registry, "/")
+	return host
+}
+
+// projectMetadata returns the metadata of the public and auto scan settings. For a new project any settings which are
+// not specified use their defaults otherwise only the specified settings are returned so the others are left unchanged
+func (o *Options) projectMetadata(create bool) (map[string]string, error) {
+	answer := map[string]string{}
+	settings := []struct {
+		key, flag, value, defaultValue string
+	}{
+		{publicKey, "harbor-public", o.Public, "false"},
+		{autoScanKey, "harbor-auto-scan", o.AutoScan, "true"},
+	}
+	for _, s := range settings {
+		if s.value == "" {
+			if create {
+				answer[s.key] = s.defaultValue
+			}
+			continue
+		}
+		b, err := strconv.ParseBool(s.value)
+		if err != nil {
+			return nil, options.InvalidOption(s.flag, s.value, []string{"true", "false"})
+		}
+		answer[s.key] = strconv.FormatBool(b)
+	}
+	return answer, nil
+}
+
+// ensureProjectSettings updates the metadata and storage quota of the existing project if they differ
+func (o *Options) ensureProjectSettings(client *Client, project *Project, metadata map[string]string, storageLimit *int64) error {
+	ctx := o.GetContext()
+	var changes []string
+	for k, v := range metadata {
+		if project.Metadata[k] != v {
+			changes = append(changes, k+" to "+v)
+		}
+	}
+	sort.Strings(changes)
+	description := strings.Join(changes, " and ")
+	if len(changes) == 0 {
+		log.Logger().Debugf("settings of Harbor project %s are up to date", project.Name)
+	} else if o.DryRun {
+		log.Logger().Infof("would set %s on Harbor project %s", description, termcolor.ColorInfo(project.Name))
+	} else {
+		err := client.UpdateProject(ctx, project.Name, &ProjectReq{Metadata: metadata})
+		if err != nil {
+			return fmt.Errorf("failed to update the metadata of Harbor project %s: %w", project.Name, err)
+		}
+		log.Logger().Infof("Set %s on Harbor project %s", description, termcolor.ColorInfo(project.Name))
+	}
+
+	if storageLimit == nil {
+		return nil
+	}
+	quota, err := client.GetProjectQuota(ctx, project.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to get the quota of Harbor project %s: %w", project.Name, err)
+	}
+	if current, ok := quota.Hard[storageKey]; ok && current == *storageLimit {
+		log.Logger().Debugf("quota of Harbor project %s is up to date", project.Name)
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would set the storage quota of Harbor project %s to %s", termcolor.ColorInfo(project.Name), o.StorageLimit)
+		return nil
+	}
+	err = client.UpdateQuota(ctx, &Quota{
+		ID:   quota.ID,
+		Hard: map[string]int64{storageKey: *storageLimit},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the quota of Harbor project %s: %w", project.Name, err)
+	}
+	log.Logger().Infof("Set the storage quota of Harbor project %s to %s", termcolor.ColorInfo(project.Name), o.StorageLimit)
+	return nil
+}
+
+// storageLimitBytes parses the storage limit returning nil if it is not specified
+func (o *Options) storageLimitBytes() (*int64, error) {
+	if o.StorageLimit == "" {
+		return nil, nil
+	}
+	if o.StorageLimit == "-1" {
+		unlimited := int64(-1)
+		return &unlimited, nil
+	}
+	q, err := resource.ParseQuantity(o.StorageLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Harbor storage limit '%s' should be a quantity such as 10Gi or -1 for unlimited: %w", o.StorageLimit, err)
+	}
+	answer := q.Value()
+	return &answer, nil
+}
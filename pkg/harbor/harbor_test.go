@@ -0,0 +1,173 @@
+package harbor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor/fakeharbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newOptions(fakeHarbor *fakeharbor.FakeHarbor) *harbor.Options {
+	return &harbor.Options{
+		Registry:             "harbor.example.com",
+		RegistryOrganisation: "MyOrg",
+		RetentionSchedule:    harbor.DefaultRetentionSchedule,
+		Client:               fakeHarbor.Client(),
+	}
+}
+
+func TestEnsureProjectCreatesProject(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+	o := newOptions(fakeHarbor)
+	o.StorageLimit = "10Gi"
+
+	err := o.EnsureProject()
+	require.NoError(t, err, "failed to ensure project")
+
+	project := fakeHarbor.Projects["myorg"]
+	require.NotNil(t, project, "should have created the project")
+	assert.Equal(t, "false", project.Metadata["public"])
+	assert.Equal(t, "true", project.Metadata["auto_scan"])
+	assert.Equal(t, int64(10*1024*1024*1024), fakeHarbor.Quotas[project.ProjectID].Hard["storage"])
+
+	require.Len(t, fakeHarbor.Retentions, 1, "should have created the retention policy")
+	for _, policy := range fakeHarbor.Retentions {
+		assert.Equal(t, project.ProjectID, policy.Scope.Ref)
+		assert.Equal(t, harbor.DefaultRetentionSchedule, policy.Trigger.Settings["cron"])
+		require.Len(t, policy.Rules, 2)
+		assert.Equal(t, "always", policy.Rules[0].Template)
+		assert.Equal(t, "0.0.0-*", policy.Rules[0].TagSelectors[0].Pattern)
+		assert.Equal(t, "excludes", policy.Rules[0].TagSelectors[0].Decoration)
+		assert.Equal(t, "nDaysSinceLastPush", policy.Rules[1].Template)
+		assert.EqualValues(t, 14, policy.Rules[1].Params["nDaysSinceLastPush"])
+	}
+
+	// a second run should not change anything
+	fakeHarbor.Requests = nil
+	err = o.EnsureProject()
+	require.NoError(t, err, "failed to ensure project again")
+	for _, r := range fakeHarbor.Requests {
+		assert.NotRegexp(t, "^(POST|PUT)", r, "should not modify anything on the second run")
+	}
+}
+
+func TestEnsureProjectUpdatesExistingProject(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+	project := fakeHarbor.AddProject("myorg", map[string]string{"public": "true"})
+	fakeHarbor.Retentions[100] = &harbor.RetentionPolicy{
+		ID:        100,
+		Algorithm: "or",
+		Rules: []harbor.RetentionRule{
+			{
+				ID:       101,
+				Action:   "retain",
+				Template: "latestPushedK",
+				Params:   map[string]any{"latestPushedK": 5},
+				TagSelectors: []harbor.RetentionSelector{
+					{Kind: "doublestar", Decoration: "matches", Pattern: "**"},
+				},
+			},
+		},
+	}
+	project.Metadata["retention_id"] = "100"
+	o := newOptions(fakeHarbor)
+	o.AutoScan = "true"
+	o.StorageLimit = "-1"
+
+	err := o.EnsureProject()
+	require.NoError(t, err, "failed to ensure project")
+
+	assert.Equal(t, "true", project.Metadata["public"], "should not change settings which are not specified")
+	assert.Equal(t, "true", project.Metadata["auto_scan"], "should have enabled auto scan")
+	assert.Equal(t, int64(-1), fakeHarbor.Quotas[project.ProjectID].Hard["storage"])
+
+	policy := fakeHarbor.Retentions[100]
+	require.Len(t, policy.Rules, 1, "should not add the default rules to an existing policy")
+	assert.Equal(t, "latestPushedK", policy.Rules[0].Template, "should keep the existing rule")
+	assert.Nil(t, policy.Trigger, "should leave the existing policy alone")
+
+	o.Public = "false"
+	err = o.EnsureProject()
+	require.NoError(t, err, "failed to ensure project")
+	assert.Equal(t, "false", project.Metadata["public"], "should have made the project private")
+}
+
+func TestValidateProjectSettings(t *testing.T) {
+	o := &harbor.Options{Public: "maybe"}
+	assert.Error(t, o.Validate(), "should fail for an invalid public setting")
+
+	o = &harbor.Options{AutoScan: "FALSE"}
+	assert.NoError(t, o.Validate(), "should accept a boolean auto scan setting")
+}
+
+func TestEnsureProjectDryRun(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+	o := newOptions(fakeHarbor)
+	o.DryRun = true
+
+	err := o.EnsureProject()
+	require.NoError(t, err, "failed to run dry run")
+	assert.Empty(t, fakeHarbor.Projects, "should not create projects in dry run mode")
+}
+
+func TestEnsureProjectRequiresOrganisation(t *testing.T) {
+	o := &harbor.Options{Registry: "harbor.example.com"}
+	err := o.EnsureProject()
+	require.Error(t, err, "should fail without a registry organisation")
+}
+
+func TestValidateStorageLimit(t *testing.T) {
+	o := &harbor.Options{StorageLimit: "lots"}
+	require.Error(t, o.Validate(), "should fail for an invalid storage limit")
+}
+
+func TestGetClientFromSecret(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+	fakeHarbor.AddProject("myorg", nil)
+	fakeHarbor.Repositories["myorg"] = []string{"myapp", "myapp/cache"}
+
+	o := &harbor.Options{
+		Registry:             "harbor.example.com",
+		RegistryOrganisation: "myorg",
+		URL:                  fakeHarbor.URL(),
+		SecretName:           harbor.DefaultSecretName,
+		Namespace:            "jx",
+		KubeClient: fake.NewSimpleClientset(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: harbor.DefaultSecretName, Namespace: "jx"},
+			Data: map[string][]byte{
+				harbor.SecretUsernameKey: []byte(fakeharbor.Username),
+				harbor.SecretPasswordKey: []byte(fakeharbor.Password),
+			},
+		}),
+		Context: context.TODO(),
+	}
+
+	names, err := o.ListRepositories()
+	require.NoError(t, err, "failed to list repositories")
+	assert.Equal(t, []string{"myorg/myapp", "myorg/myapp/cache"}, names)
+
+	err = o.DeleteRepository("myapp/cache")
+	require.NoError(t, err, "failed to delete repository")
+	assert.Equal(t, []string{"myapp"}, fakeHarbor.Repositories["myorg"])
+}
+
+func TestGetClientWithInvalidCredentials(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+
+	o := newOptions(fakeHarbor)
+	o.Client.Password = "wrong"
+	err := o.EnsureProject()
+	require.Error(t, err, "should fail with invalid credentials")
+	assert.Contains(t, err.Error(), "401")
+}
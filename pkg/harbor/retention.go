@@ -0,0 +1,177 @@
+package harbor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// DefaultRetentionSchedule the default cron schedule of the tag retention policy
+	DefaultRetentionSchedule = "0 0 0 * * *"
+
+	retentionIDKey = "retention_id"
+)
+
+// DefaultRetentionRules returns the retention rules equivalent to the default ECR lifecycle policy. Harbor deletes the
+// artifacts which are not retained by any rule so all artifacts are retained apart from pull request images which
+// are retained for 14 days after they are pushed
+func DefaultRetentionRules() []RetentionRule {
+	repositories := map[string][]RetentionSelector{
+		"repository": {
+			{
+				Kind:       "doublestar",
+				Decoration: "repoMatches",
+				Pattern:    "**",
+			},
+		},
+	}
+	tagPattern := ecrs.DefaultExpireTagPrefix + "*"
+	return []RetentionRule{
+		{
+			Action:   "retain",
+			Template: "always",
+			TagSelectors: []RetentionSelector{
+				{
+					Kind:       "doublestar",
+					Decoration: "excludes",
+					Pattern:    tagPattern,
+					Extras:     `{"untagged":true}`,
+				},
+			},
+			ScopeSelectors: repositories,
+		},
+		{
+			Action:   "retain",
+			Template: "nDaysSinceLastPush",
+			Params: map[string]any{
+				"nDaysSinceLastPush": ecrs.DefaultExpireTagPrefixDays,
+			},
+			TagSelectors: []RetentionSelector{
+				{
+					Kind:       "doublestar",
+					Decoration: "matches",
+					Pattern:    tagPattern,
+				},
+			},
+			ScopeSelectors: repositories,
+		},
+	}
+}
+
+// ensureRetentionPolicy creates the retention policy of the project with the default rules if it has none. Harbor
+// retains any artifact matched by a rule so adding the default rules to an existing policy would stop its other rules
+// from expiring anything. So an existing policy is left alone unless it only contains the default rules, in which
+// case its schedule is kept up to date
+func (o *Options) ensureRetentionPolicy(client *Client, project *Project) error {
+	ctx := o.GetContext()
+	trigger := &RetentionTrigger{
+		Kind: "Schedule",
+		Settings: map[string]any{
+			"cron": o.RetentionSchedule,
+		},
+	}
+
+	idText := project.Metadata[retentionIDKey]
+	if idText == "" {
+		policy := &RetentionPolicy{
+			Algorithm: "or",
+			Rules:     DefaultRetentionRules(),
+			Trigger:   trigger,
+			Scope: &RetentionScope{
+				Level: "project",
+				Ref:   project.ProjectID,
+			},
+		}
+		if o.DryRun {
+			log.Logger().Infof("would create the tag retention policy of Harbor project %s", termcolor.ColorInfo(project.Name))
+			return nil
+		}
+		_, err := client.CreateRetentionPolicy(ctx, policy)
+		if err != nil {
+			return fmt.Errorf("failed to create the tag retention policy of Harbor project %s: %w", project.Name, err)
+		}
+		log.Logger().Infof("Created the tag retention policy of Harbor project %s", termcolor.ColorInfo(project.Name))
+		return nil
+	}
+
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse the retention ID '%s' of Harbor project %s: %w", idText, project.Name, err)
+	}
+	policy, err := client.GetRetentionPolicy(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get the tag retention policy %d of Harbor project %s: %w", id, project.Name, err)
+	}
+	if !isDefaultRetentionPolicy(policy) {
+		log.Logger().Warnf("not changing the existing tag retention policy of Harbor project %s as it has other rules so the pull request images may not expire", termcolor.ColorInfo(project.Name))
+		return nil
+	}
+	if policy.Trigger != nil && policy.Trigger.Kind == trigger.Kind && fmt.Sprint(policy.Trigger.Settings["cron"]) == o.RetentionSchedule {
+		log.Logger().Debugf("tag retention policy of Harbor project %s is up to date", project.Name)
+		return nil
+	}
+	if o.DryRun {
+		log.Logger().Infof("would update the schedule of the tag retention policy of Harbor project %s", termcolor.ColorInfo(project.Name))
+		return nil
+	}
+	policy.ID = id
+	policy.Trigger = trigger
+	err = client.UpdateRetentionPolicy(ctx, policy)
+	if err != nil {
+		return fmt.Errorf("failed to update the tag retention policy of Harbor project %s: %w", project.Name, err)
+	}
+	log.Logger().Infof("Updated the schedule of the tag retention policy of Harbor project %s", termcolor.ColorInfo(project.Name))
+	return nil
+}
+
+// isDefaultRetentionPolicy returns true if the policy combines its rules with or and only has the enabled default rules
+func isDefaultRetentionPolicy(policy *RetentionPolicy) bool {
+	desired := DefaultRetentionRules()
+	if policy.Algorithm != "or" || len(policy.Rules) != len(desired) {
+		return false
+	}
+	for i := range desired {
+		d := &desired[i]
+		found := false
+		for j := range policy.Rules {
+			r := &policy.Rules[j]
+			if !r.Disabled && r.Action == d.Action && retentionRuleKey(r) == retentionRuleKey(d) && paramsJSON(r.Params) == paramsJSON(d.Params) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func retentionRuleKey(r *RetentionRule) string {
+	var parts []string
+	parts = append(parts, r.Template)
+	for _, s := range r.TagSelectors {
+		parts = append(parts, s.Kind, s.Decoration, s.Pattern, s.Extras)
+	}
+	for _, s := range r.ScopeSelectors["repository"] {
+		parts = append(parts, s.Kind, s.Decoration, s.Pattern)
+	}
+	return strings.Join(parts, "|")
+}
+
+func paramsJSON(params map[string]any) string {
+	if len(params) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprint(params)
+	}
+	return string(data)
+}
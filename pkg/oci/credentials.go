@@ -0,0 +1,92 @@
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// DockerConfigKeys the keys of the docker config JSON in the credentials Secret in order of preference
+	DockerConfigKeys = []string{".dockerconfigjson", "config.json"}
+)
+
+// DockerConfig the subset of the docker config JSON containing the registry credentials
+type DockerConfig struct {
+	Auths map[string]DockerAuth `json:"auths"`
+}
+
+// DockerAuth the credentials of a registry in the docker config JSON
+type DockerAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Credentials returns the username and password for the registry host from the options or the docker config JSON
+// in the credentials Secret. Empty credentials are returned if there are none so that anonymous access is used
+func (o *Options) Credentials(host string) (string, string, error) {
+	if o.Username != "" {
+		return o.Username, o.Password, nil
+	}
+	if o.SecretName == "" {
+		return "", "", nil
+	}
+	var err error
+	o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
+	if err != nil {
+		log.Logger().Debugf("using anonymous access to container registry %s as failed to create the kubernetes client: %s", host, err.Error())
+		return "", "", nil
+	}
+	secret, err := o.KubeClient.CoreV1().Secrets(o.Namespace).Get(o.GetContext(), o.SecretName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Logger().Debugf("using anonymous access to container registry %s as there is no Secret %s in namespace %s", host, o.SecretName, o.Namespace)
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to get the registry credentials Secret %s in namespace %s: %w", o.SecretName, o.Namespace, err)
+	}
+	for _, key := range DockerConfigKeys {
+		data := secret.Data[key]
+		if len(data) == 0 {
+			continue
+		}
+		config := &DockerConfig{}
+		err = json.Unmarshal(data, config)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse the %s key of the registry credentials Secret %s in namespace %s: %w", key, o.SecretName, o.Namespace, err)
+		}
+		for registry, auth := range config.Auths {
+			if RegistryHost(registry) != host {
+				continue
+			}
+			return auth.credentials()
+		}
+	}
+	log.Logger().Debugf("using anonymous access to container registry %s as the Secret %s in namespace %s has no credentials for it", host, o.SecretName, o.Namespace)
+	return "", "", nil
+}
+
+func (a *DockerAuth) credentials() (string, string, error) {
+	if a.Username != "" {
+		return a.Username, a.Password, nil
+	}
+	if a.Auth == "" {
+		return "", "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(a.Auth)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode the docker config auth: %w", err)
+	}
+	username, password, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return "", "", fmt.Errorf("the docker config auth should be of the form username:password")
+	}
+	return username, password, nil
+}
@@ -0,0 +1,146 @@
+package fakeoci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+const (
+	// Username the username accepted by the fake registry
+	Username = "pusher"
+
+	// Password the password accepted by the fake registry
+	Password = "secret"
+
+	token = "fake-token"
+)
+
+// FakeRegistry an httptest stand-in for an OCI distribution registry supporting anonymous, basic or bearer token
+// authentication
+type FakeRegistry struct {
+	Server *httptest.Server
+
+	// Auth the authentication scheme required which is either empty for anonymous access, Basic or Bearer
+	Auth string
+
+	// AnonymousReads allows GET requests without credentials so that only pushes require authentication
+	AnonymousReads bool
+
+	// PushRepositories the repositories the credentials may push to. Any repository is allowed if empty
+	PushRepositories []string
+
+	// Uploads the repositories of the uploads which have been started and not cancelled
+	Uploads map[string]string
+
+	// Requests the method and path of the requests received
+	Requests []string
+
+	lock   sync.Mutex
+	nextID int
+}
+
+// NewFakeRegistry creates and starts a new fake registry which should be closed after use
+func NewFakeRegistry(auth string) *FakeRegistry {
+	f := &FakeRegistry{
+		Auth:    auth,
+		Uploads: map[string]string{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Host returns the host of the fake registry
+func (f *FakeRegistry) Host() string {
+	return strings.TrimPrefix(f.Server.URL, "http://")
+}
+
+// Close shuts down the fake registry
+func (f *FakeRegistry) Close() {
+	f.Server.Close()
+}
+
+func (f *FakeRegistry) handle(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.Requests = append(f.Requests, r.Method+" "+r.URL.Path)
+
+	if r.URL.Path == "/token" {
+		f.handleToken(w, r)
+		return
+	}
+	if !f.authorized(r) && !(f.AnonymousReads && r.Method == http.MethodGet) {
+		switch f.Auth {
+		case "Basic":
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+		case "Bearer":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+f.Server.URL+`/token",service="fake"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/blobs/uploads/"):
+		repo := strings.TrimSuffix(strings.TrimPrefix(path, "/v2/"), "/blobs/uploads/")
+		if !f.canPush(repo) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		f.nextID++
+		id := strings.Repeat("a", f.nextID)
+		f.Uploads[id] = repo
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodDelete && strings.Contains(path, "/blobs/uploads/"):
+		id := path[strings.LastIndex(path, "/")+1:]
+		if _, ok := f.Uploads[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.Uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *FakeRegistry) handleToken(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username != Username || password != Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+func (f *FakeRegistry) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	switch f.Auth {
+	case "Basic":
+		return header == "Basic "+base64.StdEncoding.EncodeToString([]byte(Username+":"+Password))
+	case "Bearer":
+		return header == "Bearer "+token
+	default:
+		return true
+	}
+}
+
+func (f *FakeRegistry) canPush(repo string) bool {
+	if len(f.PushRepositories) == 0 {
+		return true
+	}
+	for _, r := range f.PushRepositories {
+		if r == repo {
+			return true
+		}
+	}
+	return false
+}
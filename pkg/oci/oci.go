@@ -0,0 +1,288 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultSecretName the default name of the Secret containing the docker config JSON of the registry credentials
+	DefaultSecretName = "tekton-container-registry-auth"
+)
+
+// Options the options for checking an OCI distribution registry is ready for pushes
+type Options struct {
+	Context              context.Context
+	Registry             string
+	RegistryOrganisation string
+	Enabled              bool   `env:"OCI_PREFLIGHT,default=true"`
+	Username             string `env:"OCI_USERNAME"`
+	Password             string `env:"OCI_PASSWORD"`
+	SecretName           string `env:"OCI_SECRET,default=tekton-container-registry-auth"`
+	Insecure             bool   `env:"OCI_INSECURE"`
+	Namespace            string
+	KubeClient           kubernetes.Interface
+	HTTPClient           *http.Client `env:",noinit"`
+	DryRun               bool
+}
+
+// AddFlags adds the OCI flags to the command
+func (o *Options) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&o.Enabled, "oci-preflight", "", o.Enabled, "Whether to check that registries not managed by another backend are reachable and allow pushes via the OCI distribution API")
+	cmd.Flags().StringVarP(&o.SecretName, "oci-secret", "", o.SecretName, "The name of the Secret with the docker config JSON containing the registry credentials. Defaults to $OCI_SECRET. $OCI_USERNAME and $OCI_PASSWORD take precedence")
+	cmd.Flags().BoolVarP(&o.Insecure, "oci-insecure", "", o.Insecure, "Use plain HTTP to access the registry such as for a local registry")
+}
+
+// EnvProcess processes the environment variable defaults
+func (o *Options) EnvProcess() {
+	err := envconfig.Process(o.GetContext(), o)
+	if err != nil {
+		log.Logger().Warnf("failed to default env vars: %s", err.Error())
+	}
+}
+
+// RepositoryName returns the repository name for the app name and registry organisation
+func (o *Options) RepositoryName(appName string) string {
+	appName, _, _ = strings.Cut(appName, ":")
+	repoName := appName
+	if o.RegistryOrganisation != "" {
+		repoName = o.RegistryOrganisation + "/" + appName
+	}
+	return strings.ToLower(repoName)
+}
+
+// Preflight checks that the registry is reachable and that the credentials allow a push to the repository of the
+// image by initiating a blob upload which is then cancelled
+func (o *Options) Preflight(image string) error {
+	host := RegistryHost(o.Registry)
+	if host == "" {
+		return fmt.Errorf("no container registry specified")
+	}
+	repoName := o.RepositoryName(image)
+	username, password, err := o.Credentials(host)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.send(http.MethodGet, o.baseURL()+"/v2/", "")
+	if err != nil {
+		return fmt.Errorf("container registry %s is not reachable: %w", host, err)
+	}
+	authorization := ""
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		authorization, err = o.authorization(resp.Header.Get("WWW-Authenticate"), repoName, username, password)
+		if err != nil {
+			return fmt.Errorf("failed to authenticate with container registry %s: %w", host, err)
+		}
+	default:
+		return fmt.Errorf("container registry %s does not support the OCI distribution API as GET /v2/ returned status %d", host, resp.StatusCode)
+	}
+
+	if o.DryRun {
+		log.Logger().Infof("container registry %s is reachable, would check that pushes to %s are allowed", termcolor.ColorInfo(host), termcolor.ColorInfo(repoName))
+		return nil
+	}
+	uploadURL := o.baseURL() + "/v2/" + repoName + "/blobs/uploads/"
+	resp, err = o.send(http.MethodPost, uploadURL, authorization)
+	if err != nil {
+		return fmt.Errorf("container registry %s is not reachable: %w", host, err)
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode == http.StatusUnauthorized && authorization == "" && challenge != "" {
+		// lets authenticate and retry as the registry allows anonymous access to /v2/ but not pushes
+		authorization, err = o.authorization(challenge, repoName, username, password)
+		if err != nil {
+			return fmt.Errorf("failed to authenticate with container registry %s: %w", host, err)
+		}
+		resp, err = o.send(http.MethodPost, uploadURL, authorization)
+		if err != nil {
+			return fmt.Errorf("container registry %s is not reachable: %w", host, err)
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("the credentials for container registry %s do not allow pushing to %s as starting an upload returned status %d%s", host, repoName, resp.StatusCode, o.credentialsHint(username))
+	default:
+		return fmt.Errorf("container registry %s does not allow pushing to %s as starting an upload returned status %d", host, repoName, resp.StatusCode)
+	}
+	o.cancelUpload(resp, authorization)
+	log.Logger().Infof("container registry %s allows pushing to %s", termcolor.ColorInfo(host), termcolor.ColorInfo(repoName))
+	return nil
+}
+
+// authorization returns the Authorization header for the challenge of the registry
+func (o *Options) authorization(challenge, repoName, username, password string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return "", fmt.Errorf("the registry requires credentials%s", o.credentialsHint(username))
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.SetBasicAuth(username, password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		return o.bearerToken(params, repoName, username, password)
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge '%s'", challenge)
+	}
+}
+
+// bearerToken requests a token for pulling and pushing the repository from the realm of the challenge
+func (o *Options) bearerToken(params map[string]string, repoName, username, password string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("the bearer challenge has no realm")
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the token realm %s: %w", realm, err)
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", "repository:"+repoName+":pull,push")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(o.GetContext(), http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the token request: %w", err)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := o.getHTTPClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request a token from %s: %w", realm, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("the token request to %s returned status %d%s", realm, resp.StatusCode, o.credentialsHint(username))
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the token response from %s: %w", realm, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("the token response from %s has no token", realm)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// cancelUpload cancels the upload started by the preflight. Failures are only logged as the registry expires
+// abandoned uploads anyway
+func (o *Options) cancelUpload(resp *http.Response, authorization string) {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		log.Logger().Debugf("failed to parse the upload location %s: %s", location, err.Error())
+		return
+	}
+	base, _ := url.Parse(o.baseURL() + "/")
+	cancelResp, err := o.send(http.MethodDelete, base.ResolveReference(u).String(), authorization)
+	if err != nil {
+		log.Logger().Debugf("failed to cancel the upload %s: %s", location, err.Error())
+		return
+	}
+	if cancelResp.StatusCode != http.StatusNoContent && cancelResp.StatusCode != http.StatusOK {
+		log.Logger().Debugf("cancelling the upload %s returned status %d", location, cancelResp.StatusCode)
+	}
+}
+
+func (o *Options) send(method, u, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(o.GetContext(), method, u, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := o.getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp, nil
+}
+
+func (o *Options) credentialsHint(username string) string {
+	if username != "" {
+		return ""
+	}
+	return fmt.Sprintf(". No credentials were found in $OCI_USERNAME or the Secret %s", o.SecretName)
+}
+
+func (o *Options) baseURL() string {
+	scheme := "https"
+	if o.Insecure {
+		scheme = "http"
+	}
+	return scheme + "://" + RegistryHost(o.Registry)
+}
+
+func (o *Options) getHTTPClient() *http.Client {
+	if o.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return o.HTTPClient
+}
+
+// GetContext returns the context defaulting to a new context
+func (o *Options) GetContext() context.Context {
+	if o.Context == nil {
+		o.Context = context.TODO()
+	}
+	return o.Context
+}
+
+// RegistryHost returns the host of the registry removing any scheme and path
+func RegistryHost(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(registry), "https://"), "http://")
+	host, _, _ := strings.Cut(registry, "/")
+	return host
+}
+
+// parseChallenge parses the WWW-Authenticate header into its scheme and parameters
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key != "" {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+	return scheme, params
+}
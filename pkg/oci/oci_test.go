@@ -0,0 +1,131 @@
+package oci_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/oci"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/oci/fakeoci"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newOptions(registry *fakeoci.FakeRegistry, objects ...runtime.Object) *oci.Options {
+	return &oci.Options{
+		Registry:             registry.Host(),
+		RegistryOrganisation: "myorg",
+		Enabled:              true,
+		SecretName:           oci.DefaultSecretName,
+		Insecure:             true,
+		Namespace:            "jx",
+		KubeClient:           fake.NewSimpleClientset(objects...),
+	}
+}
+
+func dockerConfigSecret(host string) *corev1.Secret {
+	auth := base64.StdEncoding.EncodeToString([]byte(fakeoci.Username + ":" + fakeoci.Password))
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: oci.DefaultSecretName, Namespace: "jx"},
+		Data: map[string][]byte{
+			".dockerconfigjson": []byte(fmt.Sprintf(`{"auths": {"https://%s": {"auth": "%s"}}}`, host, auth)),
+		},
+	}
+}
+
+func TestPreflightAnonymous(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("")
+	defer registry.Close()
+	o := newOptions(registry)
+
+	err := o.Preflight("myapp")
+	require.NoError(t, err, "failed to run preflight")
+	assert.Contains(t, registry.Requests, "POST /v2/myorg/myapp/blobs/uploads/")
+	assert.Empty(t, registry.Uploads, "should have cancelled the upload")
+}
+
+func TestPreflightBearerTokenWithSecret(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("Bearer")
+	defer registry.Close()
+	o := newOptions(registry, dockerConfigSecret(registry.Host()))
+
+	err := o.Preflight("myapp")
+	require.NoError(t, err, "failed to run preflight")
+	assert.Contains(t, registry.Requests, "GET /token")
+	assert.Empty(t, registry.Uploads, "should have cancelled the upload")
+}
+
+func TestPreflightBasicWithEnvCredentials(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("Basic")
+	defer registry.Close()
+	o := newOptions(registry)
+	o.Username = fakeoci.Username
+	o.Password = fakeoci.Password
+
+	err := o.Preflight("myapp")
+	require.NoError(t, err, "failed to run preflight")
+}
+
+func TestPreflightAuthenticatesPushWithAnonymousReads(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("Bearer")
+	defer registry.Close()
+	registry.AnonymousReads = true
+	o := newOptions(registry, dockerConfigSecret(registry.Host()))
+
+	err := o.Preflight("myapp")
+	require.NoError(t, err, "failed to run preflight")
+	assert.Equal(t, []string{
+		"GET /v2/",
+		"POST /v2/myorg/myapp/blobs/uploads/",
+		"GET /token",
+		"POST /v2/myorg/myapp/blobs/uploads/",
+		"DELETE /v2/myorg/myapp/blobs/uploads/a",
+	}, registry.Requests, "should have authenticated and retried the upload")
+	assert.Empty(t, registry.Uploads, "should have cancelled the upload")
+}
+
+func TestPreflightWithoutCredentials(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("Bearer")
+	defer registry.Close()
+	o := newOptions(registry)
+
+	err := o.Preflight("myapp")
+	require.Error(t, err, "should fail without credentials")
+	assert.Contains(t, err.Error(), "No credentials were found")
+}
+
+func TestPreflightPushDenied(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("Basic")
+	defer registry.Close()
+	registry.PushRepositories = []string{"otherorg/myapp"}
+	o := newOptions(registry, dockerConfigSecret(registry.Host()))
+
+	err := o.Preflight("myapp")
+	require.Error(t, err, "should fail when pushes are denied")
+	assert.Contains(t, err.Error(), "do not allow pushing to myorg/myapp")
+}
+
+func TestPreflightUnreachable(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("")
+	o := newOptions(registry)
+	registry.Close()
+
+	err := o.Preflight("myapp")
+	require.Error(t, err, "should fail when the registry is not reachable")
+	assert.Contains(t, err.Error(), "is not reachable")
+}
+
+func TestPreflightDryRun(t *testing.T) {
+	registry := fakeoci.NewFakeRegistry("")
+	defer registry.Close()
+	o := newOptions(registry)
+	o.DryRun = true
+
+	err := o.Preflight("myapp")
+	require.NoError(t, err, "failed to run preflight")
+	assert.Equal(t, []string{"GET /v2/"}, registry.Requests, "should not start an upload in dry run mode")
+}
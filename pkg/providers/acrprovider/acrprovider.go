@@ -0,0 +1,81 @@
+package acrprovider
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+)
+
+const (
+	// Name the name of the Azure Container Registry backend
+	Name = "acr"
+
+	// ClusterProvider the cluster provider using Azure Container Registry
+	ClusterProvider = "aks"
+)
+
+// Provider the Azure Container Registry provider wrapping the ACR options
+type Provider struct {
+	Options      *acrs.Options
+	HostSuffixes providers.HostSuffixes
+}
+
+var _ providers.RegistryProvider = (*Provider)(nil)
+
+// NewProvider creates a new Azure Container Registry provider claiming the registry hosts with the given suffixes
+// which default to acrs.HostSuffix
+func NewProvider(o *acrs.Options, hostSuffixes providers.HostSuffixes) *Provider {
+	if len(hostSuffixes) == 0 {
+		hostSuffixes = providers.HostSuffixes{acrs.HostSuffix}
+	}
+	return &Provider{
+		Options:      o,
+		HostSuffixes: hostSuffixes,
+	}
+}
+
+// Name returns the name of the backend
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Matches returns true for AKS clusters using an ACR registry host
+func (p *Provider) Matches(clusterProvider, registry string) bool {
+	return clusterProvider == ClusterProvider && p.HostSuffixes.Claims(registry)
+}
+
+// EnsureRepository ensures the settings of the ACR repository of the image along with its retention policy and purge task
+func (p *Provider) EnsureRepository(image string, cache bool) error {
+	return p.Options.EnsureRepository(image, cache)
+}
+
+// EnsureRetentionPolicy ensures the retention policy and purge task of the ACR repository of the image
+func (p *Provider) EnsureRetentionPolicy(image string, cache bool) error {
+	return p.Options.EnsureRetentionPolicy(image, cache)
+}
+
+// DeleteRepository deletes the ACR repository of the image. As all the images of the repository are deleted
+// force must be true
+func (p *Provider) DeleteRepository(image string, force bool) error {
+	if !force {
+		return fmt.Errorf("deleting the ACR repository of %s deletes all of its images so requires force", image)
+	}
+	return p.Options.DeleteRepository(image)
+}
+
+// ListRepositories lists the ACR repositories of the registry organisation
+func (p *Provider) ListRepositories() ([]providers.Repository, error) {
+	names, err := p.Options.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	var answer []providers.Repository
+	for _, name := range names {
+		answer = append(answer, providers.Repository{
+			Name: name,
+			URI:  p.Options.URI(name),
+		})
+	}
+	return answer, nil
+}
@@ -0,0 +1,47 @@
+package acrprovider_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/azure/acrs/fakeacr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/acrprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatches(t *testing.T) {
+	p := acrprovider.NewProvider(&acrs.Options{}, nil)
+	assert.True(t, p.Matches("aks", "myregistry.azurecr.io"))
+	assert.False(t, p.Matches("aks", ""))
+	assert.False(t, p.Matches("aks", "ghcr.io"))
+	assert.False(t, p.Matches("eks", "myregistry.azurecr.io"))
+
+	p = acrprovider.NewProvider(&acrs.Options{}, providers.HostSuffixes{".azurecr.cn"})
+	assert.True(t, p.Matches("aks", "myregistry.azurecr.cn"))
+	assert.False(t, p.Matches("aks", "myregistry.azurecr.io"))
+}
+
+func TestProvider(t *testing.T) {
+	fakeACR := fakeacr.NewFakeACR()
+	fakeACR.AddRepository("myorg/myapp")
+	fakeACR.AddRepository("other/app")
+	p := acrprovider.NewProvider(&acrs.Options{
+		Registry:             "myregistry.azurecr.io",
+		RegistryOrganisation: "myorg",
+		Client:               fakeACR,
+	}, nil)
+
+	repos, err := p.ListRepositories()
+	require.NoError(t, err, "failed to list repositories")
+	require.Len(t, repos, 1)
+	assert.Equal(t, "myorg/myapp", repos[0].Name)
+	assert.Equal(t, "myregistry.azurecr.io/myorg/myapp", repos[0].URI)
+
+	err = p.DeleteRepository("myapp", false)
+	require.Error(t, err, "should require force")
+	err = p.DeleteRepository("myapp", true)
+	require.NoError(t, err, "failed to delete repository")
+	assert.Len(t, fakeACR.Repositories, 1)
+}
@@ -0,0 +1,160 @@
+package ecrprovider
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+)
+
+const (
+	// Name the name of the ECR backend
+	Name = "ecr"
+
+	// ClusterProvider the cluster provider using ECR
+	ClusterProvider = "eks"
+
+	// DefaultRegistrySuffix the default suffix of the ECR registry hosts claimed by the provider
+	DefaultRegistrySuffix = ".amazonaws.com"
+)
+
+// Provider the ECR registry provider wrapping the ECR options
+type Provider struct {
+	Options      *ecrs.Options
+	Finder       *requirements.FinderOptions
+	HostSuffixes providers.HostSuffixes
+
+	policiesFound      bool
+	replicationEnsured bool
+}
+
+var _ providers.RegistryProvider = (*Provider)(nil)
+
+// NewProvider creates a new ECR provider claiming the registry hosts with the given suffixes which default to
+// DefaultRegistrySuffix
+func NewProvider(o *ecrs.Options, finder *requirements.FinderOptions, hostSuffixes providers.HostSuffixes) *Provider {
+	if len(hostSuffixes) == 0 {
+		hostSuffixes = providers.HostSuffixes{DefaultRegistrySuffix}
+	}
+	return &Provider{
+		Options:      o,
+		Finder:       finder,
+		HostSuffixes: hostSuffixes,
+	}
+}
+
+// Name returns the name of the backend
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Matches returns true for EKS clusters using an ECR registry
+func (p *Provider) Matches(clusterProvider, registry string) bool {
+	if clusterProvider != ClusterProvider {
+		return false
+	}
+	return registry == "" || registry == "ecr.io" || p.HostSuffixes.Claims(registry)
+}
+
+// EnsureRepository lazily creates the ECR repository along with its settings and policies. The registry replication
+// configuration is ensured after the first repository
+func (p *Provider) EnsureRepository(image string, cache bool) error {
+	err := p.findPolicies()
+	if err != nil {
+		return err
+	}
+	o := p.Options
+	if cache {
+		co := o.CacheOptions()
+		err = co.LazyCreateRegistry(image)
+		o.ECRClient = co.ECRClient
+		o.ECRClients = co.ECRClients
+		if err != nil {
+			return fmt.Errorf("failed to lazy create the ECR registry for %s: %w", image, err)
+		}
+	} else {
+		err = o.LazyCreateRegistry(image)
+		if err != nil {
+			return fmt.Errorf("failed to lazy create the ECR registry for %s: %w", image, err)
+		}
+	}
+	if p.replicationEnsured {
+		return nil
+	}
+	err = o.EnsureReplicationConfiguration()
+	if err != nil {
+		return err
+	}
+	p.replicationEnsured = true
+	return nil
+}
+
+// EnsureRetentionPolicy ensures the lifecycle policy of the existing ECR repository in each region
+func (p *Provider) EnsureRetentionPolicy(image string, cache bool) error {
+	err := p.findPolicies()
+	if err != nil {
+		return err
+	}
+	o := p.Options
+	if cache {
+		o = o.CacheOptions()
+	}
+	repoName := o.RepositoryName(image)
+	for _, region := range o.RepositoryRegions() {
+		ro, err := o.RegionOptions(region).RepositoryOptions(repoName)
+		if err != nil {
+			return err
+		}
+		lo := *ro
+		lo.CreateECRLifeCyclePolicy = true
+		lo.CreateECRRepositoryPolicy = false
+		lo.AllowPullAccounts = nil
+		lo.AllowPushRoleARNs = nil
+		lo.AllowPullOrgIDs = nil
+		err = lo.EnsureLifecyclePolicy(repoName)
+		if err != nil {
+			return fmt.Errorf("failed to ensure the lifecycle policy of ECR repository %s in region %s: %w", repoName, region, err)
+		}
+	}
+	return nil
+}
+
+// DeleteRepository deletes the ECR repository of the image in each region
+func (p *Provider) DeleteRepository(image string, force bool) error {
+	return p.Options.DeleteRepository(p.Options.RepositoryName(image), force)
+}
+
+// ListRepositories lists the ECR repositories of the registry organisation
+func (p *Provider) ListRepositories() ([]providers.Repository, error) {
+	repos, err := p.Options.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	var answer []providers.Repository
+	for i := range repos {
+		answer = append(answer, providers.Repository{
+			Name: repos[i].Name,
+			URI:  repos[i].URI,
+		})
+	}
+	return answer, nil
+}
+
+// findPolicies loads the policy files and lets the options look up the centrally managed policies for repositories
+// which are missing a policy
+func (p *Provider) findPolicies() error {
+	if p.policiesFound {
+		return nil
+	}
+	o := p.Options
+	err := o.LoadPolicyFiles()
+	if err != nil {
+		return err
+	}
+	if p.Finder != nil && o.PolicyFinder == nil {
+		o.PolicyFinder = p.Finder
+	}
+	p.policiesFound = true
+	return nil
+}
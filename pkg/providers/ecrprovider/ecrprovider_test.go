@@ -0,0 +1,56 @@
+package ecrprovider_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/amazon/ecrs/fakeecr"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/ecrprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatches(t *testing.T) {
+	p := ecrprovider.NewProvider(&ecrs.Options{}, nil, nil)
+	assert.True(t, p.Matches("eks", ""))
+	assert.True(t, p.Matches("eks", "123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+	assert.False(t, p.Matches("eks", "ghcr.io"))
+	assert.False(t, p.Matches("gke", "123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+}
+
+func TestProvider(t *testing.T) {
+	fakeECR := fakeecr.NewFakeECR()
+	o := &ecrs.Options{
+		ECRClient:                fakeECR,
+		RegistryOrganisation:     "myorg",
+		CreateECRLifeCyclePolicy: true,
+	}
+	o.AWSRegion = "us-east-1"
+	o.Config = &aws.Config{}
+	p := ecrprovider.NewProvider(o, nil, nil)
+
+	err := p.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository")
+	err = p.EnsureRepository("myapp/cache", true)
+	require.NoError(t, err, "failed to ensure cache repository")
+
+	cachePolicy, err := ecrs.DefaultCacheLifecyclePolicy().ToJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, cachePolicy, fakeECR.LifecyclePolicies["myorg/myapp/cache"], "should use the cache lifecycle policy")
+
+	repos, err := p.ListRepositories()
+	require.NoError(t, err, "failed to list repositories")
+	require.Len(t, repos, 2)
+	assert.Equal(t, "myorg/myapp", repos[0].Name)
+
+	o.KeepLastNTagged = 5
+	err = p.EnsureRetentionPolicy("myapp", false)
+	require.NoError(t, err, "failed to ensure retention policy")
+	assert.Contains(t, fakeECR.LifecyclePolicies["myorg/myapp"], "imageCountMoreThan", "should have updated the lifecycle policy")
+	assert.Empty(t, fakeECR.RepositoryPolicies, "should not set repository policies")
+
+	err = p.DeleteRepository("myapp/cache", true)
+	require.NoError(t, err, "failed to delete repository")
+	assert.NotContains(t, fakeECR.Repositories, "myorg/myapp/cache")
+}
@@ -0,0 +1,85 @@
+package garprovider
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+)
+
+const (
+	// Name the name of the Google Artifact Registry backend
+	Name = "gar"
+
+	// ClusterProvider the cluster provider using Google Artifact Registry
+	ClusterProvider = "gke"
+)
+
+// Provider the Google Artifact Registry provider wrapping the Artifact Registry options
+type Provider struct {
+	Options      *artifactregistry.Options
+	HostSuffixes providers.HostSuffixes
+}
+
+var _ providers.RegistryProvider = (*Provider)(nil)
+
+// NewProvider creates a new Google Artifact Registry provider claiming the registry hosts with the given suffixes
+// which default to artifactregistry.HostSuffix
+func NewProvider(o *artifactregistry.Options, hostSuffixes providers.HostSuffixes) *Provider {
+	if len(hostSuffixes) == 0 {
+		hostSuffixes = providers.HostSuffixes{artifactregistry.HostSuffix}
+	}
+	return &Provider{
+		Options:      o,
+		HostSuffixes: hostSuffixes,
+	}
+}
+
+// Name returns the name of the backend
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Matches returns true for GKE clusters using a Docker format Artifact Registry host
+func (p *Provider) Matches(clusterProvider, registry string) bool {
+	return clusterProvider == ClusterProvider && p.HostSuffixes.Claims(registry)
+}
+
+// EnsureRepository lazily creates the Artifact Registry repository of the image and ensures its cleanup policies
+func (p *Provider) EnsureRepository(image string, cache bool) error {
+	err := p.Options.LazyCreateRepository(image, cache)
+	if err != nil {
+		return fmt.Errorf("failed to lazy create the Artifact Registry repository for %s: %w", image, err)
+	}
+	return nil
+}
+
+// EnsureRetentionPolicy ensures the cleanup policies of the Artifact Registry repository of the image
+func (p *Provider) EnsureRetentionPolicy(image string, cache bool) error {
+	return p.Options.EnsureCleanupPolicies(image, cache)
+}
+
+// DeleteRepository deletes the Artifact Registry package of the image. As all the versions of the package are
+// deleted force must be true
+func (p *Provider) DeleteRepository(image string, force bool) error {
+	if !force {
+		return fmt.Errorf("deleting the Artifact Registry package of %s deletes all of its images so requires force", image)
+	}
+	return p.Options.DeletePackage(image)
+}
+
+// ListRepositories lists the Artifact Registry packages of the registry organisation
+func (p *Provider) ListRepositories() ([]providers.Repository, error) {
+	refs, err := p.Options.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	var answer []providers.Repository
+	for _, ref := range refs {
+		answer = append(answer, providers.Repository{
+			Name: ref.Package,
+			URI:  ref.URI() + "/" + ref.Package,
+		})
+	}
+	return answer, nil
+}
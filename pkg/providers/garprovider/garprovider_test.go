@@ -0,0 +1,52 @@
+package garprovider_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/google/artifactregistry/fakegar"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/garprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatches(t *testing.T) {
+	p := garprovider.NewProvider(&artifactregistry.Options{}, nil)
+	assert.True(t, p.Matches("gke", "us-docker.pkg.dev/myproject/myrepo"))
+	assert.True(t, p.Matches("gke", "europe-west1-docker.pkg.dev/myproject/myrepo"))
+	assert.False(t, p.Matches("gke", ""))
+	assert.False(t, p.Matches("gke", "gcr.io/myproject"))
+	assert.False(t, p.Matches("eks", "us-docker.pkg.dev/myproject/myrepo"))
+}
+
+func TestProvider(t *testing.T) {
+	fakeGAR := fakegar.NewFakeArtifactRegistry()
+	p := garprovider.NewProvider(&artifactregistry.Options{
+		Registry:             "us-docker.pkg.dev/myproject/myrepo",
+		RegistryOrganisation: "myorg",
+		Client:               fakeGAR,
+	}, nil)
+
+	err := p.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository")
+	err = p.EnsureRetentionPolicy("myapp/cache", true)
+	require.NoError(t, err, "failed to ensure retention policy")
+
+	repoName := "projects/myproject/locations/us/repositories/myrepo"
+	require.NotNil(t, fakeGAR.Repositories[repoName], "should have created the repository")
+	assert.Len(t, fakeGAR.Repositories[repoName].CleanupPolicies, 2)
+
+	fakeGAR.AddPackage(repoName + "/packages/myorg%2Fmyapp")
+	fakeGAR.AddPackage(repoName + "/packages/other%2Fapp")
+	repos, err := p.ListRepositories()
+	require.NoError(t, err, "failed to list repositories")
+	require.Len(t, repos, 1)
+	assert.Equal(t, "myorg/myapp", repos[0].Name)
+	assert.Equal(t, "us-docker.pkg.dev/myproject/myrepo/myorg/myapp", repos[0].URI)
+
+	err = p.DeleteRepository("myapp", false)
+	require.Error(t, err, "should require force")
+	err = p.DeleteRepository("myapp", true)
+	require.NoError(t, err, "failed to delete repository")
+	assert.Len(t, fakeGAR.Packages, 1)
+}
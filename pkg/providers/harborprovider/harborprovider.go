@@ -0,0 +1,83 @@
+package harborprovider
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+)
+
+const (
+	// Name the name of the Harbor backend
+	Name = "harbor"
+)
+
+// Provider the Harbor provider wrapping the Harbor options
+type Provider struct {
+	Options      *harbor.Options
+	HostSuffixes providers.HostSuffixes
+}
+
+var _ providers.RegistryProvider = (*Provider)(nil)
+
+// NewProvider creates a new Harbor provider claiming the registry hosts with the given suffixes. As Harbor has no
+// well known hosts the host of the Harbor URL is also claimed
+func NewProvider(o *harbor.Options, hostSuffixes providers.HostSuffixes) *Provider {
+	return &Provider{
+		Options:      o,
+		HostSuffixes: hostSuffixes,
+	}
+}
+
+// Name returns the name of the backend
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Matches returns true for a registry host claimed by the provider on any cluster provider
+func (p *Provider) Matches(_, registry string) bool {
+	if registry == "" {
+		return false
+	}
+	if p.HostSuffixes.Claims(registry) {
+		return true
+	}
+	return p.Options.URL != "" && harbor.RegistryHost(p.Options.URL) == harbor.RegistryHost(registry)
+}
+
+// EnsureRepository lazily creates the Harbor project of the registry organisation. Harbor creates repositories on
+// their first push and the cache repositories share the retention policy of the project
+func (p *Provider) EnsureRepository(_ string, _ bool) error {
+	return p.Options.EnsureProject()
+}
+
+// EnsureRetentionPolicy ensures the settings and tag retention policy of the Harbor project
+func (p *Provider) EnsureRetentionPolicy(_ string, _ bool) error {
+	return p.Options.EnsureProject()
+}
+
+// DeleteRepository deletes the Harbor repository of the image. As all the artifacts of the repository are deleted
+// force must be true
+func (p *Provider) DeleteRepository(image string, force bool) error {
+	if !force {
+		return fmt.Errorf("deleting the Harbor repository of %s deletes all of its artifacts so requires force", image)
+	}
+	return p.Options.DeleteRepository(image)
+}
+
+// ListRepositories lists the repositories of the Harbor project
+func (p *Provider) ListRepositories() ([]providers.Repository, error) {
+	names, err := p.Options.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	host := harbor.RegistryHost(p.Options.Registry)
+	var answer []providers.Repository
+	for _, name := range names {
+		answer = append(answer, providers.Repository{
+			Name: name,
+			URI:  host + "/" + name,
+		})
+	}
+	return answer, nil
+}
@@ -0,0 +1,53 @@
+package harborprovider_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/harbor/fakeharbor"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/harborprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatches(t *testing.T) {
+	p := harborprovider.NewProvider(&harbor.Options{}, nil)
+	assert.False(t, p.Matches("kubernetes", "harbor.example.com"), "should not claim hosts by default")
+
+	p = harborprovider.NewProvider(&harbor.Options{}, providers.HostSuffixes{".example.com"})
+	assert.True(t, p.Matches("kubernetes", "harbor.example.com"))
+	assert.True(t, p.Matches("eks", "harbor.example.com"))
+	assert.False(t, p.Matches("kubernetes", ""))
+
+	p = harborprovider.NewProvider(&harbor.Options{URL: "https://harbor.example.com"}, nil)
+	assert.True(t, p.Matches("kubernetes", "harbor.example.com"))
+	assert.False(t, p.Matches("kubernetes", "ghcr.io"))
+}
+
+func TestProvider(t *testing.T) {
+	fakeHarbor := fakeharbor.NewFakeHarbor()
+	defer fakeHarbor.Close()
+	p := harborprovider.NewProvider(&harbor.Options{
+		Registry:             "harbor.example.com",
+		RegistryOrganisation: "myorg",
+		RetentionSchedule:    harbor.DefaultRetentionSchedule,
+		Client:               fakeHarbor.Client(),
+	}, nil)
+
+	err := p.EnsureRepository("myapp", false)
+	require.NoError(t, err, "failed to ensure repository")
+	require.NotNil(t, fakeHarbor.Projects["myorg"], "should have created the project")
+
+	fakeHarbor.Repositories["myorg"] = []string{"myapp"}
+	repos, err := p.ListRepositories()
+	require.NoError(t, err, "failed to list repositories")
+	require.Len(t, repos, 1)
+	assert.Equal(t, "harbor.example.com/myorg/myapp", repos[0].URI)
+
+	err = p.DeleteRepository("myapp", false)
+	require.Error(t, err, "should require force")
+	err = p.DeleteRepository("myapp", true)
+	require.NoError(t, err, "failed to delete repository")
+	assert.Empty(t, fakeHarbor.Repositories["myorg"])
+}
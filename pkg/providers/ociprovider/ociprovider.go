@@ -0,0 +1,61 @@
+package ociprovider
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/oci"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// Name the name of the generic OCI distribution backend
+	Name = "oci"
+)
+
+// Provider the generic OCI distribution provider for registries without repository APIs. It only checks that the
+// registry is ready for pushes so should be the last provider
+type Provider struct {
+	Options *oci.Options
+}
+
+var _ providers.RegistryProvider = (*Provider)(nil)
+
+// NewProvider creates a new OCI distribution provider
+func NewProvider(o *oci.Options) *Provider {
+	return &Provider{
+		Options: o,
+	}
+}
+
+// Name returns the name of the backend
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Matches returns true for any registry if the preflight is enabled
+func (p *Provider) Matches(_, registry string) bool {
+	return p.Options.Enabled && registry != ""
+}
+
+// EnsureRepository checks the registry is reachable and allows pushes to the repository of the image. The
+// repository is created by the first push
+func (p *Provider) EnsureRepository(image string, _ bool) error {
+	return p.Options.Preflight(image)
+}
+
+// EnsureRetentionPolicy does nothing as the OCI distribution API has no retention policies
+func (p *Provider) EnsureRetentionPolicy(image string, _ bool) error {
+	log.Logger().Debugf("not ensuring a retention policy for %s as the OCI distribution API does not support them", image)
+	return nil
+}
+
+// DeleteRepository is not supported by the OCI distribution API
+func (p *Provider) DeleteRepository(image string, _ bool) error {
+	return fmt.Errorf("cannot delete the repository of %s as the OCI distribution API does not support deleting repositories", image)
+}
+
+// ListRepositories is not supported by the OCI distribution API
+func (p *Provider) ListRepositories() ([]providers.Repository, error) {
+	return nil, fmt.Errorf("cannot list the repositories of registry %s as the OCI distribution API does not support listing repositories", p.Options.Registry)
+}
@@ -0,0 +1,19 @@
+package ociprovider_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/oci"
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers/ociprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatches(t *testing.T) {
+	p := ociprovider.NewProvider(&oci.Options{Enabled: true})
+	assert.True(t, p.Matches("gke", "ghcr.io"))
+	assert.True(t, p.Matches("kind", "localhost:5000"))
+	assert.False(t, p.Matches("kind", ""))
+
+	p = ociprovider.NewProvider(&oci.Options{})
+	assert.False(t, p.Matches("gke", "ghcr.io"), "should not match when the preflight is disabled")
+}
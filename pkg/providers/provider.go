@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Repository a summary of a container image repository
+type Repository struct {
+	Name string `json:"name"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// RegistryProvider manages the repositories of a container registry backend
+type RegistryProvider interface {
+	// Name returns the name of the backend such as ecr
+	Name() string
+
+	// Matches returns true if the backend manages the registry for the cluster provider and registry host
+	Matches(clusterProvider, registry string) bool
+
+	// EnsureRepository lazily creates the repository of the image and reconciles its settings and retention policy.
+	// Cache repositories use the retention policy of cache images
+	EnsureRepository(image string, cache bool) error
+
+	// EnsureRetentionPolicy reconciles the retention policy of the existing repository of the image
+	EnsureRetentionPolicy(image string, cache bool) error
+
+	// DeleteRepository deletes the repository of the image. Repositories containing images are only deleted if force is true
+	DeleteRepository(image string, force bool) error
+
+	// ListRepositories lists the repositories of the registry organisation
+	ListRepositories() ([]Repository, error)
+}
+
+// Find returns the first provider which matches the cluster provider and registry host or nil if there is no match
+func Find(providers []RegistryProvider, clusterProvider, registry string) RegistryProvider {
+	for _, p := range providers {
+		if p.Matches(clusterProvider, registry) {
+			return p
+		}
+	}
+	return nil
+}
+
+// HostSuffixes the registry host suffixes claimed by a backend
+type HostSuffixes []string
+
+// Claims returns true if the host of the registry ends with one of the suffixes
+func (s HostSuffixes) Claims(registry string) bool {
+	host, _, _ := strings.Cut(strings.TrimSpace(registry), "/")
+	for _, suffix := range s {
+		if suffix != "" && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHostSuffixes parses values of the form backend=suffix into the host suffixes claimed by each backend
+func ParseHostSuffixes(values []string) (map[string]HostSuffixes, error) {
+	answer := map[string]HostSuffixes{}
+	for _, v := range values {
+		backend, suffix, ok := strings.Cut(v, "=")
+		backend = strings.TrimSpace(backend)
+		suffix = strings.TrimSpace(suffix)
+		if !ok || backend == "" || suffix == "" {
+			return nil, fmt.Errorf("invalid registry suffix '%s' should be of the form backend=suffix", v)
+		}
+		answer[backend] = append(answer[backend], suffix)
+	}
+	return answer, nil
+}
@@ -0,0 +1,31 @@
+package providers_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostSuffixesClaims(t *testing.T) {
+	s := providers.HostSuffixes{".amazonaws.com", ".example.com"}
+	assert.True(t, s.Claims("123456789012.dkr.ecr.us-east-1.amazonaws.com"))
+	assert.True(t, s.Claims("registry.example.com/myorg"))
+	assert.False(t, s.Claims("ghcr.io/amazonaws.com"))
+	assert.False(t, providers.HostSuffixes{}.Claims("registry.example.com"))
+}
+
+func TestParseHostSuffixes(t *testing.T) {
+	answer, err := providers.ParseHostSuffixes([]string{"ecr=.amazonaws.com.cn", "acr=.azurecr.cn", "ecr=.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]providers.HostSuffixes{
+		"ecr": {".amazonaws.com.cn", ".example.com"},
+		"acr": {".azurecr.cn"},
+	}, answer)
+
+	for _, v := range []string{"ecr", "=.amazonaws.com", "ecr="} {
+		_, err = providers.ParseHostSuffixes([]string{v})
+		assert.Error(t, err, "should fail for %s", v)
+	}
+}
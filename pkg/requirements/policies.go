@@ -0,0 +1,147 @@
+package requirements
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/requirements"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PolicyConfigMapName the name of the ConfigMap in the jx namespace containing the registry policies
+	PolicyConfigMapName = "jx-registry-policies"
+
+	// PolicyDir the directory in the dev environment git repository containing the registry policies
+	PolicyDir = ".jx/registry"
+
+	// LifecyclePolicyKey the ConfigMap key and file name of the lifecycle policy
+	LifecyclePolicyKey = "lifecycle-policy.json"
+
+	// RepositoryPolicyKey the ConfigMap key and file name of the repository policy
+	RepositoryPolicyKey = "repository-policy.json"
+)
+
+// FindPolicy finds the policy with the given key from the PolicyConfigMapName ConfigMap or if its not there from the
+// PolicyDir directory of the dev environment git repository. Returns an empty string if there is no policy. Failing to
+// access the cluster or clone the dev environment is only logged as the central policies are optional
+func (o *FinderOptions) FindPolicy(key string) (string, error) {
+	policy := o.findConfigMapPolicy(key)
+	if policy != "" {
+		log.Logger().Infof("using %s from ConfigMap %s in namespace %s", key, PolicyConfigMapName, o.devNamespace)
+		return policy, nil
+	}
+
+	dir, err := o.findDevEnvironmentDir()
+	if err != nil {
+		log.Logger().Warnf("not looking for policies in the dev environment git repository: %s", err.Error())
+		return "", nil
+	}
+	if dir == "" {
+		return "", nil
+	}
+	path := filepath.Join(dir, PolicyDir, key)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if file exists %s: %w", path, err)
+	}
+	if !exists {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	log.Logger().Infof("using %s from the dev environment git repository", filepath.Join(PolicyDir, key))
+	return string(data), nil
+}
+
+func (o *FinderOptions) findConfigMapPolicy(key string) string {
+	if o.policyConfigMap == nil {
+		var err error
+		o.policyConfigMap = map[string]string{}
+		o.KubeClient, o.Namespace, err = kube.LazyCreateKubeClientAndNamespace(o.KubeClient, o.Namespace)
+		if err != nil {
+			log.Logger().Warnf("not looking for policies in ConfigMap %s as failed to create kube client: %s", PolicyConfigMapName, err.Error())
+			return ""
+		}
+		ns := o.findDevNamespace()
+		cm, err := o.KubeClient.CoreV1().ConfigMaps(ns).Get(context.TODO(), PolicyConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) && !apierrors.IsForbidden(err) {
+				log.Logger().Warnf("failed to get ConfigMap %s in namespace %s: %s", PolicyConfigMapName, ns, err.Error())
+			} else {
+				log.Logger().Debugf("could not load ConfigMap %s in namespace %s: %s", PolicyConfigMapName, ns, err.Error())
+			}
+		} else {
+			o.policyConfigMap = cm.Data
+			if o.policyConfigMap == nil {
+				o.policyConfigMap = map[string]string{}
+			}
+		}
+	}
+	return o.policyConfigMap[key]
+}
+
+// findDevNamespace lazily resolves the jx namespace of the team of the current namespace, falling back to the default
+// jx namespace if the namespace cannot be read
+func (o *FinderOptions) findDevNamespace() string {
+	if o.devNamespace != "" {
+		return o.devNamespace
+	}
+	ns, _, err := jxenv.GetDevNamespace(o.KubeClient, o.Namespace)
+	if err != nil || ns == "" {
+		log.Logger().Debugf("failed to find the jx namespace of namespace %s so using %s: %v", o.Namespace, jxcore.DefaultNamespace, err)
+		ns = jxcore.DefaultNamespace
+	}
+	o.devNamespace = ns
+	return o.devNamespace
+}
+
+// findDevEnvironmentDir lazily clones the PolicyDir of the dev environment git repository. This is a separate sparse
+// clone from the one used to find the requirements so that it only checks out the PolicyDir
+func (o *FinderOptions) findDevEnvironmentDir() (string, error) {
+	if o.DevEnvironmentDir != "" || o.devEnvironmentCloned {
+		return o.DevEnvironmentDir, nil
+	}
+	o.devEnvironmentCloned = true
+
+	settings, err := requirements.LoadSettings("", true)
+	if err != nil {
+		return "", fmt.Errorf("failed to load settings: %w", err)
+	}
+	gitURL := ""
+	if settings != nil {
+		gitURL = settings.Spec.GitURL
+	}
+	if gitURL == "" {
+		o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+		if err != nil {
+			return "", fmt.Errorf("failed to create jxClient: %w", err)
+		}
+		env, err := jxenv.GetDevEnvironment(o.JXClient, o.Namespace)
+		if err != nil {
+			return "", fmt.Errorf("failed to get dev environment: %w", err)
+		}
+		if env == nil || env.Spec.Source.URL == "" {
+			log.Logger().Debugf("no dev environment source URL in namespace %s so not looking for policies in git", o.Namespace)
+			return "", nil
+		}
+		gitURL = env.Spec.Source.URL
+	}
+	o.lazyCreateGitClient()
+	o.DevEnvironmentDir, err = requirements.PartialCloneClusterRepo(o.GitClient, gitURL, true, PolicyDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to clone dev environment git repository %s: %w", gitURL, err)
+	}
+	return o.DevEnvironmentDir, nil
+}
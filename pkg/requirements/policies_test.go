@@ -0,0 +1,120 @@
+package requirements_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-registry/pkg/requirements"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const lifecyclePolicy = `{"rules": [{"rulePriority": 1, "selection": {"tagStatus": "untagged", "countType": "sinceImagePushed", "countUnit": "days", "countNumber": 3}, "action": {"type": "expire"}}]}`
+
+func TestFindPolicyFromConfigMap(t *testing.T) {
+	o := &requirements.FinderOptions{
+		Namespace: "jx",
+		KubeClient: fake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: requirements.PolicyConfigMapName, Namespace: "jx"},
+			Data: map[string]string{
+				requirements.LifecyclePolicyKey: lifecyclePolicy,
+			},
+		}),
+	}
+
+	policy, err := o.FindPolicy(requirements.LifecyclePolicyKey)
+	require.NoError(t, err, "failed to find policy")
+	assert.Equal(t, lifecyclePolicy, policy)
+}
+
+func TestFindPolicyFromConfigMapInTeamNamespace(t *testing.T) {
+	o := &requirements.FinderOptions{
+		Namespace: "myapp-pipelines",
+		KubeClient: fake.NewSimpleClientset(
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "myapp-pipelines",
+					Labels: map[string]string{"team": "jx"},
+				},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: requirements.PolicyConfigMapName, Namespace: "jx"},
+				Data: map[string]string{
+					requirements.LifecyclePolicyKey: lifecyclePolicy,
+				},
+			},
+		),
+	}
+
+	policy, err := o.FindPolicy(requirements.LifecyclePolicyKey)
+	require.NoError(t, err, "failed to find policy")
+	assert.Equal(t, lifecyclePolicy, policy, "should find the ConfigMap in the jx namespace")
+}
+
+func TestFindPolicyIgnoresClusterFailures(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("get", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	jxClient := jxfake.NewSimpleClientset()
+	jxClient.PrependReactor("get", "environments", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	o := &requirements.FinderOptions{
+		Namespace:  "jx",
+		KubeClient: kubeClient,
+		JXClient:   jxClient,
+	}
+
+	policy, err := o.FindPolicy(requirements.LifecyclePolicyKey)
+	require.NoError(t, err, "should not fail if the cluster cannot be accessed")
+	assert.Empty(t, policy)
+}
+
+func TestFindPolicyFromDevEnvironment(t *testing.T) {
+	repoDir := t.TempDir()
+	policyDir := filepath.Join(repoDir, requirements.PolicyDir)
+	require.NoError(t, os.MkdirAll(policyDir, 0o755), "failed to create dir")
+	require.NoError(t, os.WriteFile(filepath.Join(policyDir, requirements.LifecyclePolicyKey), []byte(lifecyclePolicy), 0o600), "failed to write file")
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, jxcore.RequirementsConfigFileName), []byte("apiVersion: core.jenkins-x.io/v4beta1\nkind: Requirements\nspec:\n  cluster:\n    provider: eks\n"), 0o600), "failed to write file")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, "failed to run git %v: %s", args, string(out))
+	}
+
+	o := &requirements.FinderOptions{
+		Namespace:  "jx",
+		KubeClient: fake.NewSimpleClientset(),
+		JXClient: jxfake.NewSimpleClientset(&v1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "jx"},
+			Spec: v1.EnvironmentSpec{
+				Source: v1.EnvironmentRepository{URL: "file://" + repoDir},
+			},
+		}),
+	}
+
+	req, err := o.FindRequirements("", "")
+	require.NoError(t, err, "failed to find requirements")
+	assert.Equal(t, "eks", req.Cluster.Provider)
+
+	policy, err := o.FindPolicy(requirements.LifecyclePolicyKey)
+	require.NoError(t, err, "failed to find policy")
+	assert.Equal(t, lifecyclePolicy, policy)
+}
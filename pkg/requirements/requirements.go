@@ -0,0 +1,62 @@
+package requirements
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/variablefinders"
+	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
+	"github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+// FinderOptions options for finding the requirements of the development environment
+type FinderOptions struct {
+	Namespace         string
+	DevEnvironmentDir string
+	KubeClient        kubernetes.Interface
+	JXClient          versioned.Interface
+	GitClient         gitclient.Interface
+	CommandRunner     cmdrunner.CommandRunner
+	Requirements      *jxcore.RequirementsConfig
+
+	devNamespace         string
+	policyConfigMap      map[string]string
+	devEnvironmentCloned bool
+}
+
+// AddFlags adds the flags
+func (o *FinderOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "The namespace. Defaults to the current namespace")
+}
+
+// FindRequirements lazily loads the requirements from the development environment
+func (o *FinderOptions) FindRequirements(owner, repository string) (*jxcore.RequirementsConfig, error) {
+	if o.Requirements != nil {
+		return o.Requirements, nil
+	}
+	o.lazyCreateGitClient()
+	var err error
+	o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jxClient: %w", err)
+	}
+	o.Requirements, err = variablefinders.FindRequirements(o.GitClient, o.JXClient, o.Namespace, "", owner, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load requirements from dev environment: %w", err)
+	}
+	if o.Requirements == nil {
+		return nil, fmt.Errorf("no requirements found for dev environment")
+	}
+	return o.Requirements, nil
+}
+
+func (o *FinderOptions) lazyCreateGitClient() {
+	if o.GitClient == nil {
+		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
+	}
+}